@@ -1,5 +1,3 @@
 module github.com/SpaghettiDB/Storage-Engine
 
 go 1.22.0
-
-require github.com/krasun/fbptree v0.0.0-20220109123118-8003ab1ef37b
@@ -0,0 +1,323 @@
+// this is buffermanager package main file, this module is responsible
+// for caching the pages of the database files in memory.
+// the pool has a fixed number of frames, each frame holds one page.
+// a page is pinned while it is used and unpinned when the caller is done with it,
+// only unpinned pages can be evicted (using the clock algorithm) and dirty pages
+// are written back to their file when they are evicted or flushed.
+
+package buffermanager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	PageSize          = 8192
+	DefaultFrameCount = 1024
+)
+
+var ErrNoFreeFrame = errors.New("all the frames of the buffer pool are pinned")
+
+// a page is identified by the absolute path of its file and its offset in the file
+type pageID struct {
+	path   string
+	offset int64
+}
+
+type frame struct {
+	page     pageID
+	data     []byte
+	pinCount int
+	dirty    bool
+	// set on every access and cleared by the clock hand, gives the page a second chance
+	referenced bool
+	used       bool
+}
+
+// BufferPool caches pages of files in a fixed number of frames.
+type BufferPool struct {
+	mutex     sync.Mutex
+	frames    []frame
+	pageTable map[pageID]int
+	clockHand int
+	files     map[string]*os.File
+	// called with the data of a dirty page before it is written to its file
+	writeBackHook func(data []byte) error
+}
+
+var (
+	defaultPool     *BufferPool
+	defaultPoolOnce sync.Once
+)
+
+// Default returns the buffer pool shared by the storage engine.
+func Default() *BufferPool {
+	defaultPoolOnce.Do(func() {
+		defaultPool = NewBufferPool(DefaultFrameCount)
+	})
+	return defaultPool
+}
+
+// NewBufferPool creates a buffer pool with frameCount frames of PageSize bytes.
+func NewBufferPool(frameCount int) *BufferPool {
+	frames := make([]frame, frameCount)
+	for i := range frames {
+		frames[i].data = make([]byte, PageSize)
+	}
+
+	return &BufferPool{
+		frames:    frames,
+		pageTable: make(map[pageID]int),
+		files:     make(map[string]*os.File),
+	}
+}
+
+// FetchPage returns the page at offset in the file at path and pins it.
+// the page is read from the file if it is not in the pool, a page after the end
+// of the file is returned filled with zeros.
+// the returned slice is the frame itself, changes to it must be reported with UnpinPage(dirty = true).
+func (p *BufferPool) FetchPage(path string, offset int64) ([]byte, error) {
+	id, err := newPageID(path, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if frameIndex, ok := p.pageTable[id]; ok {
+		f := &p.frames[frameIndex]
+		f.pinCount++
+		f.referenced = true
+		return f.data, nil
+	}
+
+	frameIndex, err := p.findVictim()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &p.frames[frameIndex]
+	if err := p.readPage(id, f.data); err != nil {
+		return nil, err
+	}
+
+	f.page = id
+	f.pinCount = 1
+	f.dirty = false
+	f.referenced = true
+	f.used = true
+	p.pageTable[id] = frameIndex
+
+	return f.data, nil
+}
+
+// UnpinPage releases a page fetched with FetchPage.
+// dirty must be true if the page was changed, so it is written back before it leaves the pool.
+func (p *BufferPool) UnpinPage(path string, offset int64, dirty bool) error {
+	id, err := newPageID(path, offset)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	frameIndex, ok := p.pageTable[id]
+	if !ok {
+		return fmt.Errorf("page at offset %d of %s is not in the buffer pool", offset, path)
+	}
+
+	f := &p.frames[frameIndex]
+	if f.pinCount == 0 {
+		return fmt.Errorf("page at offset %d of %s is not pinned", offset, path)
+	}
+
+	f.pinCount--
+	f.dirty = f.dirty || dirty
+	return nil
+}
+
+// FlushFile writes all the dirty pages of the file at path and syncs the file.
+func (p *BufferPool) FlushFile(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i := range p.frames {
+		f := &p.frames[i]
+		if f.used && f.page.path == absPath {
+			if err := p.writeBack(f); err != nil {
+				return err
+			}
+		}
+	}
+
+	if file, ok := p.files[absPath]; ok {
+		return file.Sync()
+	}
+	return nil
+}
+
+// FlushAll writes all the dirty pages in the pool and syncs their files.
+func (p *BufferPool) FlushAll() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i := range p.frames {
+		if err := p.writeBack(&p.frames[i]); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range p.files {
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropFile removes all the pages of the file at path from the pool without writing them
+// and closes the file, it must be called before the file is deleted, truncated or renamed.
+func (p *BufferPool) DropFile(path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i := range p.frames {
+		f := &p.frames[i]
+		if !f.used || f.page.path != absPath {
+			continue
+		}
+		if f.pinCount > 0 {
+			return fmt.Errorf("page at offset %d of %s is pinned", f.page.offset, path)
+		}
+		delete(p.pageTable, f.page)
+		*f = frame{data: f.data}
+	}
+
+	if file, ok := p.files[absPath]; ok {
+		delete(p.files, absPath)
+		return file.Close()
+	}
+	return nil
+}
+
+// SetWriteBackHook sets a function that is called with the data of each dirty page before
+// the page is written to its file, the write fails if the hook returns an error.
+// it is used to flush the write-ahead log up to the last change of the page.
+func (p *BufferPool) SetWriteBackHook(hook func(data []byte) error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.writeBackHook = hook
+}
+
+// finds a frame for a new page using the clock algorithm.
+// the dirty victim is written back to its file before the frame is reused.
+func (p *BufferPool) findVictim() (int, error) {
+	// two rounds are enough, the first one clears the referenced bits
+	for i := 0; i < 2*len(p.frames); i++ {
+		frameIndex := p.clockHand
+		p.clockHand = (p.clockHand + 1) % len(p.frames)
+
+		f := &p.frames[frameIndex]
+		if !f.used {
+			return frameIndex, nil
+		}
+		if f.pinCount > 0 {
+			continue
+		}
+		if f.referenced {
+			f.referenced = false
+			continue
+		}
+
+		if err := p.writeBack(f); err != nil {
+			return 0, err
+		}
+		delete(p.pageTable, f.page)
+		f.used = false
+		return frameIndex, nil
+	}
+
+	return 0, ErrNoFreeFrame
+}
+
+// writes the frame to its file if it is dirty
+func (p *BufferPool) writeBack(f *frame) error {
+	if !f.used || !f.dirty {
+		return nil
+	}
+
+	if p.writeBackHook != nil {
+		if err := p.writeBackHook(f.data); err != nil {
+			return err
+		}
+	}
+
+	file, err := p.openFile(f.page.path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteAt(f.data, f.page.offset); err != nil {
+		return fmt.Errorf("failed to write page at offset %d of %s: %w", f.page.offset, f.page.path, err)
+	}
+
+	f.dirty = false
+	return nil
+}
+
+// reads the page from its file into data, the part after the end of the file is zeroed
+func (p *BufferPool) readPage(id pageID, data []byte) error {
+	file, err := p.openFile(id.path)
+	if err != nil {
+		return err
+	}
+
+	n, err := file.ReadAt(data, id.offset)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read page at offset %d of %s: %w", id.offset, id.path, err)
+	}
+
+	clear(data[n:])
+	return nil
+}
+
+// returns the open file of the pool for the path, the files stay open until they are dropped
+func (p *BufferPool) openFile(path string) (*os.File, error) {
+	if file, ok := p.files[path]; ok {
+		return file, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	p.files[path] = file
+	return file, nil
+}
+
+func newPageID(path string, offset int64) (pageID, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return pageID{}, err
+	}
+	return pageID{path: absPath, offset: offset}, nil
+}
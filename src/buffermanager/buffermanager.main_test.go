@@ -0,0 +1,208 @@
+package buffermanager
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+// the files of the tests are created in the working directory, the tests run in a temporary one
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "buffermanager")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// creates the file with pageCount pages, page i is filled with byte i+1
+func createTestFile(t *testing.T, name string, pageCount int) {
+	t.Helper()
+
+	data := make([]byte, 0, pageCount*PageSize)
+	for i := 0; i < pageCount; i++ {
+		data = append(data, bytes.Repeat([]byte{byte(i + 1)}, PageSize)...)
+	}
+	if err := os.WriteFile(name, data, 0644); err != nil {
+		t.Fatalf("failed to create %s: %s", name, err)
+	}
+}
+
+func fetchPage(t *testing.T, pool *BufferPool, name string, pageIndex int) []byte {
+	t.Helper()
+
+	page, err := pool.FetchPage(name, int64(pageIndex*PageSize))
+	if err != nil {
+		t.Fatalf("failed to fetch page %d of %s: %s", pageIndex, name, err)
+	}
+	return page
+}
+
+func unpinPage(t *testing.T, pool *BufferPool, name string, pageIndex int, dirty bool) {
+	t.Helper()
+
+	if err := pool.UnpinPage(name, int64(pageIndex*PageSize), dirty); err != nil {
+		t.Fatalf("failed to unpin page %d of %s: %s", pageIndex, name, err)
+	}
+}
+
+// checks that every byte of the page in the file is b
+func checkFilePage(t *testing.T, name string, pageIndex int, b byte) {
+	t.Helper()
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := data[pageIndex*PageSize : (pageIndex+1)*PageSize]
+	if !bytes.Equal(page, bytes.Repeat([]byte{b}, PageSize)) {
+		t.Fatalf("page %d of %s starts with %d, expected %d", pageIndex, name, page[0], b)
+	}
+}
+
+// checks whether the page is in one of the frames of the pool
+func isCached(pool *BufferPool, name string, pageIndex int) bool {
+	id, _ := newPageID(name, int64(pageIndex*PageSize))
+	_, ok := pool.pageTable[id]
+	return ok
+}
+
+// an unpinned page is evicted to make room for a new one, a dirty one is written to its file first
+func TestEvictUnpinnedPage(t *testing.T) {
+	createTestFile(t, "evict", 3)
+	pool := NewBufferPool(2)
+
+	first := fetchPage(t, pool, "evict", 0)
+	if first[0] != 1 {
+		t.Fatalf("page 0 was read as %d", first[0])
+	}
+	first[0] = 0xAA
+	unpinPage(t, pool, "evict", 0, true)
+	fetchPage(t, pool, "evict", 1)
+
+	// page 1 is pinned, page 0 is the only page that can leave the pool
+	third := fetchPage(t, pool, "evict", 2)
+	if third[0] != 3 {
+		t.Fatalf("page 2 was read as %d", third[0])
+	}
+	if isCached(pool, "evict", 0) || !isCached(pool, "evict", 1) || !isCached(pool, "evict", 2) {
+		t.Fatal("the pool did not evict page 0, the only unpinned page")
+	}
+
+	data, err := os.ReadFile("evict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != 0xAA {
+		t.Fatal("the change of page 0 was not written to the file when it was evicted")
+	}
+
+	// the page is read again from the file
+	unpinPage(t, pool, "evict", 2, false)
+	if page := fetchPage(t, pool, "evict", 0); page[0] != 0xAA || page[1] != 1 {
+		t.Fatalf("page 0 was read again as %d %d", page[0], page[1])
+	}
+}
+
+// the pages in use stay in the pool, a fetch fails if all the frames are pinned
+func TestEvictPinnedPage(t *testing.T) {
+	createTestFile(t, "pinned", 3)
+	pool := NewBufferPool(2)
+
+	fetchPage(t, pool, "pinned", 0)
+	fetchPage(t, pool, "pinned", 1)
+	// a page can be pinned again while it is pinned, it stays in the pool until both are released
+	fetchPage(t, pool, "pinned", 1)
+
+	if _, err := pool.FetchPage("pinned", 2*PageSize); !errors.Is(err, ErrNoFreeFrame) {
+		t.Fatalf("expected ErrNoFreeFrame with all the frames pinned, got %v", err)
+	}
+
+	unpinPage(t, pool, "pinned", 1, false)
+	if _, err := pool.FetchPage("pinned", 2*PageSize); !errors.Is(err, ErrNoFreeFrame) {
+		t.Fatalf("expected ErrNoFreeFrame with page 1 pinned once more, got %v", err)
+	}
+
+	unpinPage(t, pool, "pinned", 1, false)
+	fetchPage(t, pool, "pinned", 2)
+	if !isCached(pool, "pinned", 0) || isCached(pool, "pinned", 1) {
+		t.Fatal("the pool did not evict page 1 once it was unpinned")
+	}
+
+	if err := pool.UnpinPage("pinned", 1*PageSize, false); err == nil {
+		t.Fatal("unpinning a page that is not in the pool must fail")
+	}
+	unpinPage(t, pool, "pinned", 2, false)
+	if err := pool.UnpinPage("pinned", 2*PageSize, false); err == nil {
+		t.Fatal("unpinning a page that is not pinned must fail")
+	}
+	if err := pool.DropFile("pinned"); err == nil {
+		t.Fatal("dropping a file with a pinned page must fail")
+	}
+}
+
+// the hook runs before the page reaches its file, the page is not written if the hook fails
+func TestWriteBackHook(t *testing.T) {
+	createTestFile(t, "hook", 2)
+	pool := NewBufferPool(1)
+
+	hookErr := errors.New("the log cannot be flushed")
+	var failure error
+	calls := 0
+	pool.SetWriteBackHook(func(data []byte) error {
+		calls++
+		// the file still has the old page when the hook runs
+		checkFilePage(t, "hook", 0, 1)
+		if data[0] != 0xBB {
+			t.Fatalf("the hook got a page starting with %d", data[0])
+		}
+		return failure
+	})
+
+	page := fetchPage(t, pool, "hook", 0)
+	page[0] = 0xBB
+	unpinPage(t, pool, "hook", 0, true)
+
+	failure = hookErr
+	if _, err := pool.FetchPage("hook", PageSize); !errors.Is(err, hookErr) {
+		t.Fatalf("expected the error of the hook on eviction, got %v", err)
+	}
+	if err := pool.FlushFile("hook"); !errors.Is(err, hookErr) {
+		t.Fatalf("expected the error of the hook on flush, got %v", err)
+	}
+	checkFilePage(t, "hook", 0, 1)
+	if !isCached(pool, "hook", 0) {
+		t.Fatal("the page was evicted without being written")
+	}
+
+	failure = nil
+	if err := pool.FlushFile("hook"); err != nil {
+		t.Fatalf("failed to flush the file: %s", err)
+	}
+	if calls != 3 {
+		t.Fatalf("the hook was called %d times, expected 3", calls)
+	}
+	data, err := os.ReadFile("hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != 0xBB {
+		t.Fatal("the page was not written after the hook succeeded")
+	}
+
+	// a clean page is not written again and does not call the hook
+	if err := pool.FlushFile("hook"); err != nil {
+		t.Fatalf("failed to flush the file: %s", err)
+	}
+	fetchPage(t, pool, "hook", 1)
+	if calls != 3 {
+		t.Fatalf("the hook was called for a clean page, %d calls", calls)
+	}
+}
@@ -0,0 +1,514 @@
+// this is checkexpr package main file, it compiles the CHECK expressions of the columns of a table
+// and evaluates them on the values of a row.
+//
+// expression:
+//
+//	expr       := and { OR and }
+//	and        := not { AND not }
+//	not        := NOT not | primary
+//	primary    := ( expr ) | operand IS [NOT] NULL | operand op operand | operand
+//	operand    := column | number | 'string' | TRUE | FALSE
+//	op         := = | != | <> | < | <= | > | >=
+//
+//   - the keywords are not case sensitive, a string literal doubles its quotes ('it''s').
+//   - a literal compared with a column is converted to the data type of the column (see types.Convert),
+//     so a timestamp column is compared with an RFC 3339 string. ints and floats are compared as floats.
+//   - an operand alone must be a bool (a bool column or TRUE or FALSE).
+//   - a comparison with a null value is unknown, and like in SQL a CHECK fails only when its expression
+//     is false, so a row with a null passes (use IS NOT NULL or NOT NULL on the column to reject it).
+
+package checkexpr
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+// Expr is a compiled CHECK expression.
+type Expr struct {
+	expression string
+	root       node
+	columns    []string
+}
+
+// the value of a node, SQL has a third value for the comparisons with null
+type truth int8
+
+const (
+	truthFalse truth = iota
+	truthTrue
+	truthUnknown
+)
+
+type node interface {
+	eval(value func(column string) any) truth
+}
+
+// Compile parses the expression, columnType returns the data type of a column of the table
+// and false when the table has no column with the name.
+func Compile(expression string, columnType func(name string) (types.DataType, bool)) (*Expr, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid check %q: %w", expression, err)
+	}
+
+	p := &parser{tokens: tokens, columnType: columnType}
+	root, err := p.or()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid check %q: %w", expression, err)
+	}
+	return &Expr{expression: expression, root: root, columns: p.columns}, nil
+}
+
+// String returns the expression as it was compiled.
+func (e *Expr) String() string {
+	return e.expression
+}
+
+// Columns returns the columns used by the expression, each once.
+func (e *Expr) Columns() []string {
+	return e.columns
+}
+
+// Eval reports whether the row passes the check, value returns the value of a column of the row
+// (the go value of its data type, nil for null). only a false expression fails, an unknown one passes.
+func (e *Expr) Eval(value func(column string) any) bool {
+	return e.root.eval(value) != truthFalse
+}
+
+// RenameColumn returns the expression with the column oldName renamed to newName,
+// the rest of the expression is kept as it is.
+func RenameColumn(expression string, oldName string, newName string) (string, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return "", fmt.Errorf("invalid check %q: %w", expression, err)
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, t := range tokens {
+		if t.kind == identifier && t.text == oldName {
+			b.WriteString(expression[last:t.start])
+			b.WriteString(newName)
+			last = t.end
+		}
+	}
+	b.WriteString(expression[last:])
+	return b.String(), nil
+}
+
+type and struct{ left, right node }
+
+func (n and) eval(value func(string) any) truth {
+	left, right := n.left.eval(value), n.right.eval(value)
+	switch {
+	case left == truthFalse || right == truthFalse:
+		return truthFalse
+	case left == truthUnknown || right == truthUnknown:
+		return truthUnknown
+	}
+	return truthTrue
+}
+
+type or struct{ left, right node }
+
+func (n or) eval(value func(string) any) truth {
+	left, right := n.left.eval(value), n.right.eval(value)
+	switch {
+	case left == truthTrue || right == truthTrue:
+		return truthTrue
+	case left == truthUnknown || right == truthUnknown:
+		return truthUnknown
+	}
+	return truthFalse
+}
+
+type not struct{ operand node }
+
+func (n not) eval(value func(string) any) truth {
+	switch n.operand.eval(value) {
+	case truthTrue:
+		return truthFalse
+	case truthFalse:
+		return truthTrue
+	}
+	return truthUnknown
+}
+
+type isNull struct {
+	operand operand
+	negated bool
+}
+
+func (n isNull) eval(value func(string) any) truth {
+	if (n.operand.value(value) == nil) != n.negated {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+type comparison struct {
+	left, right operand
+	op          string
+	// ints are compared with floats as floats
+	numeric bool
+}
+
+func (n comparison) eval(value func(string) any) truth {
+	left, right := n.left.value(value), n.right.value(value)
+	if left == nil || right == nil {
+		return truthUnknown
+	}
+	if n.numeric {
+		left, right = toFloat(left), toFloat(right)
+	}
+
+	c := compare(left, right)
+	var result bool
+	switch n.op {
+	case "=":
+		result = c == 0
+	case "!=", "<>":
+		result = c != 0
+	case "<":
+		result = c < 0
+	case "<=":
+		result = c <= 0
+	case ">":
+		result = c > 0
+	case ">=":
+		result = c >= 0
+	}
+	if result {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// a bool operand alone
+type predicate struct{ operand operand }
+
+func (n predicate) eval(value func(string) any) truth {
+	switch n.operand.value(value) {
+	case nil:
+		return truthUnknown
+	case true:
+		return truthTrue
+	}
+	return truthFalse
+}
+
+// an operand is a column or a literal, a literal has no column
+type operand struct {
+	column   string
+	literal  any
+	dataType types.DataType
+}
+
+func (o operand) value(value func(string) any) any {
+	if o.column == "" {
+		return o.literal
+	}
+	return value(o.column)
+}
+
+func toFloat(v any) any {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v
+}
+
+// compares two values of the same data type
+func compare(a any, b any) int {
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		}
+		if !a {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+type tokenKind int
+
+const (
+	identifier tokenKind = iota
+	number
+	stringLiteral
+	symbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// the offsets of the token in the expression
+	start, end int
+}
+
+// returns the tokens of the expression, a string literal is unquoted
+func tokenize(s string) ([]token, error) {
+	tokens := make([]token, 0)
+	for i := 0; i < len(s); {
+		c, start := s[i], i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case isLetter(c):
+			for i < len(s) && (isLetter(s[i]) || isDigit(s[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: identifier, text: s[start:i], start: start, end: i})
+
+		case isDigit(c) || (c == '-' && i+1 < len(s) && isDigit(s[i+1])):
+			i++
+			for i < len(s) && (isDigit(s[i]) || strings.IndexByte(".eE", s[i]) >= 0 ||
+				(strings.IndexByte("+-", s[i]) >= 0 && strings.IndexByte("eE", s[i-1]) >= 0)) {
+				i++
+			}
+			tokens = append(tokens, token{kind: number, text: s[start:i], start: start, end: i})
+
+		case c == '\'':
+			var b strings.Builder
+			i++
+			for {
+				if i >= len(s) {
+					return nil, fmt.Errorf("unterminated string")
+				}
+				if s[i] == '\'' {
+					if i+1 < len(s) && s[i+1] == '\'' {
+						b.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: stringLiteral, text: b.String(), start: start, end: i})
+
+		default:
+			op := ""
+			for _, candidate := range []string{"<=", ">=", "!=", "<>", "=", "<", ">", "(", ")"} {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			i += len(op)
+			tokens = append(tokens, token{kind: symbol, text: op, start: start, end: i})
+		}
+	}
+	return tokens, nil
+}
+
+func isLetter(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+type parser struct {
+	tokens     []token
+	pos        int
+	columnType func(name string) (types.DataType, bool)
+	columns    []string
+}
+
+// reports whether the next token is the keyword and consumes it
+func (p *parser) keyword(k string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == identifier && strings.EqualFold(p.tokens[p.pos].text, k) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// reports whether the next token is the symbol and consumes it
+func (p *parser) symbol(s string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == symbol && p.tokens[p.pos].text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	for err == nil && p.keyword("OR") {
+		var right node
+		right, err = p.and()
+		left = or{left, right}
+	}
+	return left, err
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.not()
+	for err == nil && p.keyword("AND") {
+		var right node
+		right, err = p.not()
+		left = and{left, right}
+	}
+	return left, err
+}
+
+func (p *parser) not() (node, error) {
+	if p.keyword("NOT") {
+		operand, err := p.not()
+		return not{operand}, err
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	if p.symbol("(") {
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.symbol(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		return n, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.keyword("IS") {
+		negated := p.keyword("NOT")
+		if !p.keyword("NULL") {
+			return nil, fmt.Errorf("expected NULL after IS")
+		}
+		return isNull{operand: left, negated: negated}, nil
+	}
+
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == symbol && p.tokens[p.pos].text != "(" && p.tokens[p.pos].text != ")" {
+		op := p.tokens[p.pos].text
+		p.pos++
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return p.comparison(left, op, right)
+	}
+
+	if left.dataType != types.Bool {
+		return nil, fmt.Errorf("%s is not a condition", describe(left))
+	}
+	return predicate{operand: left}, nil
+}
+
+// returns the comparison of the operands, converting a literal to the data type of the other operand
+func (p *parser) comparison(left operand, op string, right operand) (node, error) {
+	if left.literal == nil && left.column == "" || right.literal == nil && right.column == "" {
+		return nil, fmt.Errorf("a comparison with NULL is never true, use IS NULL")
+	}
+
+	numeric := func(t types.DataType) bool { return t == types.Int || t == types.Float }
+	switch {
+	case left.dataType == right.dataType:
+		return comparison{left: left, right: right, op: op}, nil
+	case numeric(left.dataType) && numeric(right.dataType):
+		return comparison{left: left, right: right, op: op, numeric: true}, nil
+	case left.column == "":
+		converted, err := convert(left, right.dataType)
+		return comparison{left: converted, right: right, op: op}, err
+	case right.column == "":
+		converted, err := convert(right, left.dataType)
+		return comparison{left: left, right: converted, op: op}, err
+	}
+	return nil, fmt.Errorf("cannot compare %s with %s", describe(left), describe(right))
+}
+
+func convert(literal operand, dataType types.DataType) (operand, error) {
+	value, err := types.Convert(literal.literal, literal.dataType, dataType)
+	if err != nil {
+		return operand{}, err
+	}
+	return operand{literal: value, dataType: dataType}, nil
+}
+
+func describe(o operand) string {
+	if o.column != "" {
+		return fmt.Sprintf("column %s (%s)", o.column, o.dataType)
+	}
+	return fmt.Sprintf("%v (%s)", o.literal, o.dataType)
+}
+
+func (p *parser) operand() (operand, error) {
+	if p.pos >= len(p.tokens) {
+		return operand{}, fmt.Errorf("unexpected end of the expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+
+	switch t.kind {
+	case number:
+		if i, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return operand{literal: i, dataType: types.Int}, nil
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return operand{}, fmt.Errorf("invalid number %q", t.text)
+		}
+		return operand{literal: f, dataType: types.Float}, nil
+
+	case stringLiteral:
+		return operand{literal: t.text, dataType: types.String}, nil
+
+	case identifier:
+		switch strings.ToUpper(t.text) {
+		case "TRUE":
+			return operand{literal: true, dataType: types.Bool}, nil
+		case "FALSE":
+			return operand{literal: false, dataType: types.Bool}, nil
+		case "NULL":
+			return operand{}, nil
+		case "AND", "OR", "NOT", "IS":
+			return operand{}, fmt.Errorf("unexpected %s", t.text)
+		}
+
+		dataType, ok := p.columnType(t.text)
+		if !ok {
+			return operand{}, fmt.Errorf("column %s does not exist", t.text)
+		}
+		if !slices.Contains(p.columns, t.text) {
+			p.columns = append(p.columns, t.text)
+		}
+		return operand{column: t.text, dataType: dataType}, nil
+	}
+	return operand{}, fmt.Errorf("unexpected %q", t.text)
+}
@@ -0,0 +1,36 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ConstraintType string
+
+const (
+	NotNull    ConstraintType = "NOT NULL"
+	Unique     ConstraintType = "UNIQUE"
+	PrimaryKey ConstraintType = "PRIMARY KEY"
+	Check      ConstraintType = "CHECK"
+	ForeignKey ConstraintType = "FOREIGN KEY"
+	// you can add more constraint types here
+)
+
+// ConstraintViolationError is returned when a row written to a table does not satisfy a constraint of its columns.
+// Name is the index behind a UNIQUE or PRIMARY KEY constraint, the expression of a CHECK and the name of a FOREIGN KEY,
+// the table of a FOREIGN KEY is the table that has the foreign key (the one that references the other).
+type ConstraintViolationError struct {
+	Constraint ConstraintType
+	Table      string
+	Columns    []string
+	Name       string
+}
+
+// Error returns the error message.
+func (e *ConstraintViolationError) Error() string {
+	message := fmt.Sprintf("%s constraint on %s(%s) is violated", e.Constraint, e.Table, strings.Join(e.Columns, ", "))
+	if e.Name != "" {
+		message += fmt.Sprintf(" (%s)", e.Name)
+	}
+	return message
+}
@@ -0,0 +1,193 @@
+package fbptree
+
+import (
+	"fmt"
+)
+
+// BulkLoad builds the tree bottom-up from count entries given in strictly ascending key order
+// by next, which is called exactly count times. The tree must be empty.
+//
+// The shape of the tree is computed first: the entries are spread evenly over the leaves
+// and every node is filled up to fill (between 0.5 and 1) of its capacity, so the later puts
+// have room before the nodes split. The leaves are then written one by one while the entries
+// are read, and the internal levels are written from the first keys of their children.
+// Only the first keys of the nodes are kept in memory.
+func (t *FBPTree) BulkLoad(count int, next func() (key, value []byte, err error), fill float64) error {
+	if t.metadata != nil {
+		return fmt.Errorf("the tree must be empty to be bulk loaded")
+	}
+	if fill < 0.5 || fill > 1 {
+		return fmt.Errorf("the fill factor must be between 0.5 and 1, but received %f", fill)
+	}
+	if count <= 0 {
+		return nil
+	}
+	if count > maxTreeSize {
+		return fmt.Errorf("maximum tree size is %d, but received %d", maxTreeSize, count)
+	}
+
+	// sizes[0] is the number of keys of each leaf,
+	// sizes[l] is the number of children of each node of the level l
+	sizes := [][]int{distribute(count, t.minKeyNum, t.order-1, fill)}
+	for len(sizes[len(sizes)-1]) > 1 {
+		children := len(sizes[len(sizes)-1])
+		sizes = append(sizes, distribute(children, t.minKeyNum+1, t.order, fill))
+	}
+
+	ids := make([][]uint32, len(sizes))
+	for level, levelSizes := range sizes {
+		ids[level] = make([]uint32, len(levelSizes))
+		for i := range ids[level] {
+			nodeID, err := t.storage.newNode()
+			if err != nil {
+				return fmt.Errorf("failed to instantiate new node: %w", err)
+			}
+
+			ids[level][i] = nodeID
+		}
+	}
+
+	// the first key of each node of the current level
+	firstKeys, err := t.bulkLoadLeaves(sizes[0], ids[0], parentIDs(sizes, ids, 0), next)
+	if err != nil {
+		return err
+	}
+
+	for level := 1; level < len(sizes); level++ {
+		firstKeys, err = t.bulkLoadInternalNodes(sizes[level], ids[level], ids[level-1], parentIDs(sizes, ids, level), firstKeys)
+		if err != nil {
+			return err
+		}
+	}
+
+	root := ids[len(ids)-1][0]
+	if err := t.updateMetadata(root, ids[0][0], uint32(count)); err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+
+	return nil
+}
+
+func (t *FBPTree) bulkLoadLeaves(sizes []int, ids []uint32, parents []uint32, next func() ([]byte, []byte, error)) ([][]byte, error) {
+	firstKeys := make([][]byte, len(sizes))
+
+	var previous []byte
+	for i, size := range sizes {
+		n := &node{
+			id:       ids[i],
+			leaf:     true,
+			parentID: parents[i],
+			keys:     make([][]byte, t.order-1),
+			keyNum:   size,
+			pointers: make([]*pointer, t.order),
+		}
+
+		for k := 0; k < size; k++ {
+			key, value, err := next()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the next entry: %w", err)
+			}
+			if len(key) > maxKeySize {
+				return nil, fmt.Errorf("maximum key size is %d, but received %d", maxKeySize, len(key))
+			} else if len(value) > maxValueSize {
+				return nil, fmt.Errorf("maximum value size is %d, but received %d", maxValueSize, len(value))
+			}
+			if previous != nil && !less(previous, key) {
+				return nil, fmt.Errorf("the keys must be in strictly ascending order")
+			}
+			previous = key
+
+			n.keys[k] = copyBytes(key)
+			n.pointers[k] = &pointer{copyBytes(value)}
+		}
+
+		if i+1 < len(ids) {
+			n.setNext(&pointer{ids[i+1]})
+		}
+
+		if err := t.storage.updateNodeByID(n.id, n); err != nil {
+			return nil, fmt.Errorf("failed to store the leaf node %d: %w", n.id, err)
+		}
+
+		firstKeys[i] = n.keys[0]
+	}
+
+	return firstKeys, nil
+}
+
+func (t *FBPTree) bulkLoadInternalNodes(sizes []int, ids []uint32, childIDs []uint32, parents []uint32, childFirstKeys [][]byte) ([][]byte, error) {
+	firstKeys := make([][]byte, len(sizes))
+
+	child := 0
+	for i, size := range sizes {
+		n := &node{
+			id:       ids[i],
+			leaf:     false,
+			parentID: parents[i],
+			keys:     make([][]byte, t.order-1),
+			keyNum:   size - 1,
+			pointers: make([]*pointer, t.order),
+		}
+
+		// the key before each child but the first one is the first key of its subtree
+		firstKeys[i] = childFirstKeys[child]
+		for c := 0; c < size; c++ {
+			n.pointers[c] = &pointer{childIDs[child]}
+			if c > 0 {
+				n.keys[c-1] = childFirstKeys[child]
+			}
+			child++
+		}
+
+		if err := t.storage.updateNodeByID(n.id, n); err != nil {
+			return nil, fmt.Errorf("failed to store the internal node %d: %w", n.id, err)
+		}
+	}
+
+	return firstKeys, nil
+}
+
+// parentIDs returns the id of the parent of each node of the level, 0 for the root.
+func parentIDs(sizes [][]int, ids [][]uint32, level int) []uint32 {
+	parents := make([]uint32, len(ids[level]))
+	if level+1 == len(sizes) {
+		return parents
+	}
+
+	child := 0
+	for i, size := range sizes[level+1] {
+		for c := 0; c < size; c++ {
+			parents[child] = ids[level+1][i]
+			child++
+		}
+	}
+
+	return parents
+}
+
+// distribute spreads n items over the fewest nodes filled up to fill of maxSize,
+// evenly so that every node has at least minSize items when there is more than one node.
+func distribute(n, minSize, maxSize int, fill float64) []int {
+	capacity := int(fill * float64(maxSize))
+	if capacity < minSize {
+		capacity = minSize
+	}
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	nodes := ceil(n, capacity)
+	for nodes > 1 && n/nodes < minSize {
+		nodes--
+	}
+
+	sizes := make([]int, nodes)
+	for i := range sizes {
+		sizes[i] = n / nodes
+		if i < n%nodes {
+			sizes[i]++
+		}
+	}
+
+	return sizes
+}
@@ -0,0 +1,85 @@
+package fbptree
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestBulkLoad(t *testing.T) {
+	for _, count := range []int{1, 3, 4, 50, 1000} {
+		for _, fill := range []float64{0.5, 0.7, 1} {
+			tree := openTestTree(t, 5)
+
+			i := 0
+			err := tree.BulkLoad(count, func() ([]byte, []byte, error) {
+				i++
+				return testKey(2 * (i - 1)), testKey(2 * (i - 1)), nil
+			}, fill)
+			if err != nil {
+				t.Fatalf("failed to bulk load %d keys with fill %v: %s", count, fill, err)
+			}
+			if tree.Size() != count {
+				t.Fatalf("the tree has %d keys, expected %d", tree.Size(), count)
+			}
+
+			checkTreeKeys(t, tree, count)
+
+			// the tree is a regular tree after the load
+			if _, _, err := tree.Put(testKey(1), testKey(1)); err != nil {
+				t.Fatalf("failed to put after the load: %s", err)
+			}
+			if _, ok, err := tree.Delete(testKey(0)); err != nil || !ok {
+				t.Fatalf("failed to delete after the load: %v", err)
+			}
+			if value, ok, err := tree.Get(testKey(1)); err != nil || !ok || !bytes.Equal(value, testKey(1)) {
+				t.Fatalf("Get(1) after the load = %s, %v, %v", value, ok, err)
+			}
+		}
+	}
+}
+
+// checks that the tree has the even keys from 0 to 2*(count-1) and that its stats count them
+func checkTreeKeys(t *testing.T, tree *FBPTree, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		value, ok, err := tree.Get(testKey(2 * i))
+		if err != nil || !ok || !bytes.Equal(value, testKey(2*i)) {
+			t.Fatalf("Get(%d) = %s, %v, %v", 2*i, value, ok, err)
+		}
+	}
+
+	stats, err := tree.Stats()
+	if err != nil {
+		t.Fatalf("failed to get the stats: %s", err)
+	}
+	if stats.Keys != count {
+		t.Fatalf("the leaves have %d keys, expected %d", stats.Keys, count)
+	}
+	height, err := tree.Height()
+	if err != nil || height != stats.Height {
+		t.Fatalf("Height() = %d, %v, the stats have %d", height, err, stats.Height)
+	}
+}
+
+func TestBulkLoadErrors(t *testing.T) {
+	tree := openTestTree(t, 5)
+	next := func() ([]byte, []byte, error) { return testKey(0), testKey(0), nil }
+
+	if err := tree.BulkLoad(1, next, 0.4); err == nil {
+		t.Fatal("must return an error for a fill factor below 0.5")
+	}
+
+	if _, _, err := tree.Put(testKey(0), testKey(0)); err != nil {
+		t.Fatalf("failed to put: %s", err)
+	}
+	if err := tree.BulkLoad(1, next, 1); err == nil {
+		t.Fatal("must return an error for a tree that is not empty")
+	}
+
+	tree = openTestTree(t, 5)
+	if err := tree.BulkLoad(1, func() ([]byte, []byte, error) { return nil, nil, fmt.Errorf("some error") }, 1); err == nil {
+		t.Fatal("must return the error of next")
+	}
+}
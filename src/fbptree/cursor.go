@@ -0,0 +1,250 @@
+package fbptree
+
+import "fmt"
+
+// Cursor is a position in the tree that can be moved in both directions.
+// It keeps the path from the root to the current leaf, so moving to the
+// previous leaf does not need a "previous" pointer in the leaf nodes.
+//
+// The cursor must not be used after the tree is changed.
+type Cursor struct {
+	tree *FBPTree
+
+	// path from the root to the current leaf, positions[i] is the
+	// position of the pointer followed in path[i] (the key position in the leaf)
+	path      []*node
+	positions []int
+
+	valid bool
+	err   error
+}
+
+// Cursor returns a cursor that is not positioned yet, call one of the
+// Seek methods before reading from it.
+func (t *FBPTree) Cursor() *Cursor {
+	return &Cursor{tree: t}
+}
+
+// Seek moves the cursor to the first key that is greater than or equal to the key,
+// or to the first key of the tree if the key is nil. Returns false if there is no such key.
+func (c *Cursor) Seek(key []byte) bool {
+	if !c.descend(func(n *node) int {
+		if key == nil {
+			return 0
+		}
+
+		position := 0
+		for position < n.keyNum && !less(key, n.keys[position]) {
+			position++
+		}
+
+		return position
+	}) {
+		return false
+	}
+
+	leaf, position := c.leaf()
+	for position < leaf.keyNum && key != nil && less(leaf.keys[position], key) {
+		position++
+	}
+	c.positions[len(c.positions)-1] = position
+
+	if position == leaf.keyNum {
+		// all the keys of the leaf are less than the key, the next leaf has the first greater one
+		return c.nextLeaf()
+	}
+
+	return true
+}
+
+// SeekLast moves the cursor to the last key of the tree. Returns false if the tree is empty.
+func (c *Cursor) SeekLast() bool {
+	if !c.descend(func(n *node) int {
+		return n.keyNum
+	}) {
+		return false
+	}
+
+	leaf, _ := c.leaf()
+	c.positions[len(c.positions)-1] = leaf.keyNum - 1
+
+	return c.valid
+}
+
+// Next moves the cursor to the next key. Returns false if there is no next key.
+func (c *Cursor) Next() bool {
+	if !c.valid {
+		return false
+	}
+
+	leaf, position := c.leaf()
+	if position+1 < leaf.keyNum {
+		c.positions[len(c.positions)-1]++
+
+		return true
+	}
+
+	return c.nextLeaf()
+}
+
+// Prev moves the cursor to the previous key. Returns false if there is no previous key.
+func (c *Cursor) Prev() bool {
+	if !c.valid {
+		return false
+	}
+
+	_, position := c.leaf()
+	if position > 0 {
+		c.positions[len(c.positions)-1]--
+
+		return true
+	}
+
+	return c.prevLeaf()
+}
+
+// Valid returns true if the cursor is positioned at a key.
+func (c *Cursor) Valid() bool {
+	return c.valid
+}
+
+// Key returns the key at the cursor position.
+// Caution! Key panics if the cursor is not valid.
+func (c *Cursor) Key() []byte {
+	leaf, position := c.leaf()
+
+	return leaf.keys[position]
+}
+
+// Value returns the value at the cursor position.
+// Caution! Value panics if the cursor is not valid.
+func (c *Cursor) Value() []byte {
+	leaf, position := c.leaf()
+
+	return leaf.pointers[position].asValue()
+}
+
+// Err returns the error that invalidated the cursor, if any.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// descend resets the path and follows it from the root to a leaf, choose returns
+// the pointer position to follow in each internal node and the leaf position is set to 0.
+func (c *Cursor) descend(choose func(n *node) int) bool {
+	c.path, c.positions, c.valid = c.path[:0], c.positions[:0], false
+	if c.err != nil || c.tree.metadata == nil {
+		return false
+	}
+
+	current, err := c.tree.storage.loadNodeByID(c.tree.metadata.rootID)
+	if err != nil {
+		c.err = fmt.Errorf("failed to load root node: %w", err)
+
+		return false
+	}
+
+	if !c.down(current, choose) {
+		return false
+	}
+
+	leaf, _ := c.leaf()
+	c.valid = leaf.keyNum > 0
+
+	return c.valid
+}
+
+// down appends the nodes from n to a leaf to the path.
+func (c *Cursor) down(n *node, choose func(n *node) int) bool {
+	current := n
+	for !current.leaf {
+		position := choose(current)
+		c.path = append(c.path, current)
+		c.positions = append(c.positions, position)
+
+		nextID := current.pointers[position].asNodeID()
+		nextNode, err := c.tree.storage.loadNodeByID(nextID)
+		if err != nil {
+			c.err = fmt.Errorf("failed to load next node %d: %w", nextID, err)
+			c.valid = false
+
+			return false
+		}
+
+		current = nextNode
+	}
+
+	c.path = append(c.path, current)
+	c.positions = append(c.positions, 0)
+
+	return true
+}
+
+// nextLeaf moves the cursor to the first key of the next leaf.
+func (c *Cursor) nextLeaf() bool {
+	return c.moveLeaf(func(n *node, position int) (int, bool) {
+		return position + 1, position < n.keyNum
+	}, func(n *node) int {
+		return 0
+	}, func(leaf *node) int {
+		return 0
+	})
+}
+
+// prevLeaf moves the cursor to the last key of the previous leaf.
+func (c *Cursor) prevLeaf() bool {
+	return c.moveLeaf(func(n *node, position int) (int, bool) {
+		return position - 1, position > 0
+	}, func(n *node) int {
+		return n.keyNum
+	}, func(leaf *node) int {
+		return leaf.keyNum - 1
+	})
+}
+
+// moveLeaf goes up the path to the first internal node where step can move to a sibling
+// pointer, then goes down that pointer with choose. The cursor becomes invalid
+// if there is no such node.
+func (c *Cursor) moveLeaf(step func(n *node, position int) (int, bool), choose func(n *node) int, leafPosition func(leaf *node) int) bool {
+	for level := len(c.path) - 2; level >= 0; level-- {
+		position, ok := step(c.path[level], c.positions[level])
+		if !ok {
+			continue
+		}
+
+		parent := c.path[level]
+		c.path, c.positions = c.path[:level], c.positions[:level]
+		c.path = append(c.path, parent)
+		c.positions = append(c.positions, position)
+
+		nextID := parent.pointers[position].asNodeID()
+		next, err := c.tree.storage.loadNodeByID(nextID)
+		if err != nil {
+			c.err = fmt.Errorf("failed to load the sibling node %d: %w", nextID, err)
+			c.valid = false
+
+			return false
+		}
+
+		if !c.down(next, choose) {
+			return false
+		}
+
+		leaf, _ := c.leaf()
+		c.positions[len(c.positions)-1] = leafPosition(leaf)
+		c.valid = leaf.keyNum > 0
+
+		return c.valid
+	}
+
+	c.valid = false
+
+	return false
+}
+
+// leaf returns the current leaf and the key position in it.
+func (c *Cursor) leaf() (*node, int) {
+	last := len(c.path) - 1
+
+	return c.path[last], c.positions[last]
+}
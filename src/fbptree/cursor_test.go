@@ -0,0 +1,97 @@
+package fbptree
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"path"
+	"testing"
+)
+
+// opens a tree of the given order in a temporary directory
+func openTestTree(t *testing.T, order int) *FBPTree {
+	t.Helper()
+
+	tree, err := Open(path.Join(t.TempDir(), "test.db"), Order(order))
+	if err != nil {
+		t.Fatalf("failed to open the tree: %s", err)
+	}
+	t.Cleanup(func() { tree.Close() })
+	return tree
+}
+
+// returns the key i, the keys sort in the order of i
+func testKey(i int) []byte {
+	return []byte(fmt.Sprintf("%05d", i))
+}
+
+// puts the even keys from 0 to 2*(count-1) in a random order
+func putEvenKeys(t *testing.T, tree *FBPTree, count int) {
+	t.Helper()
+
+	for _, i := range rand.Perm(count) {
+		if _, _, err := tree.Put(testKey(2*i), testKey(2*i)); err != nil {
+			t.Fatalf("failed to put %d: %s", 2*i, err)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	tree := openTestTree(t, 4)
+	putEvenKeys(t, tree, 200)
+
+	c := tree.Cursor()
+	i := 0
+	for ok := c.Seek(nil); ok; ok = c.Next() {
+		if !bytes.Equal(c.Key(), testKey(2*i)) || !bytes.Equal(c.Value(), testKey(2*i)) {
+			t.Fatalf("got key %s at %d, expected %s", c.Key(), i, testKey(2*i))
+		}
+		i++
+	}
+	if i != 200 || c.Valid() || c.Err() != nil {
+		t.Fatalf("the forward scan read %d keys (valid %v, err %v), expected 200", i, c.Valid(), c.Err())
+	}
+
+	i = 199
+	for ok := c.SeekLast(); ok; ok = c.Prev() {
+		if !bytes.Equal(c.Key(), testKey(2*i)) {
+			t.Fatalf("got key %s at %d, expected %s", c.Key(), i, testKey(2*i))
+		}
+		i--
+	}
+	if i != -1 || c.Err() != nil {
+		t.Fatalf("the backward scan stopped at %d (err %v), expected -1", i, c.Err())
+	}
+}
+
+func TestCursorSeek(t *testing.T) {
+	tree := openTestTree(t, 4)
+	putEvenKeys(t, tree, 200)
+
+	c := tree.Cursor()
+	for i := 0; i < 399; i++ {
+		// an odd key is not in the tree, the cursor stops at the next even one
+		expected := testKey(i + i%2)
+		if !c.Seek(testKey(i)) || !bytes.Equal(c.Key(), expected) {
+			t.Fatalf("Seek(%d) is at %s, expected %s", i, c.Key(), expected)
+		}
+	}
+
+	if c.Seek(testKey(399)) {
+		t.Fatalf("Seek after the last key is at %s", c.Key())
+	}
+
+	// the cursor moves back from a seek in the middle of the tree
+	if !c.Seek(testKey(101)) || !c.Prev() || !bytes.Equal(c.Key(), testKey(100)) {
+		t.Fatal("Prev after Seek(101) is not at 100")
+	}
+}
+
+func TestCursorOnEmptyTree(t *testing.T) {
+	tree := openTestTree(t, 4)
+
+	c := tree.Cursor()
+	if c.Seek(nil) || c.SeekLast() || c.Valid() {
+		t.Fatal("the cursor of an empty tree must not be valid")
+	}
+}
@@ -0,0 +1,82 @@
+package fbptree
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+)
+
+// tests of the additions of the fork, the tests of the upstream library are in the other files
+
+// countingFile counts the writes made to the file of the tree
+type countingFile struct {
+	*os.File
+	writes *int
+}
+
+func (f countingFile) WriteAt(data []byte, offset int64) (int, error) {
+	*f.writes++
+	return f.File.WriteAt(data, offset)
+}
+
+func TestOpenFile(t *testing.T) {
+	dbPath := path.Join(t.TempDir(), "test.db")
+
+	writes := 0
+	var opened string
+	open := func(name string, flag int, perm os.FileMode) (File, error) {
+		opened = name
+		file, err := os.OpenFile(name, flag, perm)
+		if err != nil {
+			return nil, err
+		}
+		return countingFile{File: file, writes: &writes}, nil
+	}
+
+	tree, err := Open(dbPath, OpenFile(open))
+	if err != nil {
+		t.Fatalf("failed to open the tree: %s", err)
+	}
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		if _, _, err := tree.Put(key, key); err != nil {
+			t.Fatalf("failed to put %s: %s", key, err)
+		}
+	}
+	if err := tree.Close(); err != nil {
+		t.Fatalf("failed to close the tree: %s", err)
+	}
+
+	if opened != dbPath {
+		t.Fatalf("the tree opened %s, expected %s", opened, dbPath)
+	}
+	if writes == 0 {
+		t.Fatal("the writes of the tree did not go through the file of the option")
+	}
+
+	// the file written through the option is a regular tree file
+	tree, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open the tree again: %s", err)
+	}
+	defer tree.Close()
+
+	if tree.Size() != 100 {
+		t.Fatalf("the tree has %d keys, expected 100", tree.Size())
+	}
+	value, ok, err := tree.Get([]byte("key042"))
+	if err != nil || !ok || string(value) != "key042" {
+		t.Fatalf("Get(key042) = %q, %v, %v", value, ok, err)
+	}
+}
+
+func TestOpenFileReturnsAnError(t *testing.T) {
+	open := func(name string, flag int, perm os.FileMode) (File, error) {
+		return nil, fmt.Errorf("some error")
+	}
+
+	if _, err := Open(path.Join(t.TempDir(), "test.db"), OpenFile(open)); err == nil {
+		t.Fatal("must return the error of the open function")
+	}
+}
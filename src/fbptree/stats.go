@@ -0,0 +1,82 @@
+package fbptree
+
+import "fmt"
+
+// Stats describes the shape of the tree.
+type Stats struct {
+	// Height is the number of levels, 0 for an empty tree.
+	Height int
+	// LeafNodes and InternalNodes are the number of nodes of each kind.
+	LeafNodes     int
+	InternalNodes int
+	// Keys is the number of keys in the leaves.
+	Keys int
+	// Fill is the average number of keys per node divided by the
+	// maximum number of keys of a node (order - 1), between 0 and 1.
+	Fill float64
+}
+
+// Stats walks all the nodes of the tree and returns its statistics.
+func (t *FBPTree) Stats() (Stats, error) {
+	var stats Stats
+	if t.metadata == nil {
+		return stats, nil
+	}
+
+	keyNum := 0
+	level := []uint32{t.metadata.rootID}
+	for len(level) > 0 {
+		stats.Height++
+
+		next := make([]uint32, 0)
+		for _, nodeID := range level {
+			n, err := t.storage.loadNodeByID(nodeID)
+			if err != nil {
+				return Stats{}, fmt.Errorf("failed to load the node %d: %w", nodeID, err)
+			}
+
+			keyNum += n.keyNum
+			if n.leaf {
+				stats.LeafNodes++
+				stats.Keys += n.keyNum
+
+				continue
+			}
+
+			stats.InternalNodes++
+			for i := 0; i <= n.keyNum; i++ {
+				next = append(next, n.pointers[i].asNodeID())
+			}
+		}
+
+		level = next
+	}
+
+	nodes := stats.LeafNodes + stats.InternalNodes
+	stats.Fill = float64(keyNum) / float64(nodes*(t.order-1))
+
+	return stats, nil
+}
+
+// Height returns the number of levels of the tree, 0 for an empty tree.
+// All the leaves are on the same level, so only the leftmost path is read.
+func (t *FBPTree) Height() (int, error) {
+	if t.metadata == nil {
+		return 0, nil
+	}
+
+	height := 0
+	nodeID := t.metadata.rootID
+	for {
+		n, err := t.storage.loadNodeByID(nodeID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load the node %d: %w", nodeID, err)
+		}
+
+		height++
+		if n.leaf {
+			return height, nil
+		}
+		nodeID = n.pointers[0].asNodeID()
+	}
+}
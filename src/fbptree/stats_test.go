@@ -0,0 +1,34 @@
+package fbptree
+
+import (
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	tree := openTestTree(t, 4)
+
+	stats, err := tree.Stats()
+	if err != nil || stats != (Stats{}) {
+		t.Fatalf("the stats of an empty tree are %+v, %v", stats, err)
+	}
+
+	putEvenKeys(t, tree, 500)
+	checkTreeKeys(t, tree, 500)
+
+	stats, _ = tree.Stats()
+	if stats.Height < 2 || stats.LeafNodes == 0 || stats.InternalNodes == 0 {
+		t.Fatalf("unexpected shape %+v for 500 keys of order 4", stats)
+	}
+	if stats.Fill <= 0 || stats.Fill > 1 {
+		t.Fatalf("the fill is %v, expected a value between 0 and 1", stats.Fill)
+	}
+
+	for i := 0; i < 500; i++ {
+		if _, _, err := tree.Delete(testKey(2 * i)); err != nil {
+			t.Fatalf("failed to delete %d: %s", 2*i, err)
+		}
+	}
+	if stats, err := tree.Stats(); err != nil || stats.Keys != 0 {
+		t.Fatalf("the stats of the emptied tree are %+v, %v", stats, err)
+	}
+}
@@ -0,0 +1,562 @@
+// this is hashindex package main file, an on-disk extendible hash table used by the hash indexes.
+// the keys are hashed with fnv-1a (64 bits), the low bits of the hash select a slot of the directory
+// and the slot holds the page of the bucket of the key. a full bucket is split in two with one more bit
+// of the hash, and the directory doubles when the bucket already uses as many bits as the directory.
+// a key can have many values, so the entries of a full bucket can all have the same hash,
+// that bucket gets an overflow page instead of being split.
+//
+// the pages of the file are never freed: the buckets are not merged when their entries are removed,
+// and the old directory is left behind when it doubles. the index is rebuilt to get the space back.
+
+package hashindex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// file:
+// | Header page | Directory pages | Bucket pages ... |
+// the new pages are added at the end of the file.
+//
+// header:
+// | Magic 4B | PageSize 4B | GlobalDepth 4B | DirectoryPage 4B | PageCount 4B | Size 8B |
+//
+// directory: 2^GlobalDepth page ids of 4B, on the pages that start at DirectoryPage
+//
+// bucket page:
+// | LocalDepth 4B | EntryCount 4B | OverflowPage 4B | Entries ... |
+// entry: | KeySize 2B | ValueSize 2B | Key | Value |
+const (
+	magic            = 0x53484958
+	headerSize       = 28
+	bucketHeaderSize = 12
+	entryHeaderSize  = 4
+
+	defaultPageSize = 4096
+	minPageSize     = 256
+	maxPageSize     = 1 << 16
+	// the directory stops doubling past this depth, the full buckets get overflow pages instead
+	maxGlobalDepth = 30
+)
+
+// File is the file the hash index is stored in, *os.File implements it.
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+
+	Sync() error
+	Stat() (fs.FileInfo, error)
+}
+
+type config struct {
+	pageSize int
+	openFile func(path string, flag int, perm os.FileMode) (File, error)
+}
+
+// PageSize option sets the page size of a new index, an existing index must be opened with its page size.
+func PageSize(pageSize int) func(*config) error {
+	return func(c *config) error {
+		if pageSize < minPageSize || pageSize > maxPageSize {
+			return fmt.Errorf("page size must be between %d and %d", minPageSize, maxPageSize)
+		}
+
+		c.pageSize = pageSize
+		return nil
+	}
+}
+
+// OpenFile option replaces os.OpenFile for opening the file of the index,
+// all the reads and writes of the index go through the returned file.
+func OpenFile(open func(path string, flag int, perm os.FileMode) (File, error)) func(*config) error {
+	return func(c *config) error {
+		c.openFile = open
+		return nil
+	}
+}
+
+// HashIndex is an on-disk hash table from keys to values, a key can have many values.
+type HashIndex struct {
+	file     File
+	pageSize int
+
+	globalDepth   uint32
+	directoryPage uint32
+	pageCount     uint32
+	size          uint64
+}
+
+type entry struct {
+	key, value []byte
+}
+
+// a page of a bucket, the first page of the bucket has its local depth
+type bucketPage struct {
+	id         uint32
+	localDepth uint32
+	overflow   uint32
+	entries    []entry
+}
+
+// Open opens the hash index stored in the file at path, the file is created if it does not exist.
+func Open(path string, options ...func(*config) error) (*HashIndex, error) {
+	cfg := &config{pageSize: defaultPageSize, openFile: func(path string, flag int, perm os.FileMode) (File, error) {
+		return os.OpenFile(path, flag, perm)
+	}}
+	for _, option := range options {
+		if err := option(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := cfg.openFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	h := &HashIndex{file: file, pageSize: cfg.pageSize}
+	if err := h.load(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to load the hash index %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// reads the header, or writes the first pages of an empty file:
+// the header, a directory of one slot and its bucket
+func (h *HashIndex) load() error {
+	info, err := h.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() == 0 {
+		h.globalDepth, h.directoryPage, h.pageCount = 0, 1, 3
+		if err := h.writePage(&bucketPage{id: 2}); err != nil {
+			return err
+		}
+		if err := h.writeSlot(0, 2); err != nil {
+			return err
+		}
+		return h.writeHeader()
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := h.file.ReadAt(header, 0); err != nil {
+		return fmt.Errorf("failed to read the header: %w", err)
+	}
+	if binary.BigEndian.Uint32(header[0:4]) != magic {
+		return errors.New("the file is not a hash index")
+	}
+	if pageSize := int(binary.BigEndian.Uint32(header[4:8])); pageSize != h.pageSize {
+		return fmt.Errorf("the index has pages of %d bytes, not %d", pageSize, h.pageSize)
+	}
+
+	h.globalDepth = binary.BigEndian.Uint32(header[8:12])
+	h.directoryPage = binary.BigEndian.Uint32(header[12:16])
+	h.pageCount = binary.BigEndian.Uint32(header[16:20])
+	h.size = binary.BigEndian.Uint64(header[20:28])
+	return nil
+}
+
+// Get returns the values of the key, in no particular order.
+func (h *HashIndex) Get(key []byte) ([][]byte, error) {
+	_, pages, err := h.bucketOf(hash(key))
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]byte, 0)
+	for _, page := range pages {
+		for _, e := range page.entries {
+			if bytes.Equal(e.key, key) {
+				values = append(values, e.value)
+			}
+		}
+	}
+	return values, nil
+}
+
+// Put adds the value to the key, the key keeps its other values.
+func (h *HashIndex) Put(key, value []byte) error {
+	e := entry{key: key, value: value}
+	if entrySize(e) > h.pageSize-bucketHeaderSize {
+		return fmt.Errorf("the entry of %d bytes does not fit in a page of %d bytes", entrySize(e), h.pageSize)
+	}
+
+	keyHash := hash(key)
+	for {
+		slot, pages, err := h.bucketOf(keyHash)
+		if err != nil {
+			return err
+		}
+
+		for _, page := range pages {
+			if page.size()+entrySize(e) <= h.pageSize {
+				page.entries = append(page.entries, e)
+				if err := h.writePage(page); err != nil {
+					return err
+				}
+				return h.setSize(h.size + 1)
+			}
+		}
+
+		if !h.canSplit(pages, keyHash, entrySize(e)) {
+			overflow := &bucketPage{id: h.newPage(), entries: []entry{e}}
+			last := pages[len(pages)-1]
+			last.overflow = overflow.id
+			if err := h.writePage(overflow); err != nil {
+				return err
+			}
+			if err := h.writePage(last); err != nil {
+				return err
+			}
+			return h.setSize(h.size + 1)
+		}
+
+		if err := h.split(slot, pages); err != nil {
+			return err
+		}
+	}
+}
+
+// Delete removes the value of the key, it reports whether the key had the value.
+func (h *HashIndex) Delete(key, value []byte) (bool, error) {
+	_, pages, err := h.bucketOf(hash(key))
+	if err != nil {
+		return false, err
+	}
+
+	for _, page := range pages {
+		for i, e := range page.entries {
+			if bytes.Equal(e.key, key) && bytes.Equal(e.value, value) {
+				page.entries = append(page.entries[:i], page.entries[i+1:]...)
+				if err := h.writePage(page); err != nil {
+					return false, err
+				}
+				return true, h.setSize(h.size - 1)
+			}
+		}
+	}
+	return false, nil
+}
+
+// ForEach calls action for every entry of the index, in no particular order.
+func (h *HashIndex) ForEach(action func(key, value []byte)) error {
+	return h.forEachBucket(func(pages []*bucketPage) {
+		for _, page := range pages {
+			for _, e := range page.entries {
+				action(e.key, e.value)
+			}
+		}
+	})
+}
+
+// Size returns the number of entries of the index.
+func (h *HashIndex) Size() int {
+	return int(h.size)
+}
+
+// Close syncs and closes the file of the index.
+func (h *HashIndex) Close() error {
+	if err := h.file.Sync(); err != nil {
+		h.file.Close()
+		return fmt.Errorf("failed to sync the hash index: %w", err)
+	}
+	return h.file.Close()
+}
+
+// returns the directory slot of the hash and the pages of its bucket
+func (h *HashIndex) bucketOf(keyHash uint64) (uint32, []*bucketPage, error) {
+	slot := uint32(keyHash & (1<<h.globalDepth - 1))
+	pageID, err := h.readSlot(slot)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	pages, err := h.readBucket(pageID)
+	return slot, pages, err
+}
+
+// reads the first page of a bucket and its overflow pages
+func (h *HashIndex) readBucket(pageID uint32) ([]*bucketPage, error) {
+	pages := make([]*bucketPage, 0, 1)
+	for {
+		page, err := h.readPage(pageID)
+		if err != nil {
+			return nil, err
+		}
+
+		pages = append(pages, page)
+		if page.overflow == 0 {
+			return pages, nil
+		}
+		pageID = page.overflow
+	}
+}
+
+// reports whether the bucket is split to make room for the new entry of newSize bytes and the hash keyHash.
+// the entries with the same hash always stay together, so a bucket with a key that has values
+// for more than half a page would be split again and again by the other keys that land in it,
+// it gets overflow pages instead
+func (h *HashIndex) canSplit(pages []*bucketPage, keyHash uint64, newSize int) bool {
+	if pages[0].localDepth >= maxGlobalDepth {
+		return false
+	}
+
+	sizes := map[uint64]int{keyHash: bucketHeaderSize + newSize}
+	for _, page := range pages {
+		for _, e := range page.entries {
+			entryHash := hash(e.key)
+			if sizes[entryHash] == 0 {
+				sizes[entryHash] = bucketHeaderSize
+			}
+			sizes[entryHash] += entrySize(e)
+		}
+	}
+
+	for _, size := range sizes {
+		if size > h.pageSize/2 {
+			return false
+		}
+	}
+	return true
+}
+
+// splits the bucket of the slot in two with the next bit of the hash, the entries with the bit set
+// move to a new bucket and the slots of the directory that have the bit set point to it
+func (h *HashIndex) split(slot uint32, pages []*bucketPage) error {
+	depth := pages[0].localDepth
+	if depth == h.globalDepth {
+		if err := h.doubleDirectory(); err != nil {
+			return err
+		}
+	}
+
+	stay, move := make([]entry, 0), make([]entry, 0)
+	for _, page := range pages {
+		for _, e := range page.entries {
+			if hash(e.key)&(1<<depth) == 0 {
+				stay = append(stay, e)
+			} else {
+				move = append(move, e)
+			}
+		}
+	}
+
+	// the pages of the bucket are reused for the entries that stay, the pages left empty stay in the chain
+	if err := h.fillPages(pages, stay, depth+1); err != nil {
+		return err
+	}
+
+	newPages := []*bucketPage{{id: h.newPage()}}
+	if err := h.fillPages(newPages, move, depth+1); err != nil {
+		return err
+	}
+
+	pattern := slot & (1<<depth - 1)
+	for s := pattern | 1<<depth; s < 1<<h.globalDepth; s += 1 << (depth + 1) {
+		if err := h.writeSlot(s, newPages[0].id); err != nil {
+			return err
+		}
+	}
+	return h.writeHeader()
+}
+
+// writes the entries to the pages of a bucket in order, adding overflow pages when they do not fit
+func (h *HashIndex) fillPages(pages []*bucketPage, entries []entry, localDepth uint32) error {
+	for _, page := range pages {
+		page.localDepth = localDepth
+		page.entries = nil
+	}
+
+	current := 0
+	for _, e := range entries {
+		if pages[current].size()+entrySize(e) > h.pageSize {
+			if current+1 == len(pages) {
+				page := &bucketPage{id: h.newPage(), localDepth: localDepth}
+				pages[current].overflow = page.id
+				pages = append(pages, page)
+			}
+			current++
+		}
+		pages[current].entries = append(pages[current].entries, e)
+	}
+
+	for _, page := range pages {
+		if err := h.writePage(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moves the directory to new pages at the end of the file with twice the slots,
+// the slot s and the slot s + 2^GlobalDepth point to the same bucket
+func (h *HashIndex) doubleDirectory() error {
+	size := 4 << h.globalDepth
+	directory := make([]byte, size, 2*size)
+	if _, err := h.file.ReadAt(directory, h.offset(h.directoryPage)); err != nil {
+		return fmt.Errorf("failed to read the directory: %w", err)
+	}
+	directory = append(directory, directory...)
+
+	directoryPage := h.pageCount
+	h.pageCount += uint32((len(directory) + h.pageSize - 1) / h.pageSize)
+	if _, err := h.file.WriteAt(directory, h.offset(directoryPage)); err != nil {
+		return fmt.Errorf("failed to write the directory: %w", err)
+	}
+
+	h.globalDepth++
+	h.directoryPage = directoryPage
+	return h.writeHeader()
+}
+
+// calls action with the pages of every bucket, once for each bucket
+func (h *HashIndex) forEachBucket(action func(pages []*bucketPage)) error {
+	for slot := uint32(0); slot < 1<<h.globalDepth; slot++ {
+		pageID, err := h.readSlot(slot)
+		if err != nil {
+			return err
+		}
+		pages, err := h.readBucket(pageID)
+		if err != nil {
+			return err
+		}
+
+		// the slots of a bucket of depth d have the same low d bits, the first one is below 2^d
+		if slot < 1<<pages[0].localDepth {
+			action(pages)
+		}
+	}
+	return nil
+}
+
+// returns the id of a new page at the end of the file, the header is written by the caller
+func (h *HashIndex) newPage() uint32 {
+	id := h.pageCount
+	h.pageCount++
+	return id
+}
+
+func (h *HashIndex) setSize(size uint64) error {
+	h.size = size
+	return h.writeHeader()
+}
+
+func (h *HashIndex) writeHeader() error {
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header[0:4], magic)
+	binary.BigEndian.PutUint32(header[4:8], uint32(h.pageSize))
+	binary.BigEndian.PutUint32(header[8:12], h.globalDepth)
+	binary.BigEndian.PutUint32(header[12:16], h.directoryPage)
+	binary.BigEndian.PutUint32(header[16:20], h.pageCount)
+	binary.BigEndian.PutUint64(header[20:28], h.size)
+
+	if _, err := h.file.WriteAt(header, 0); err != nil {
+		return fmt.Errorf("failed to write the header: %w", err)
+	}
+	return nil
+}
+
+func (h *HashIndex) readSlot(slot uint32) (uint32, error) {
+	data := make([]byte, 4)
+	if _, err := h.file.ReadAt(data, h.offset(h.directoryPage)+int64(slot)*4); err != nil {
+		return 0, fmt.Errorf("failed to read the directory slot %d: %w", slot, err)
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+func (h *HashIndex) writeSlot(slot uint32, pageID uint32) error {
+	data := binary.BigEndian.AppendUint32(nil, pageID)
+	if _, err := h.file.WriteAt(data, h.offset(h.directoryPage)+int64(slot)*4); err != nil {
+		return fmt.Errorf("failed to write the directory slot %d: %w", slot, err)
+	}
+	return nil
+}
+
+func (h *HashIndex) readPage(id uint32) (*bucketPage, error) {
+	data := make([]byte, h.pageSize)
+	if _, err := h.file.ReadAt(data, h.offset(id)); err != nil {
+		return nil, fmt.Errorf("failed to read the page %d: %w", id, err)
+	}
+
+	page := &bucketPage{
+		id:         id,
+		localDepth: binary.BigEndian.Uint32(data[0:4]),
+		overflow:   binary.BigEndian.Uint32(data[8:12]),
+	}
+
+	count := int(binary.BigEndian.Uint32(data[4:8]))
+	page.entries = make([]entry, 0, count)
+	offset := bucketHeaderSize
+	for i := 0; i < count; i++ {
+		if offset+entryHeaderSize > len(data) {
+			return nil, fmt.Errorf("the page %d is corrupted", id)
+		}
+		keySize := int(binary.BigEndian.Uint16(data[offset:]))
+		valueSize := int(binary.BigEndian.Uint16(data[offset+2:]))
+		offset += entryHeaderSize
+		if offset+keySize+valueSize > len(data) {
+			return nil, fmt.Errorf("the page %d is corrupted", id)
+		}
+
+		page.entries = append(page.entries, entry{
+			key:   data[offset : offset+keySize],
+			value: data[offset+keySize : offset+keySize+valueSize],
+		})
+		offset += keySize + valueSize
+	}
+	return page, nil
+}
+
+func (h *HashIndex) writePage(page *bucketPage) error {
+	data := make([]byte, bucketHeaderSize, h.pageSize)
+	binary.BigEndian.PutUint32(data[0:4], page.localDepth)
+	binary.BigEndian.PutUint32(data[4:8], uint32(len(page.entries)))
+	binary.BigEndian.PutUint32(data[8:12], page.overflow)
+
+	for _, e := range page.entries {
+		data = binary.BigEndian.AppendUint16(data, uint16(len(e.key)))
+		data = binary.BigEndian.AppendUint16(data, uint16(len(e.value)))
+		data = append(data, e.key...)
+		data = append(data, e.value...)
+	}
+	// the page is written whole so the file always ends with a full page
+	data = data[:h.pageSize]
+
+	if _, err := h.file.WriteAt(data, h.offset(page.id)); err != nil {
+		return fmt.Errorf("failed to write the page %d: %w", page.id, err)
+	}
+	return nil
+}
+
+func (h *HashIndex) offset(pageID uint32) int64 {
+	return int64(pageID) * int64(h.pageSize)
+}
+
+// the bytes used by the entries of the page and its header
+func (p *bucketPage) size() int {
+	size := bucketHeaderSize
+	for _, e := range p.entries {
+		size += entrySize(e)
+	}
+	return size
+}
+
+func entrySize(e entry) int {
+	return entryHeaderSize + len(e.key) + len(e.value)
+}
+
+func hash(key []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(key)
+	return h.Sum64()
+}
@@ -0,0 +1,228 @@
+package hashindex
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+)
+
+// the indexes of the tests are created in the working directory, the tests run in a temporary one
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "hashindex")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// opens the index with the smallest pages, so a few entries split the buckets
+func openTestIndex(t *testing.T, path string) *HashIndex {
+	t.Helper()
+
+	h, err := Open(path, PageSize(minPageSize))
+	if err != nil {
+		t.Fatalf("failed to open %s: %s", path, err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func put(t *testing.T, h *HashIndex, key, value string) {
+	t.Helper()
+
+	if err := h.Put([]byte(key), []byte(value)); err != nil {
+		t.Fatalf("failed to put %s=%s: %s", key, value, err)
+	}
+}
+
+// checks the values of the key, in any order
+func checkGet(t *testing.T, h *HashIndex, key string, expected ...string) {
+	t.Helper()
+
+	values, err := h.Get([]byte(key))
+	if err != nil {
+		t.Fatalf("failed to get %s: %s", key, err)
+	}
+	found := make([]string, 0, len(values))
+	for _, value := range values {
+		found = append(found, string(value))
+	}
+	slices.Sort(found)
+	slices.Sort(expected)
+	if !slices.Equal(found, expected) {
+		t.Fatalf("%s has the values %v, expected %v", key, found, expected)
+	}
+}
+
+func stats(t *testing.T, h *HashIndex) Stats {
+	t.Helper()
+
+	s, err := h.Stats()
+	if err != nil {
+		t.Fatalf("failed to read the stats: %s", err)
+	}
+	return s
+}
+
+func TestPutGetDelete(t *testing.T) {
+	h := openTestIndex(t, "basic")
+
+	put(t, h, "a", "1")
+	put(t, h, "b", "2")
+	put(t, h, "a", "3")
+	checkGet(t, h, "a", "1", "3")
+	checkGet(t, h, "b", "2")
+	checkGet(t, h, "c")
+
+	deleted, err := h.Delete([]byte("a"), []byte("1"))
+	if err != nil || !deleted {
+		t.Fatalf("failed to delete a=1: %v %v", deleted, err)
+	}
+	deleted, err = h.Delete([]byte("a"), []byte("1"))
+	if err != nil || deleted {
+		t.Fatalf("a=1 was deleted twice: %v %v", deleted, err)
+	}
+	deleted, err = h.Delete([]byte("c"), []byte("1"))
+	if err != nil || deleted {
+		t.Fatalf("a missing key was deleted: %v %v", deleted, err)
+	}
+	checkGet(t, h, "a", "3")
+	if h.Size() != 2 {
+		t.Fatalf("the index has %d entries, expected 2", h.Size())
+	}
+
+	// the entries and the size are read from the file
+	if err := h.Close(); err != nil {
+		t.Fatalf("failed to close the index: %s", err)
+	}
+	h = openTestIndex(t, "basic")
+	checkGet(t, h, "a", "3")
+	checkGet(t, h, "b", "2")
+	if h.Size() != 2 {
+		t.Fatalf("the reopened index has %d entries, expected 2", h.Size())
+	}
+
+	entries := make(map[string]string)
+	if err := h.ForEach(func(key, value []byte) { entries[string(key)] = string(value) }); err != nil {
+		t.Fatalf("failed to read the entries: %s", err)
+	}
+	if len(entries) != 2 || entries["a"] != "3" || entries["b"] != "2" {
+		t.Fatalf("the index has the entries %v", entries)
+	}
+}
+
+func TestOpenOptions(t *testing.T) {
+	if _, err := Open("options", PageSize(minPageSize-1)); err == nil {
+		t.Fatal("a page size below the minimum must be refused")
+	}
+
+	h := openTestIndex(t, "options")
+	if err := h.Put(make([]byte, minPageSize), []byte("1")); err == nil {
+		t.Fatal("an entry bigger than a page must be refused")
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("failed to close the index: %s", err)
+	}
+
+	if _, err := Open("options"); err == nil {
+		t.Fatal("an index must be opened with its page size")
+	}
+	if err := os.WriteFile("notanindex", []byte("some other content of the file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open("notanindex", PageSize(minPageSize)); err == nil {
+		t.Fatal("a file that is not a hash index must be refused")
+	}
+}
+
+// the full buckets are split and the directory doubles, the entries are found in their new buckets
+func TestSplit(t *testing.T) {
+	h := openTestIndex(t, "split")
+
+	for i := 0; i < 500; i++ {
+		put(t, h, fmt.Sprintf("key%d", i), fmt.Sprint(i))
+	}
+	for i := 0; i < 500; i += 50 {
+		checkGet(t, h, fmt.Sprintf("key%d", i), fmt.Sprint(i))
+	}
+
+	s := stats(t, h)
+	if s.GlobalDepth < 4 || s.Buckets < 1<<(s.GlobalDepth-1) || s.Buckets > 1<<s.GlobalDepth {
+		t.Fatalf("500 keys left a directory of depth %d with %d buckets", s.GlobalDepth, s.Buckets)
+	}
+
+	// the directory is read from its new pages after a reopen
+	if err := h.Close(); err != nil {
+		t.Fatalf("failed to close the index: %s", err)
+	}
+	h = openTestIndex(t, "split")
+	for i := 0; i < 500; i++ {
+		checkGet(t, h, fmt.Sprintf("key%d", i), fmt.Sprint(i))
+	}
+	if reopened := stats(t, h); reopened != s {
+		t.Fatalf("the reopened index has the stats %+v, expected %+v", reopened, s)
+	}
+}
+
+// the values of a key that do not fit in a page go to overflow pages instead of splitting the bucket again and again
+func TestDuplicateKeys(t *testing.T) {
+	h := openTestIndex(t, "duplicates")
+
+	values := make([]string, 100)
+	for i := range values {
+		values[i] = fmt.Sprintf("value%d", i)
+		put(t, h, "same", values[i])
+	}
+	put(t, h, "other", "1")
+	checkGet(t, h, "same", values...)
+	checkGet(t, h, "other", "1")
+
+	s := stats(t, h)
+	if s.Pages <= s.Buckets {
+		t.Fatalf("the values of the key did not get overflow pages, %d pages for %d buckets", s.Pages, s.Buckets)
+	}
+	if s.GlobalDepth > 2 {
+		t.Fatalf("the values of one key doubled the directory to depth %d", s.GlobalDepth)
+	}
+
+	// a value is deleted from the overflow page it is on
+	deleted, err := h.Delete([]byte("same"), []byte(values[99]))
+	if err != nil || !deleted {
+		t.Fatalf("failed to delete the last value: %v %v", deleted, err)
+	}
+	checkGet(t, h, "same", values[:99]...)
+}
+
+func TestStats(t *testing.T) {
+	h := openTestIndex(t, "stats")
+
+	s := stats(t, h)
+	if s.GlobalDepth != 0 || s.Buckets != 1 || s.Pages != 1 || s.Keys != 0 || s.DistinctKeys != 0 {
+		t.Fatalf("the empty index has the stats %+v", s)
+	}
+
+	for i := 0; i < 60; i++ {
+		put(t, h, fmt.Sprintf("key%d", i%20), fmt.Sprint(i))
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := h.Delete([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("failed to delete: %s", err)
+		}
+	}
+
+	s = stats(t, h)
+	if s.Keys != 50 || s.Keys != h.Size() || s.DistinctKeys != 20 {
+		t.Fatalf("the index has %d entries of %d keys and a size of %d, expected 50 entries of 20 keys",
+			s.Keys, s.DistinctKeys, h.Size())
+	}
+	if s.Fill <= 0 || s.Fill > 1 {
+		t.Fatalf("the index has a fill of %f", s.Fill)
+	}
+}
@@ -0,0 +1,44 @@
+package hashindex
+
+// Stats describes the shape of the hash index.
+type Stats struct {
+	GlobalDepth int
+	Buckets     int
+	// the pages of the buckets, with their overflow pages
+	Pages int
+	// the entries, and the different keys among them
+	Keys         int
+	DistinctKeys int
+	// the bytes used by the entries of the pages divided by the bytes of the pages, between 0 and 1
+	Fill float64
+}
+
+// Stats reads all the buckets of the index and returns its statistics.
+func (h *HashIndex) Stats() (Stats, error) {
+	stats := Stats{GlobalDepth: int(h.globalDepth)}
+
+	used := 0
+	err := h.forEachBucket(func(pages []*bucketPage) {
+		stats.Buckets++
+
+		// the entries of a key are all in its bucket
+		keys := make(map[string]struct{})
+		for _, page := range pages {
+			stats.Pages++
+			used += page.size()
+			for _, e := range page.entries {
+				keys[string(e.key)] = struct{}{}
+			}
+			stats.Keys += len(page.entries)
+		}
+		stats.DistinctKeys += len(keys)
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	if stats.Pages > 0 {
+		stats.Fill = float64(used) / float64(stats.Pages*h.pageSize)
+	}
+	return stats, nil
+}
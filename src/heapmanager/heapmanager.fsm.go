@@ -0,0 +1,125 @@
+package heapmanager
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// the free space map (fsm) of a heap has one byte per heap page holding the approximate
+// free space of the page in units of fsmCategorySize bytes, so inserts can find a page
+// with enough room without reading the pages.
+//
+// the maps are kept in memory and never written to the disk. the map of a heap is built from
+// its pages (through the buffer pool) the first time an insert needs it, which is after the log
+// is recovered since every insert runs in a transaction. every page write updates the entry of
+// its page, including the writes of a rollback and of recovery, so the map follows the pages.
+const fsmCategorySize = pageSize / 256
+
+var (
+	fsmMutex sync.Mutex
+	// the maps of the heaps by the absolute path of their file, see fsmKey
+	freeSpaceMaps = make(map[string][]byte)
+)
+
+// returns the key of the map of the heap file, the log names the files by their absolute path
+func fsmKey(name string) string {
+	if path, err := filepath.Abs(name); err == nil {
+		return path
+	}
+	return name
+}
+
+// rounds the free space down, so a page is never reported with more room than it has
+func freeSpaceCategory(freeSpace int) byte {
+	category := freeSpace / fsmCategorySize
+	if category > 255 {
+		category = 255
+	}
+	return byte(category)
+}
+
+// returns the free bytes of the page as tracked by the free space map
+// overflow pages have no room for rows
+func pageFreeSpace(page []byte) int {
+	header := parsePageHeader(page)
+	if header.pageType != pageTypeData {
+		return 0
+	}
+	return int(header.freeSpace)
+}
+
+// returns the map of the heap with an entry for each of its pageCount pages,
+// the entries it does not have yet are read from the pages.
+// it must be called with fsmMutex locked
+func loadFreeSpaceMap(file *os.File, pageCount int) ([]byte, error) {
+	key := fsmKey(file.Name())
+	categories := freeSpaceMaps[key]
+	for pageIndex := len(categories); pageIndex < pageCount; pageIndex++ {
+		page, err := getPageFromHeap(file, pageIndex)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, freeSpaceCategory(pageFreeSpace(page)))
+	}
+	freeSpaceMaps[key] = categories
+	return categories, nil
+}
+
+// returns the first page from start on that the free space map reports with at least required free bytes,
+// or -1 if there is none.
+func findPageWithFreeSpace(file *os.File, required int, start int) (int, error) {
+	header := make([]byte, heapHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return 0, err
+	}
+	pageCount, _ := parseHeapHeader(header)
+
+	fsmMutex.Lock()
+	defer fsmMutex.Unlock()
+
+	categories, err := loadFreeSpaceMap(file, int(pageCount))
+	if err != nil {
+		return 0, err
+	}
+
+	//round the required space up, the categories are rounded down
+	minCategory := (required + fsmCategorySize - 1) / fsmCategorySize
+
+	//the map may have entries for pages that are not in the header, after a rollback of an append
+	for pageIndex := start; pageIndex < int(pageCount); pageIndex++ {
+		if int(categories[pageIndex]) >= minCategory {
+			return pageIndex, nil
+		}
+	}
+	return -1, nil
+}
+
+// writes the free space of the page to its entry in the free space map of the heap
+func updateFreeSpaceMap(file *os.File, pageIndex int, page []byte) {
+	fsmMutex.Lock()
+	defer fsmMutex.Unlock()
+
+	key := fsmKey(file.Name())
+	categories, ok := freeSpaceMaps[key]
+	if !ok {
+		return
+	}
+
+	//the entries of the pages after the map are read from the pages when it is loaded
+	category := freeSpaceCategory(pageFreeSpace(page))
+	switch {
+	case pageIndex < len(categories):
+		categories[pageIndex] = category
+	case pageIndex == len(categories):
+		freeSpaceMaps[key] = append(categories, category)
+	}
+}
+
+// drops the free space map of the heap, its pages are read again when it is needed
+func dropFreeSpaceMap(name string) {
+	fsmMutex.Lock()
+	defer fsmMutex.Unlock()
+
+	delete(freeSpaceMaps, fsmKey(name))
+}
@@ -0,0 +1,61 @@
+package heapmanager
+
+import (
+	"os"
+	"testing"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// the free space map follows the pages through a rollback, and is built again from the pages after a restart
+func TestFreeSpaceMap(t *testing.T) {
+	createTestHeap(t, "fsm")
+
+	rows := make(map[RID][]byte)
+	for i := 0; i < 4; i++ {
+		rows[addRow(t, "fsm", testRow(byte(i+1), 1000))] = testRow(byte(i+1), 1000)
+	}
+
+	// the rows added by a rolled back transaction are gone, their page has room again
+	tx, err := logmanager.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %s", err)
+	}
+	for i := 0; i < 12; i++ {
+		if _, err := AddRowToHeapTx(tx, "fsm", testRow(0xA0, 1000)); err != nil {
+			t.Fatalf("failed to add a row: %s", err)
+		}
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to roll back: %s", err)
+	}
+	firstPage := make([]RID, 0)
+	for i := 0; i < 12; i++ {
+		rid := addRow(t, "fsm", testRow(byte(i+5), 1000))
+		rows[rid] = testRow(byte(i+5), 1000)
+		if rid.PageID == 0 {
+			firstPage = append(firstPage, rid)
+		}
+	}
+	if len(firstPage) == 0 {
+		t.Fatal("no row was added to page 0, the map kept the rows of the rolled back transaction")
+	}
+
+	// the map is not on the disk, a new process reads the room of the pages
+	for _, rid := range firstPage {
+		if err := DeleteRowFromHeap("fsm", rid); err != nil {
+			t.Fatalf("failed to delete row %v: %s", rid, err)
+		}
+		delete(rows, rid)
+	}
+	reopenHeap(t, "fsm")
+	if _, err := os.Stat("fsm.fsm"); !os.IsNotExist(err) {
+		t.Fatalf("the free space map was written to the disk: %v", err)
+	}
+	rid := addRow(t, "fsm", testRow(0xA2, 1000))
+	if rid.PageID != 0 {
+		t.Fatalf("the row was added to page %d, expected page 0 whose rows were deleted", rid.PageID)
+	}
+	rows[rid] = testRow(0xA2, 1000)
+	checkRows(t, "fsm", rows)
+}
@@ -1,4 +1,3 @@
-
 package heapmanager
 
 import (
@@ -6,12 +5,35 @@ import (
 	"errors"
 	"fmt"
 	"os"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/buffermanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
 )
 
 const (
 	pageSize       = 8192
-	pageHeaderSize = 4
+	pageHeaderSize = 18
 	heapHeaderSize = 8
+	slotSize       = 8
+	// every record takes at least RIDSize bytes in the page,
+	// so that it can always be replaced by a forwarding pointer in place
+	minRecordSize = RIDSize
+)
+
+// slot flags
+const (
+	// the record is the RID of the row in another page
+	slotForwarded uint16 = 1 << 0
+	// the record was moved here from another page, its RID is the one of the forwarding slot
+	slotRelocated uint16 = 1 << 1
+	// the record is a pointer to the overflow pages that hold the row
+	slotOverflow uint16 = 1 << 2
+)
+
+// page types
+const (
+	pageTypeData     uint16 = 0
+	pageTypeOverflow uint16 = 1
 )
 
 func CreateHeap(name string) error {
@@ -21,11 +43,25 @@ func CreateHeap(name string) error {
 	// 	return errors.New("file already exists")
 	// }
 
+	// the log may still have changes of an old heap with the same name,
+	// they are applied to the old file first so recovery never replays them on the new one
+	if err := logmanager.Checkpoint(); err != nil {
+		return err
+	}
+
+	// the pages of an old heap with the same name must not be served from the buffer pool
+	if err := buffermanager.Default().DropFile(name); err != nil {
+		return err
+	}
+
 	file, err := os.Create(name)
 	if err != nil {
 		return err
 	}
 
+	// the free space map of an old heap with the same name must not be used either
+	dropFreeSpaceMap(name)
+
 	// set the file permission to 0644
 	if err := file.Chmod(0644); err != nil {
 		return err
@@ -33,104 +69,245 @@ func CreateHeap(name string) error {
 
 	defer file.Close()
 
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
+	}
+
+	return tx.Finish(initializeHeap(tx, file))
+}
+
+// DeleteHeap removes the heap file with name = name.
+func DeleteHeap(name string) error {
+	// the log may still have changes of the heap, they are applied before it is removed
+	// so recovery never replays them on a new heap with the same name
+	if err := logmanager.Checkpoint(); err != nil {
+		return err
+	}
+	if err := buffermanager.Default().DropFile(name); err != nil {
+		return err
+	}
+
+	dropFreeSpaceMap(name)
+
+	if err := os.Remove(name); err != nil {
+		return fmt.Errorf("failed to delete heap %s: %w", name, err)
+	}
+	return nil
+}
+
+// RenameHeap renames the heap file oldName to newName,
+// a heap with the new name must not exist.
+func RenameHeap(oldName string, newName string) error {
+	if _, err := os.Stat(newName); err == nil {
+		return fmt.Errorf("heap %s already exists", newName)
+	}
+
+	// the log names the files it changes, its changes of the heap are applied before the file gets a new name
+	if err := logmanager.Checkpoint(); err != nil {
+		return err
+	}
+	if err := buffermanager.Default().DropFile(oldName); err != nil {
+		return err
+	}
+
+	// the free space map is built again from the pages of the new name
+	dropFreeSpaceMap(oldName)
+
+	if err := os.Rename(oldName, newName); err != nil {
+		return fmt.Errorf("failed to rename heap %s: %w", oldName, err)
+	}
+	return nil
+}
+
+// writes the header and the first page of a new heap
+func initializeHeap(tx *logmanager.Transaction, file *os.File) error {
 	header := make([]byte, heapHeaderSize)
-	if _, err := file.WriteAt(header, 0); err != nil {
+	if err := tx.OverwriteAt(file, header, 0); err != nil {
 		return err
 	}
-	file.Sync()
 
 	//create the first page and write it to the file
 	page := createPage()
-	appendPageToHeap(file, page)
-	return nil
+	_, err := appendPageToHeap(tx, file, page)
+	return err
 }
 
 // adds a new row to the heap with name.
-// TODO : should return the page number where the row was added.
-func AddRowToHeap(name string, row []byte) {
-	
+// returns the RID (page number and slot number) where the row was added.
+func AddRowToHeap(name string, row []byte) (RID, error) {
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return RID{}, err
+	}
+
+	rid, err := addRowToHeap(tx, name, row)
+	if err := tx.Finish(err); err != nil {
+		return RID{}, err
+	}
+	return rid, nil
+}
 
+// AddRowToHeapTx adds the row as part of the transaction tx, the row is kept only if tx commits.
+// if it fails its changes are undone and tx stays open.
+func AddRowToHeapTx(tx *logmanager.Transaction, name string, row []byte) (RID, error) {
+	savepoint := tx.Savepoint()
+
+	rid, err := addRowToHeap(tx, name, row)
+	if err := tx.EndStatement(savepoint, err); err != nil {
+		return RID{}, err
+	}
+	return rid, nil
+}
+
+func addRowToHeap(tx *logmanager.Transaction, name string, row []byte) (RID, error) {
 	file, err := os.OpenFile(name, os.O_RDWR, 0644)
 	if err != nil {
-		return
+		return RID{}, err
 	}
+	defer file.Close()
 
-	//read the header in byte slice
-	header := make([]byte, heapHeaderSize)
-	if _, err := file.ReadAt(header, 0); err != nil {
-		return
+	//rows that are too big for a page are stored in overflow pages first
+	record, flags, err := prepareRecord(tx, file, row)
+	if err != nil {
+		return RID{}, err
 	}
 
-	pageCount, _ := parseHeapHeader(header)
-	page, _ := getPageFromHeap(file, int(pageCount-1))
-	freeSpaceOffset, recordCount := parsePageHeader(page)
+	rid, err := addRecord(tx, file, record, flags)
+	if err != nil {
+		return RID{}, err
+	}
 
-	//calculate the free space available in the page
-	freeSpaceAvailable := pageSize - freeSpaceOffset
+	//update the heap header with the new rowCount
+	if err := updateRowCount(tx, file, 1); err != nil {
+		return RID{}, err
+	}
+
+	return rid, nil
+}
 
-	//if the free space available is enough to add the row then go ahead
-	//notice that we add 2 to the length of the row to store the record size
+// updates the row identified by rid in the heap with name = name.
+// the row is overwritten in place when the new version fits in its page,
+// otherwise it is moved to another page and its slot keeps a forwarding pointer
+// to the new location, so rid stays valid.
+func UpdateRowInHeap(name string, rid RID, newRow []byte) error {
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(updateRowInHeap(tx, name, rid, newRow))
+}
 
-	if int(freeSpaceAvailable) >= len(row)+2 {
-		//write the row with its size to the page
-		//update the page header
+// UpdateRowInHeapTx updates the row as part of the transaction tx, the old version is back if tx is rolled back.
+// if it fails its changes are undone and tx stays open.
+func UpdateRowInHeapTx(tx *logmanager.Transaction, name string, rid RID, newRow []byte) error {
+	savepoint := tx.Savepoint()
+	return tx.EndStatement(savepoint, updateRowInHeap(tx, name, rid, newRow))
+}
 
-		//get the record size and convert it to byte slice
-		recordSize := make([]byte, 2)
-		binary.BigEndian.PutUint16(recordSize, uint16(len(row)))
+func updateRowInHeap(tx *logmanager.Transaction, name string, rid RID, newRow []byte) error {
+	file, err := os.OpenFile(name, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	page, err := getRowPage(file, rid)
+	if err != nil {
+		return err
+	}
 
-		//write the record size to the page
-		copy(page[freeSpaceOffset:], recordSize)
+	//rows that are too big for a page are stored in overflow pages first
+	record, flags, err := prepareRecord(tx, file, newRow)
+	if err != nil {
+		return err
+	}
 
-		//write the row to the page
-		copy(page[freeSpaceOffset+2:], row)
+	if getSlotFlags(page, rid.SlotID)&slotForwarded == 0 {
+		//the overflow pages of the old version are freed once it is replaced
+		oldRecord, oldFlags := readRecord(page, rid.SlotID), getSlotFlags(page, rid.SlotID)
 
-		//update the page header
-		freeSpaceOffset += uint16(len(row) + 2)
-		recordCount++
-		binary.BigEndian.PutUint16(page[0:2], freeSpaceOffset)
-		binary.BigEndian.PutUint16(page[2:4], recordCount)
+		if updateRecord(page, rid.SlotID, record, flags) {
+			if err := overWritePageToHeap(tx, file, int(rid.PageID), page); err != nil {
+				return err
+			}
+			return freeRecord(tx, file, oldRecord, oldFlags)
+		}
 
-		//overWrite the page to the file
-		overWritePageToHeap(file, int(pageCount-1), page)
+		//the row does not fit in its page anymore, move it and leave a forwarding pointer
+		target, err := addRecord(tx, file, record, slotRelocated|flags)
+		if err != nil {
+			return err
+		}
 
-		//update the heap header with the new rowCount
-		//read the header in byte slice
-		header = make([]byte, heapHeaderSize)
-		if _, err := file.ReadAt(header, 0); err != nil {
-			return
+		updateRecord(page, rid.SlotID, target.Bytes(), slotForwarded)
+		if err := overWritePageToHeap(tx, file, int(rid.PageID), page); err != nil {
+			return err
 		}
+		return freeRecord(tx, file, oldRecord, oldFlags)
+	}
+
+	//the row was already moved, so the new version goes to the page of the moved row
+	target, err := RIDFromBytes(readRecord(page, rid.SlotID))
+	if err != nil {
+		return err
+	}
 
-		//parse the header
-		_, rowCount := parseHeapHeader(header)
+	targetPage, err := getPageFromHeap(file, int(target.PageID))
+	if err != nil {
+		return err
+	}
 
-		//update the rowCount
-		rowCount++
-		binary.BigEndian.PutUint32(header[4:8], rowCount)
+	oldRecord, oldFlags := readRecord(targetPage, target.SlotID), getSlotFlags(targetPage, target.SlotID)
 
-		//write the header to the file
-		file.WriteAt(header, 0)
-		file.Sync()
+	if updateRecord(targetPage, target.SlotID, record, slotRelocated|flags) {
+		if err := overWritePageToHeap(tx, file, int(target.PageID), targetPage); err != nil {
+			return err
+		}
+		return freeRecord(tx, file, oldRecord, oldFlags)
+	}
 
+	//the new version may fit back in the original page, then the forwarding pointer is not needed anymore
+	if updateRecord(page, rid.SlotID, record, flags) {
+		if err := overWritePageToHeap(tx, file, int(rid.PageID), page); err != nil {
+			return err
+		}
 	} else {
+		//move the row again, the forwarding pointer always points to the latest location
+		//so reading a row never follows more than one pointer
+		newTarget, err := addRecord(tx, file, record, slotRelocated|flags)
+		if err != nil {
+			return err
+		}
 
-		newPage := createPage()
-		//append the new page to the file
-		appendPageToHeap(file, newPage)
+		updateRecord(page, rid.SlotID, newTarget.Bytes(), slotForwarded)
+		if err := overWritePageToHeap(tx, file, int(rid.PageID), page); err != nil {
+			return err
+		}
+	}
 
-		//call the function recursively to add the row to the new page
-		AddRowToHeap(name, row)
+	//remove the old copy of the row, the page is read again since addRecord may have changed it
+	targetPage, err = getPageFromHeap(file, int(target.PageID))
+	if err != nil {
+		return err
 	}
 
+	deleteRecord(targetPage, target.SlotID)
+	if err := overWritePageToHeap(tx, file, int(target.PageID), targetPage); err != nil {
+		return err
+	}
+	return freeRecord(tx, file, oldRecord, oldFlags)
 }
 
 // returns all the rows from the heap with name = name and page index = pageIndex.
+// use HeapScanner to read all the rows of the heap.
 func GetPageRowsFromHeap(name string, pageIndex int) [][]byte {
 	file, err := os.OpenFile(name, os.O_RDWR, 0644)
 
 	if err != nil {
 		return nil
 	}
+	defer file.Close()
 
 	header := make([]byte, heapHeaderSize)
 	if _, err := file.ReadAt(header, 0); err != nil {
@@ -143,7 +320,14 @@ func GetPageRowsFromHeap(name string, pageIndex int) [][]byte {
 	}
 
 	page, _ := getPageFromHeap(file, pageIndex)
-	rows := extractRowsFromPage(page)
+	if parsePageHeader(page).pageType != pageTypeData {
+		return [][]byte{}
+	}
+
+	rows, err := extractRowsFromPage(file, page)
+	if err != nil {
+		return nil
+	}
 
 	return rows
 }
@@ -155,6 +339,7 @@ func GetRowFromHeap(name string, rowIndex int) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
 	// read the header in byte slice
 	header := make([]byte, heapHeaderSize)
@@ -180,23 +365,30 @@ func GetRowFromHeap(name string, rowIndex int) ([]byte, error) {
 			return nil, errors.New("failed to retrieve page")
 		}
 
-		// Parse page header to get the number of records and free space offset
-		_, recordCount := parsePageHeader(page)
+		// Parse page header to get the number of live records in the page
+		pHeader := parsePageHeader(page)
+
+		// overflow pages only hold parts of rows that belong to data pages
+		if pHeader.pageType != pageTypeData {
+			continue
+		}
 
 		// Check if the row is in this page
-		if remainingRows < int(recordCount) {
-			// Calculate the offset of the row within the page
-			rowOffset := pageHeaderSize
-			for i := 0; i < remainingRows; i++ {
-				recordSize := binary.BigEndian.Uint16(page[rowOffset : rowOffset+2])
-				rowOffset += int(recordSize) + 2 // 2 bytes for record size
+		if remainingRows < int(pHeader.recordCount) {
+			// skip the tombstones and the rows moved from other pages,
+			// the slot of the row holds its offset within the page
+			for slotID := uint16(0); slotID < pHeader.slotCount; slotID++ {
+				if !isVisibleSlot(page, slotID) {
+					continue
+				}
+				if remainingRows == 0 {
+					return readRow(file, page, slotID)
+				}
+				remainingRows--
 			}
-			// Extract and return the row
-			recordSize := binary.BigEndian.Uint16(page[rowOffset : rowOffset+2])
-			return page[rowOffset+2 : rowOffset+2+int(recordSize)], nil
 		} else {
 			// Move to the next page
-			remainingRows -= int(recordCount)
+			remainingRows -= int(pHeader.recordCount)
 		}
 	}
 
@@ -204,17 +396,414 @@ func GetRowFromHeap(name string, rowIndex int) ([]byte, error) {
 	return nil, errors.New("row not found")
 }
 
+// deletes the row identified by rid from the heap with name = name.
+// the slot of the row becomes a tombstone that can be reused by later inserts
+// and the space of the row is added to the free space of its page.
+func DeleteRowFromHeap(name string, rid RID) error {
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(deleteRowFromHeap(tx, name, rid))
+}
+
+// DeleteRowFromHeapTx deletes the row as part of the transaction tx, the row is back if tx is rolled back.
+// if it fails its changes are undone and tx stays open.
+func DeleteRowFromHeapTx(tx *logmanager.Transaction, name string, rid RID) error {
+	savepoint := tx.Savepoint()
+	return tx.EndStatement(savepoint, deleteRowFromHeap(tx, name, rid))
+}
 
-// takes a page and returns freeSpaceOffset and recordCount
-func parsePageHeader(page []byte) (uint16, uint16) {
+func deleteRowFromHeap(tx *logmanager.Transaction, name string, rid RID) error {
+	file, err := os.OpenFile(name, os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	page, err := getRowPage(file, rid)
+	if err != nil {
+		return err
+	}
+
+	//the overflow pages of the row are freed after its record is deleted
+	oldRecord, oldFlags := readRecord(page, rid.SlotID), getSlotFlags(page, rid.SlotID)
+
+	//a moved row is deleted from the page it was moved to as well
+	if oldFlags&slotForwarded != 0 {
+		target, err := RIDFromBytes(oldRecord)
+		if err != nil {
+			return err
+		}
+
+		targetPage, err := getPageFromHeap(file, int(target.PageID))
+		if err != nil {
+			return err
+		}
+
+		oldRecord, oldFlags = readRecord(targetPage, target.SlotID), getSlotFlags(targetPage, target.SlotID)
+		deleteRecord(targetPage, target.SlotID)
+		if err := overWritePageToHeap(tx, file, int(target.PageID), targetPage); err != nil {
+			return err
+		}
+	}
+
+	deleteRecord(page, rid.SlotID)
+	if err := overWritePageToHeap(tx, file, int(rid.PageID), page); err != nil {
+		return err
+	}
+
+	if err := freeRecord(tx, file, oldRecord, oldFlags); err != nil {
+		return err
+	}
+
+	return updateRowCount(tx, file, -1)
+}
+
+// returns the row identified by rid from the heap with name = name.
+// only the page that holds the row is read from the file,
+// unless the row was moved to another page by an update.
+func GetRowByRID(name string, rid RID) ([]byte, error) {
+	file, err := os.OpenFile(name, os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	page, err := getRowPage(file, rid)
+	if err != nil {
+		return nil, err
+	}
+
+	return readRow(file, page, rid.SlotID)
+}
+
+// adds the record to the first page that the free space map reports with enough room,
+// or to a new page if there is no such page.
+// the slot of the record gets the given flags.
+// the heap header rowCount is not changed.
+func addRecord(tx *logmanager.Transaction, file *os.File, record []byte, flags uint16) (RID, error) {
+	//the record may need a new slot as well
+	required := allocatedSize(len(record)) + slotSize
+
+	for start := 0; ; {
+		pageIndex, err := findPageWithFreeSpace(file, required, start)
+		if err != nil {
+			return RID{}, err
+		}
+		if pageIndex == -1 {
+			break
+		}
+		start = pageIndex + 1
+
+		page, err := getPageFromHeap(file, pageIndex)
+		if err != nil {
+			return RID{}, err
+		}
+
+		//if the free space of the page is enough to add the record then go ahead
+		//insertRecord reuses dead slots and compacts the page when needed
+		if slotID, ok := insertRecord(page, record, flags); ok {
+			//overWrite the page to the file
+			if err := overWritePageToHeap(tx, file, pageIndex, page); err != nil {
+				return RID{}, err
+			}
+			return RID{PageID: uint32(pageIndex), SlotID: slotID}, nil
+		}
+
+		//the map is approximate, correct the entry of the page and try the next one
+		updateFreeSpaceMap(file, pageIndex, page)
+	}
+
+	//no page has enough room, add the record to a new page
+	page := createPage()
+	slotID, ok := insertRecord(page, record, flags)
+	if !ok {
+		return RID{}, fmt.Errorf("record of size %d does not fit in a page", len(record))
+	}
+
+	pageIndex, err := appendPageToHeap(tx, file, page)
+	if err != nil {
+		return RID{}, err
+	}
+	return RID{PageID: uint32(pageIndex), SlotID: slotID}, nil
+}
+
+// reads the page of the row identified by rid and checks that the row exists.
+// rows that were moved from another page can only be reached through their original rid.
+func getRowPage(file *os.File, rid RID) ([]byte, error) {
+	header := make([]byte, heapHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+
+	pageCount, _ := parseHeapHeader(header)
+	if rid.PageID >= pageCount {
+		return nil, fmt.Errorf("page %d out of range for %s", rid.PageID, rid)
+	}
+
+	page, err := getPageFromHeap(file, int(rid.PageID))
+	if err != nil {
+		return nil, err
+	}
+
+	if parsePageHeader(page).pageType != pageTypeData {
+		return nil, fmt.Errorf("page %d of %s is not a data page", rid.PageID, rid)
+	}
+
+	if rid.SlotID >= parsePageHeader(page).slotCount {
+		return nil, fmt.Errorf("slot %d out of range for %s", rid.SlotID, rid)
+	}
+
+	if !isVisibleSlot(page, rid.SlotID) {
+		return nil, fmt.Errorf("row %s not found", rid)
+	}
+
+	return page, nil
+}
+
+// returns the row stored in the slot, following the forwarding pointer if the row was moved
+func readRow(file *os.File, page []byte, slotID uint16) ([]byte, error) {
+	if getSlotFlags(page, slotID)&slotForwarded == 0 {
+		return readStoredRecord(file, page, slotID)
+	}
+
+	target, err := RIDFromBytes(readRecord(page, slotID))
+	if err != nil {
+		return nil, err
+	}
+
+	targetPage, err := getPageFromHeap(file, int(target.PageID))
+	if err != nil {
+		return nil, err
+	}
+
+	return readStoredRecord(file, targetPage, target.SlotID)
+}
+
+// pageHeader is the parsed form of the header at the start of each page.
+// the lsn of the page (see heapmanager.wal.go) is stored between the type and the other fields,
+// it is only read and written with getPageLSN and setPageLSN.
+type pageHeader struct {
+	// data page or overflow page, the other fields are only used by data pages
+	pageType uint16
+	// offset of the first record, the contiguous free space ends there
+	freeSpaceOffset uint16
+	// number of slots in the slot array including the tombstones
+	slotCount uint16
+	// number of live rows whose rid points to this page, moved in rows are not counted
+	recordCount uint16
+	// total free bytes in the page including the holes left by deleted records
+	freeSpace uint16
+}
+
+// takes a page and returns its header
+func parsePageHeader(page []byte) pageHeader {
 	if len(page) != pageSize {
-		return 0, 0
+		return pageHeader{}
+	}
+
+	return pageHeader{
+		pageType:        binary.BigEndian.Uint16(page[0:2]),
+		freeSpaceOffset: binary.BigEndian.Uint16(page[10:12]),
+		slotCount:       binary.BigEndian.Uint16(page[12:14]),
+		recordCount:     binary.BigEndian.Uint16(page[14:16]),
+		freeSpace:       binary.BigEndian.Uint16(page[16:18]),
 	}
+}
+
+// writes the header to the start of the page
+func setPageHeader(page []byte, header pageHeader) {
+	binary.BigEndian.PutUint16(page[0:2], header.pageType)
+	binary.BigEndian.PutUint16(page[10:12], header.freeSpaceOffset)
+	binary.BigEndian.PutUint16(page[12:14], header.slotCount)
+	binary.BigEndian.PutUint16(page[14:16], header.recordCount)
+	binary.BigEndian.PutUint16(page[16:18], header.freeSpace)
+}
+
+// takes a page and a slot number and returns the offset and size of the record in the slot
+func getSlot(page []byte, slotID uint16) (uint16, uint16) {
+	slotOffset := pageHeaderSize + int(slotID)*slotSize
 
-	freeSpaceOffset := binary.BigEndian.Uint16(page[0:2])
-	recordCount := binary.BigEndian.Uint16(page[2:4])
+	recordOffset := binary.BigEndian.Uint16(page[slotOffset+2 : slotOffset+4])
+	recordSize := binary.BigEndian.Uint16(page[slotOffset+4 : slotOffset+6])
 
-	return freeSpaceOffset, recordCount
+	return recordOffset, recordSize
+}
+
+// takes a page and a slot number and returns the flags of the slot
+func getSlotFlags(page []byte, slotID uint16) uint16 {
+	slotOffset := pageHeaderSize + int(slotID)*slotSize
+	return binary.BigEndian.Uint16(page[slotOffset+6 : slotOffset+8])
+}
+
+// writes the slot with number slotID to the slot array of the page
+func setSlot(page []byte, slotID uint16, recordOffset uint16, recordSize uint16, flags uint16) {
+	slotOffset := pageHeaderSize + int(slotID)*slotSize
+
+	binary.BigEndian.PutUint16(page[slotOffset:slotOffset+2], slotID)
+	binary.BigEndian.PutUint16(page[slotOffset+2:slotOffset+4], recordOffset)
+	binary.BigEndian.PutUint16(page[slotOffset+4:slotOffset+6], recordSize)
+	binary.BigEndian.PutUint16(page[slotOffset+6:slotOffset+8], flags)
+}
+
+// a slot with offset = 0 is a tombstone, no record can start inside the page header
+func isLiveSlot(page []byte, slotID uint16) bool {
+	recordOffset, _ := getSlot(page, slotID)
+	return recordOffset != 0
+}
+
+// a visible slot holds a row that is addressed by the rid of the slot,
+// rows moved in from other pages are addressed by the rid of their forwarding slot.
+func isVisibleSlot(page []byte, slotID uint16) bool {
+	return isLiveSlot(page, slotID) && getSlotFlags(page, slotID)&slotRelocated == 0
+}
+
+// returns the number of bytes a record of the given size takes in the page
+func allocatedSize(recordSize int) int {
+	if recordSize < minRecordSize {
+		return minRecordSize
+	}
+	return recordSize
+}
+
+// adds the record to the page and returns its slot number.
+// the first tombstone is reused if there is one, otherwise a new slot is appended.
+// returns false if the free space of the page is not enough for the record.
+func insertRecord(page []byte, record []byte, flags uint16) (uint16, bool) {
+	header := parsePageHeader(page)
+
+	slotID := header.slotCount
+	for i := uint16(0); i < header.slotCount; i++ {
+		if !isLiveSlot(page, i) {
+			slotID = i
+			break
+		}
+	}
+
+	//a new slot takes space from the free space as well
+	required := allocatedSize(len(record))
+	if slotID == header.slotCount {
+		required += slotSize
+	}
+
+	if int(header.freeSpace) < required {
+		return 0, false
+	}
+
+	//the free space is enough but it is fragmented by the deleted records
+	slotArrayEnd := pageHeaderSize + int(header.slotCount)*slotSize
+	if int(header.freeSpaceOffset)-slotArrayEnd < required {
+		compactPage(page)
+		header = parsePageHeader(page)
+	}
+
+	//records grow from the end of the page towards the slot array
+	header.freeSpaceOffset -= uint16(allocatedSize(len(record)))
+	copy(page[header.freeSpaceOffset:], record)
+	setSlot(page, slotID, header.freeSpaceOffset, uint16(len(record)), flags)
+
+	if slotID == header.slotCount {
+		header.slotCount++
+	}
+	if flags&slotRelocated == 0 {
+		header.recordCount++
+	}
+	header.freeSpace -= uint16(required)
+	setPageHeader(page, header)
+
+	return slotID, true
+}
+
+// replaces the record in the slot with the new record and sets the flags of the slot.
+// the record is overwritten in place if the new one is not bigger,
+// otherwise it is rewritten in the free space of the page.
+// returns false if the free space of the page is not enough for the new record.
+func updateRecord(page []byte, slotID uint16, record []byte, flags uint16) bool {
+	header := parsePageHeader(page)
+	recordOffset, recordSize := getSlot(page, slotID)
+
+	oldSize := allocatedSize(int(recordSize))
+	newSize := allocatedSize(len(record))
+
+	if newSize <= oldSize {
+		copy(page[recordOffset:], record)
+		setSlot(page, slotID, recordOffset, uint16(len(record)), flags)
+
+		header.freeSpace += uint16(oldSize - newSize)
+		setPageHeader(page, header)
+		return true
+	}
+
+	if int(header.freeSpace)+oldSize < newSize {
+		return false
+	}
+
+	//free the old record first so that compaction can reclaim its space
+	setSlot(page, slotID, 0, 0, 0)
+	header.freeSpace += uint16(oldSize)
+
+	slotArrayEnd := pageHeaderSize + int(header.slotCount)*slotSize
+	if int(header.freeSpaceOffset)-slotArrayEnd < newSize {
+		setPageHeader(page, header)
+		compactPage(page)
+		header = parsePageHeader(page)
+	}
+
+	header.freeSpaceOffset -= uint16(newSize)
+	copy(page[header.freeSpaceOffset:], record)
+	setSlot(page, slotID, header.freeSpaceOffset, uint16(len(record)), flags)
+
+	header.freeSpace -= uint16(newSize)
+	setPageHeader(page, header)
+	return true
+}
+
+// turns the slot into a tombstone and gives the space of its record back to the page
+func deleteRecord(page []byte, slotID uint16) {
+	header := parsePageHeader(page)
+	_, recordSize := getSlot(page, slotID)
+
+	if getSlotFlags(page, slotID)&slotRelocated == 0 {
+		header.recordCount--
+	}
+
+	setSlot(page, slotID, 0, 0, 0)
+
+	header.freeSpace += uint16(allocatedSize(int(recordSize)))
+	setPageHeader(page, header)
+}
+
+// moves all the live records to the end of the page so that the free space is contiguous again.
+// the records keep their slots so the RIDs stay the same.
+func compactPage(page []byte) {
+	header := parsePageHeader(page)
+
+	compacted := make([]byte, pageSize)
+	freeSpaceOffset := uint16(pageSize)
+
+	for slotID := uint16(0); slotID < header.slotCount; slotID++ {
+		if !isLiveSlot(page, slotID) {
+			continue
+		}
+		recordOffset, recordSize := getSlot(page, slotID)
+		freeSpaceOffset -= uint16(allocatedSize(int(recordSize)))
+		copy(compacted[freeSpaceOffset:], page[recordOffset:recordOffset+recordSize])
+		setSlot(page, slotID, freeSpaceOffset, recordSize, getSlotFlags(page, slotID))
+	}
+
+	copy(page[freeSpaceOffset:], compacted[freeSpaceOffset:])
+	header.freeSpaceOffset = freeSpaceOffset
+	setPageHeader(page, header)
+}
+
+// returns a copy of the record stored in the slot with number slotID
+func readRecord(page []byte, slotID uint16) []byte {
+	recordOffset, recordSize := getSlot(page, slotID)
+
+	record := make([]byte, recordSize)
+	copy(record, page[recordOffset:recordOffset+recordSize])
+	return record
 }
 
 // parse the heap header and return the pageCount and rowCount
@@ -230,96 +819,135 @@ func parseHeapHeader(header []byte) (uint32, uint32) {
 }
 
 // takes a page and returns all the rows in the page
-func extractRowsFromPage(page []byte) [][]byte {
+// the file is needed to read the rows that were moved to other pages
+func extractRowsFromPage(file *os.File, page []byte) ([][]byte, error) {
 
 	// fmt.Println("extractRowsFromPage ", page)
 
-	_, recordCount := parsePageHeader(page)
-
-	fmt.Println("recordCount ", recordCount)
+	header := parsePageHeader(page)
 
-	records := make([][]byte, 0)
+	records := make([][]byte, 0, header.recordCount)
 
-	//skip the header size
-	recordIndex := pageHeaderSize
-
-	for recordCount > 0 {
-		//read the row size from row header
-		rowSize := binary.BigEndian.Uint16(page[recordIndex : recordIndex+2])
-
-		//read the row from the page
-		row := make([]byte, rowSize)
-		copy(row, page[recordIndex+2:recordIndex+2+int(rowSize)])
+	//read the records in the order of their slots, tombstones and moved in rows are skipped
+	for slotID := uint16(0); slotID < header.slotCount; slotID++ {
+		if !isVisibleSlot(page, slotID) {
+			continue
+		}
+		row, err := readRow(file, page, slotID)
+		if err != nil {
+			return nil, err
+		}
 		records = append(records, row)
-
-		//update the index to get the next row
-		recordIndex = recordIndex + 2 + int(rowSize)
-		recordCount -= 1
 	}
-	return records
+	return records, nil
 }
 
-// crete new page and initialize page header with free space offset = 0 and record count = 0
+// crete new page and initialize page header with free space offset = pageSize and slot count = 0
+// records are added from the end of the page, so the whole page after the header is free
 // return the page as []byte
 func createPage() []byte {
 	page := make([]byte, pageSize)
 
-	copy(page[0:2], []byte{byte(pageHeaderSize >> 8), byte(pageHeaderSize)})
-	copy(page[2:4], []byte{0, 0})
+	setPageHeader(page, pageHeader{
+		pageType:        pageTypeData,
+		freeSpaceOffset: pageSize,
+		freeSpace:       pageSize - pageHeaderSize,
+	})
 	return page
 }
 
-// returns the page with pageIndex from the heap file
-func getPageFromHeap(file *os.File, pageIndex int) ([]byte, error) {
+// FlushHeap writes the pages of the heap with name = name that are cached in the buffer pool
+// to the heap file and syncs it.
+func FlushHeap(name string) error {
+	return buffermanager.Default().FlushFile(name)
+}
 
-	offset := int64(heapHeaderSize + pageIndex*pageSize)
+// returns the offset of the page with pageIndex in the heap file
+func pageOffset(pageIndex int) int64 {
+	return int64(heapHeaderSize) + int64(pageIndex)*pageSize
+}
+
+// returns a copy of the page with pageIndex from the heap file
+// the page is read through the buffer pool so hot pages are not read from the disk again
+func getPageFromHeap(file *os.File, pageIndex int) ([]byte, error) {
+	pool := buffermanager.Default()
 
-	// Seek to the beginning of the page
-	if _, err := file.Seek(offset, 0); err != nil {
+	frame, err := pool.FetchPage(file.Name(), pageOffset(pageIndex))
+	if err != nil {
 		return nil, err
 	}
 
 	// Read the page content
 	page := make([]byte, pageSize)
-	if _, err := file.Read(page); err != nil {
-		return nil, err
+	copy(page, frame)
+
+	return page, pool.UnpinPage(file.Name(), pageOffset(pageIndex), false)
+}
+
+// adds delta to the rowCount in the heap header
+func updateRowCount(tx *logmanager.Transaction, file *os.File, delta int) error {
+	header := make([]byte, heapHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return err
 	}
 
-	return page, nil
+	_, rowCount := parseHeapHeader(header)
+	binary.BigEndian.PutUint32(header[4:8], uint32(int(rowCount)+delta))
+
+	return tx.OverwriteAt(file, header, 0)
 }
 
 // overWrite the page to the file at pageIndex
-func overWritePageToHeap(file *os.File, pageIndex int, page []byte) {
-	//overWrite the page to the file
-	//use the file.WriteAt function
-	file.WriteAt(page, int64(pageIndex*pageSize)+int64(heapHeaderSize))
-	file.Sync()
-}
+// the change is logged by tx and the page is written to the buffer pool,
+// it reaches the file when it is evicted or flushed
+// the free space map entry of the page is updated as well
+func overWritePageToHeap(tx *logmanager.Transaction, file *os.File, pageIndex int, page []byte) error {
+	pool := buffermanager.Default()
 
-// append the page to the file
-func appendPageToHeap(file *os.File, page []byte) {
-	//write the page to the end of the file
-	//get file size and write the page to the end of the file
+	frame, err := pool.FetchPage(file.Name(), pageOffset(pageIndex))
+	if err != nil {
+		return err
+	}
 
-	// Get the current file size
-	fileInfo, err := file.Stat()
+	lsn, err := logPageWrite(tx, file, pageIndex, frame, page)
 	if err != nil {
-		return
+		pool.UnpinPage(file.Name(), pageOffset(pageIndex), false)
+		return err
 	}
-	fileSize := fileInfo.Size()
 
-	// Write the page to the end of the file
-	file.WriteAt(page, fileSize)
+	copy(frame, page)
+	setPageLSN(frame, lsn)
 
-	//read heap header from the file and parse it then ++ pageCount
+	if err := pool.UnpinPage(file.Name(), pageOffset(pageIndex), true); err != nil {
+		return err
+	}
 
+	updateFreeSpaceMap(file, pageIndex, page)
+	return nil
+}
+
+// append the page to the file and return its index
+func appendPageToHeap(tx *logmanager.Transaction, file *os.File, page []byte) (int, error) {
+	//read heap header from the file and parse it then ++ pageCount
 	header := make([]byte, heapHeaderSize)
-	file.ReadAt(header, 0)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		return 0, err
+	}
 	pageCount, _ := parseHeapHeader(header)
+
+	// Write the page after the last page of the heap
+	//the map entry is added before the page count, so the map never reads the page again
+	pageIndex := int(pageCount)
+	if err := overWritePageToHeap(tx, file, pageIndex, page); err != nil {
+		return 0, err
+	}
+
 	pageCount++
 	binary.BigEndian.PutUint32(header, pageCount)
 
 	//write the heap header to the file
-	file.WriteAt(header, 0)
-	file.Sync()
+	if err := tx.OverwriteAt(file, header, 0); err != nil {
+		return 0, err
+	}
+	return pageIndex, nil
 }
@@ -0,0 +1,178 @@
+package heapmanager
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// creates the heap, the test fails if it cannot
+func createTestHeap(t *testing.T, name string) {
+	t.Helper()
+
+	if err := CreateHeap(name); err != nil {
+		t.Fatalf("failed to create the heap: %s", err)
+	}
+}
+
+func addRow(t *testing.T, name string, row []byte) RID {
+	t.Helper()
+
+	rid, err := AddRowToHeap(name, row)
+	if err != nil {
+		t.Fatalf("failed to add a row of %d bytes: %s", len(row), err)
+	}
+	return rid
+}
+
+func updateRow(t *testing.T, name string, rid RID, row []byte) {
+	t.Helper()
+
+	if err := UpdateRowInHeap(name, rid, row); err != nil {
+		t.Fatalf("failed to update row %v to %d bytes: %s", rid, len(row), err)
+	}
+}
+
+// reads all the rows of the heap with a scanner, a row read twice fails the test
+func scanHeap(t *testing.T, name string) map[RID][]byte {
+	t.Helper()
+
+	scanner, err := OpenHeapScanner(name)
+	if err != nil {
+		t.Fatalf("failed to open the scanner: %s", err)
+	}
+	defer scanner.Close()
+
+	rows := make(map[RID][]byte)
+	for scanner.Next() {
+		if _, ok := rows[scanner.RID()]; ok {
+			t.Fatalf("the scan read row %v twice", scanner.RID())
+		}
+		rows[scanner.RID()] = scanner.Row()
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to scan the heap: %s", err)
+	}
+	return rows
+}
+
+// checks that the heap has exactly the rows, read by rid and by a scan
+func checkRows(t *testing.T, name string, rows map[RID][]byte) {
+	t.Helper()
+
+	for rid, expected := range rows {
+		row, err := GetRowByRID(name, rid)
+		if err != nil {
+			t.Fatalf("failed to read row %v: %s", rid, err)
+		}
+		if !bytes.Equal(row, expected) {
+			t.Fatalf("row %v has %d bytes starting with %v, expected %d bytes of %v", rid, len(row), row[:min(len(row), 1)], len(expected), expected[:min(len(expected), 1)])
+		}
+	}
+
+	scanned := scanHeap(t, name)
+	if len(scanned) != len(rows) {
+		t.Fatalf("the scan read %d rows, expected %d", len(scanned), len(rows))
+	}
+	for rid, row := range scanned {
+		if !bytes.Equal(row, rows[rid]) {
+			t.Fatalf("the scan read row %v with %d bytes, expected %d", rid, len(row), len(rows[rid]))
+		}
+	}
+}
+
+// reports whether the slot of the row holds a forwarding pointer
+func isForwarded(t *testing.T, name string, rid RID) bool {
+	t.Helper()
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	page, err := getPageFromHeap(file, int(rid.PageID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return getSlotFlags(page, rid.SlotID)&slotForwarded != 0
+}
+
+// a row that grows out of its page moves to another one, its rid stays valid and the scan reads it once
+func TestForwarding(t *testing.T) {
+	createTestHeap(t, "forwarding")
+
+	// the 8 rows fill the page
+	rows := make(map[RID][]byte)
+	rids := make([]RID, 8)
+	for i := range rids {
+		rids[i] = addRow(t, "forwarding", testRow(byte(i+1), 1000))
+		rows[rids[i]] = testRow(byte(i+1), 1000)
+	}
+	if rids[0].PageID != rids[7].PageID {
+		t.Fatalf("the rows are in pages %d and %d, the test needs them in one page", rids[0].PageID, rids[7].PageID)
+	}
+
+	// the row does not fit in its page anymore
+	rid := rids[3]
+	rows[rid] = testRow(0xA1, 1200)
+	updateRow(t, "forwarding", rid, rows[rid])
+	if !isForwarded(t, "forwarding", rid) {
+		t.Fatal("the row did not move")
+	}
+	checkRows(t, "forwarding", rows)
+
+	// the moved row grows in its new page, and moves again once the new page is full
+	rows[rid] = testRow(0xA2, 1300)
+	updateRow(t, "forwarding", rid, rows[rid])
+	checkRows(t, "forwarding", rows)
+
+	for i := 0; i < 5; i++ {
+		other := addRow(t, "forwarding", testRow(0xB0+byte(i), 1300))
+		rows[other] = testRow(0xB0+byte(i), 1300)
+	}
+	rows[rid] = testRow(0xA3, 2000)
+	updateRow(t, "forwarding", rid, rows[rid])
+	if !isForwarded(t, "forwarding", rid) {
+		t.Fatal("the row is not forwarded after its second move")
+	}
+	checkRows(t, "forwarding", rows)
+
+	// the row goes back to its page once it has room and the page of the row is full
+	for i := 0; i < 3; i++ {
+		other := addRow(t, "forwarding", testRow(0xC0+byte(i), 2040))
+		rows[other] = testRow(0xC0+byte(i), 2040)
+	}
+	for _, other := range rids[:2] {
+		if err := DeleteRowFromHeap("forwarding", other); err != nil {
+			t.Fatalf("failed to delete row %v: %s", other, err)
+		}
+		delete(rows, other)
+	}
+	rows[rid] = testRow(0xA4, maxInlineRowSize)
+	updateRow(t, "forwarding", rid, rows[rid])
+	if isForwarded(t, "forwarding", rid) {
+		t.Fatal("the row did not go back to its page")
+	}
+	checkRows(t, "forwarding", rows)
+
+	// a moved row is deleted with its forwarding pointer
+	rows[rids[5]] = testRow(0xA6, maxInlineRowSize)
+	updateRow(t, "forwarding", rids[5], rows[rids[5]])
+	moved := rids[4]
+	rows[moved] = testRow(0xA5, maxInlineRowSize)
+	updateRow(t, "forwarding", moved, rows[moved])
+	if !isForwarded(t, "forwarding", moved) {
+		t.Fatal("the row did not move")
+	}
+	if err := DeleteRowFromHeap("forwarding", moved); err != nil {
+		t.Fatalf("failed to delete the moved row: %s", err)
+	}
+	delete(rows, moved)
+	checkRows(t, "forwarding", rows)
+	if _, err := GetRowByRID("forwarding", moved); err == nil {
+		t.Fatal("the deleted row can still be read")
+	}
+
+	reopenHeap(t, "forwarding")
+	checkRows(t, "forwarding", rows)
+}
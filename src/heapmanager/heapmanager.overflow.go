@@ -0,0 +1,179 @@
+package heapmanager
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// rows bigger than maxInlineRowSize are not stored in the data pages,
+// they are split across a chain of overflow pages and the record in the data page
+// only keeps a pointer to the chain (similar to TOAST in postgres).
+//
+// overflow page:
+// | PageType 2B | PageLSN 8B | ChunkSize 2B | NextPage 4B | Chunk |
+//
+// overflow pointer (the record stored in the data page):
+// | RowSize 4B | FirstPage 4B |
+const (
+	maxInlineRowSize    = pageSize / 4
+	overflowHeaderSize  = 16
+	overflowChunkSize   = pageSize - overflowHeaderSize
+	overflowPointerSize = 8
+	// marks the last page of a chain
+	noNextPage = 0xFFFFFFFF
+)
+
+// stores the row in overflow pages if it is too big to be kept in a data page.
+// returns the record to store in the data page and the slot flags it needs.
+func prepareRecord(tx *logmanager.Transaction, file *os.File, row []byte) ([]byte, uint16, error) {
+	if len(row) <= maxInlineRowSize {
+		return row, 0, nil
+	}
+
+	firstPage, err := writeOverflowChain(tx, file, row)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pointer := make([]byte, overflowPointerSize)
+	binary.BigEndian.PutUint32(pointer[0:4], uint32(len(row)))
+	binary.BigEndian.PutUint32(pointer[4:8], firstPage)
+	return pointer, slotOverflow, nil
+}
+
+// frees the overflow pages of a record that is being deleted or replaced
+func freeRecord(tx *logmanager.Transaction, file *os.File, record []byte, flags uint16) error {
+	if flags&slotOverflow == 0 {
+		return nil
+	}
+	return freeOverflowChain(tx, file, binary.BigEndian.Uint32(record[4:8]))
+}
+
+// returns the row of a record, reading it back from the overflow pages if needed
+func readStoredRecord(file *os.File, page []byte, slotID uint16) ([]byte, error) {
+	record := readRecord(page, slotID)
+	if getSlotFlags(page, slotID)&slotOverflow == 0 {
+		return record, nil
+	}
+
+	rowSize := binary.BigEndian.Uint32(record[0:4])
+	firstPage := binary.BigEndian.Uint32(record[4:8])
+	return readOverflowChain(file, firstPage, int(rowSize))
+}
+
+// writes the data to a chain of overflow pages and returns the index of the first page.
+// the chunks are written from the last one, so each page knows the index of the next one.
+func writeOverflowChain(tx *logmanager.Transaction, file *os.File, data []byte) (uint32, error) {
+	nextPage := uint32(noNextPage)
+
+	chunkCount := (len(data) + overflowChunkSize - 1) / overflowChunkSize
+	for i := chunkCount - 1; i >= 0; i-- {
+		chunk := data[i*overflowChunkSize : min(len(data), (i+1)*overflowChunkSize)]
+
+		page := make([]byte, pageSize)
+		binary.BigEndian.PutUint16(page[0:2], pageTypeOverflow)
+		binary.BigEndian.PutUint16(page[10:12], uint16(len(chunk)))
+		binary.BigEndian.PutUint32(page[12:16], nextPage)
+		copy(page[overflowHeaderSize:], chunk)
+
+		pageIndex, err := allocateOverflowPage(tx, file, page)
+		if err != nil {
+			return 0, err
+		}
+		nextPage = uint32(pageIndex)
+	}
+
+	return nextPage, nil
+}
+
+// reads the chain of overflow pages starting at firstPage and joins the chunks
+func readOverflowChain(file *os.File, firstPage uint32, size int) ([]byte, error) {
+	data := make([]byte, 0, size)
+
+	for pageIndex := firstPage; pageIndex != noNextPage; {
+		page, err := getPageFromHeap(file, int(pageIndex))
+		if err != nil {
+			return nil, err
+		}
+		if parsePageHeader(page).pageType != pageTypeOverflow {
+			return nil, fmt.Errorf("page %d is not an overflow page", pageIndex)
+		}
+
+		chunkSize := binary.BigEndian.Uint16(page[10:12])
+		data = append(data, page[overflowHeaderSize:overflowHeaderSize+int(chunkSize)]...)
+		pageIndex = binary.BigEndian.Uint32(page[12:16])
+	}
+
+	if len(data) != size {
+		return nil, fmt.Errorf("overflow chain at page %d has %d bytes, expected %d", firstPage, len(data), size)
+	}
+	return data, nil
+}
+
+// turns every page of the chain back into an empty data page,
+// the free space map then reports them as free for new rows or new chains.
+func freeOverflowChain(tx *logmanager.Transaction, file *os.File, firstPage uint32) error {
+	for pageIndex := firstPage; pageIndex != noNextPage; {
+		page, err := getPageFromHeap(file, int(pageIndex))
+		if err != nil {
+			return err
+		}
+		if parsePageHeader(page).pageType != pageTypeOverflow {
+			return fmt.Errorf("page %d is not an overflow page", pageIndex)
+		}
+
+		nextPage := binary.BigEndian.Uint32(page[12:16])
+		if err := overWritePageToHeap(tx, file, int(pageIndex), createPage()); err != nil {
+			return err
+		}
+		pageIndex = nextPage
+	}
+	return nil
+}
+
+// writes the overflow page to an empty data page of the heap, or appends it if there is none.
+// returns the index of the page.
+func allocateOverflowPage(tx *logmanager.Transaction, file *os.File, page []byte) (int, error) {
+	//an empty page is in the highest category of the free space map
+	emptyPageSpace := int(freeSpaceCategory(pageSize-pageHeaderSize)) * fsmCategorySize
+
+	for start := 0; ; {
+		pageIndex, err := findPageWithFreeSpace(file, emptyPageSpace, start)
+		if err != nil {
+			return 0, err
+		}
+		if pageIndex == -1 {
+			break
+		}
+		start = pageIndex + 1
+
+		candidate, err := getPageFromHeap(file, pageIndex)
+		if err != nil {
+			return 0, err
+		}
+
+		if isEmptyDataPage(candidate) {
+			return pageIndex, overWritePageToHeap(tx, file, pageIndex, page)
+		}
+	}
+
+	return appendPageToHeap(tx, file, page)
+}
+
+// a data page with no live records, only tombstones are left in its slot array
+func isEmptyDataPage(page []byte) bool {
+	header := parsePageHeader(page)
+	if header.pageType != pageTypeData {
+		return false
+	}
+
+	for slotID := uint16(0); slotID < header.slotCount; slotID++ {
+		if isLiveSlot(page, slotID) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package heapmanager
+
+import (
+	"os"
+	"testing"
+)
+
+// returns the number of pages in the header of the heap
+func heapPageCount(t *testing.T, name string) uint32 {
+	t.Helper()
+
+	header := make([]byte, heapHeaderSize)
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.ReadAt(header, 0); err != nil {
+		t.Fatal(err)
+	}
+	pageCount, _ := parseHeapHeader(header)
+	return pageCount
+}
+
+// a row bigger than a quarter of a page is stored in overflow pages, whose pages are reused once it is gone
+func TestOverflow(t *testing.T) {
+	createTestHeap(t, "overflow")
+
+	rows := make(map[RID][]byte)
+	small := addRow(t, "overflow", testRow(1, 100))
+	rows[small] = testRow(1, 100)
+	big := addRow(t, "overflow", testRow(2, 30000))
+	rows[big] = testRow(2, 30000)
+	checkRows(t, "overflow", rows)
+
+	// a row that grows past the limit, and one that shrinks under it
+	rows[small] = testRow(3, maxInlineRowSize+1)
+	updateRow(t, "overflow", small, rows[small])
+	rows[big] = testRow(4, 200)
+	updateRow(t, "overflow", big, rows[big])
+	checkRows(t, "overflow", rows)
+
+	pageCount := heapPageCount(t, "overflow")
+
+	// the pages freed by the old chain hold the new one
+	rows[big] = testRow(5, 20000)
+	updateRow(t, "overflow", big, rows[big])
+	checkRows(t, "overflow", rows)
+	if after := heapPageCount(t, "overflow"); after != pageCount {
+		t.Fatalf("the heap grew from %d to %d pages, the freed overflow pages were not reused", pageCount, after)
+	}
+
+	if err := DeleteRowFromHeap("overflow", big); err != nil {
+		t.Fatalf("failed to delete the big row: %s", err)
+	}
+	delete(rows, big)
+	other := addRow(t, "overflow", testRow(6, 16000))
+	rows[other] = testRow(6, 16000)
+	if after := heapPageCount(t, "overflow"); after != pageCount {
+		t.Fatalf("the heap grew from %d to %d pages, the pages of the deleted row were not reused", pageCount, after)
+	}
+
+	reopenHeap(t, "overflow")
+	checkRows(t, "overflow", rows)
+}
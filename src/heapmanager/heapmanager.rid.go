@@ -0,0 +1,39 @@
+package heapmanager
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// RIDSize is the size of an encoded RID in bytes.
+const RIDSize = 6
+
+// RID (record identifier) points to a row in the heap.
+// it is made of the page number and the slot number of the row inside that page.
+type RID struct {
+	PageID uint32
+	SlotID uint16
+}
+
+// Bytes encodes the rid as | PageID 4B | SlotID 2B | using big endian.
+func (r RID) Bytes() []byte {
+	b := make([]byte, RIDSize)
+	binary.BigEndian.PutUint32(b[0:4], r.PageID)
+	binary.BigEndian.PutUint16(b[4:6], r.SlotID)
+	return b
+}
+
+func (r RID) String() string {
+	return fmt.Sprintf("(%d, %d)", r.PageID, r.SlotID)
+}
+
+// RIDFromBytes decodes a rid that was encoded with RID.Bytes.
+func RIDFromBytes(b []byte) (RID, error) {
+	if len(b) != RIDSize {
+		return RID{}, fmt.Errorf("invalid rid length %d, expected %d", len(b), RIDSize)
+	}
+	return RID{
+		PageID: binary.BigEndian.Uint32(b[0:4]),
+		SlotID: binary.BigEndian.Uint16(b[4:6]),
+	}, nil
+}
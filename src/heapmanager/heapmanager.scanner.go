@@ -0,0 +1,127 @@
+package heapmanager
+
+import (
+	"os"
+)
+
+// HeapScanner reads all the rows of a heap page by page with a single open file.
+//
+//	scanner, err := OpenHeapScanner("student")
+//	if err != nil {
+//		return err
+//	}
+//	defer scanner.Close()
+//
+//	for scanner.Next() {
+//		rid, row := scanner.RID(), scanner.Row()
+//		...
+//	}
+//	if err := scanner.Err(); err != nil {
+//		return err
+//	}
+//
+// the loop can be stopped at any time, Close releases the file.
+type HeapScanner struct {
+	file      *os.File
+	pageCount uint32
+
+	// the page being scanned and the next slot to read from it
+	page      []byte
+	pageIndex uint32
+	slotID    uint16
+
+	rid RID
+	row []byte
+	err error
+}
+
+// OpenHeapScanner opens the heap with name = name and returns a scanner positioned before its first row.
+func OpenHeapScanner(name string) (*HeapScanner, error) {
+	file, err := os.OpenFile(name, os.O_RDONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, heapHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	pageCount, _ := parseHeapHeader(header)
+	return &HeapScanner{file: file, pageCount: pageCount}, nil
+}
+
+// Seek moves the scanner so that the next call to Next returns the first row at or after rid.
+func (s *HeapScanner) Seek(rid RID) {
+	s.page = nil
+	s.pageIndex = rid.PageID
+	s.slotID = rid.SlotID
+}
+
+// Next reads the next row, it returns false when there are no more rows or an error happened.
+func (s *HeapScanner) Next() bool {
+	if s.err != nil {
+		return false
+	}
+
+	for s.pageIndex < s.pageCount {
+		if s.page == nil {
+			page, err := getPageFromHeap(s.file, int(s.pageIndex))
+			if err != nil {
+				s.err = err
+				return false
+			}
+			s.page = page
+		}
+
+		header := parsePageHeader(s.page)
+		for header.pageType == pageTypeData && s.slotID < header.slotCount {
+			slotID := s.slotID
+			s.slotID++
+
+			//tombstones and rows moved in from other pages are skipped,
+			//moved rows are returned with the rid of their forwarding slot
+			if !isVisibleSlot(s.page, slotID) {
+				continue
+			}
+
+			row, err := readRow(s.file, s.page, slotID)
+			if err != nil {
+				s.err = err
+				return false
+			}
+
+			s.rid = RID{PageID: s.pageIndex, SlotID: slotID}
+			s.row = row
+			return true
+		}
+
+		//move to the next page
+		s.page = nil
+		s.pageIndex++
+		s.slotID = 0
+	}
+
+	return false
+}
+
+// RID returns the rid of the row read by the last call to Next.
+func (s *HeapScanner) RID() RID {
+	return s.rid
+}
+
+// Row returns the row read by the last call to Next.
+func (s *HeapScanner) Row() []byte {
+	return s.row
+}
+
+// Err returns the error that stopped the scan, if any.
+func (s *HeapScanner) Err() error {
+	return s.err
+}
+
+// Close closes the heap file of the scanner.
+func (s *HeapScanner) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,81 @@
+package heapmanager
+
+import (
+	"bytes"
+	"testing"
+)
+
+// the scan skips the deleted rows and reads a moved row once, with the rid of its forwarding slot
+func TestScannerSkipsDeletedAndMovedRows(t *testing.T) {
+	createTestHeap(t, "scan")
+
+	rows := make(map[RID][]byte)
+	rids := make([]RID, 8)
+	for i := range rids {
+		rids[i] = addRow(t, "scan", testRow(byte(i+1), 1000))
+		rows[rids[i]] = testRow(byte(i+1), 1000)
+	}
+
+	// the moved row is in a page after the one of its forwarding slot
+	rows[rids[2]] = testRow(0xA1, 2000)
+	updateRow(t, "scan", rids[2], rows[rids[2]])
+	if !isForwarded(t, "scan", rids[2]) {
+		t.Fatal("the row did not move, the test needs a forwarded row")
+	}
+
+	for _, rid := range []RID{rids[0], rids[5]} {
+		if err := DeleteRowFromHeap("scan", rid); err != nil {
+			t.Fatalf("failed to delete row %v: %s", rid, err)
+		}
+		delete(rows, rid)
+	}
+
+	// a slot freed by a delete is reused by the next insert
+	reused := addRow(t, "scan", testRow(0xB1, 10))
+	rows[reused] = testRow(0xB1, 10)
+	if reused != rids[0] && reused != rids[5] {
+		t.Fatalf("the new row is at %v, expected the slot of a deleted row", reused)
+	}
+
+	checkRows(t, "scan", rows)
+}
+
+func TestScannerSeekAndEarlyClose(t *testing.T) {
+	createTestHeap(t, "seek")
+
+	rids := make([]RID, 20)
+	for i := range rids {
+		rids[i] = addRow(t, "seek", testRow(byte(i+1), 1000))
+	}
+
+	scanner, err := OpenHeapScanner("seek")
+	if err != nil {
+		t.Fatalf("failed to open the scanner: %s", err)
+	}
+	scanner.Seek(rids[10])
+	for i := 10; i < 13; i++ {
+		if !scanner.Next() {
+			t.Fatalf("the scan stopped before row %d: %v", i, scanner.Err())
+		}
+		if scanner.RID() != rids[i] || !bytes.Equal(scanner.Row(), testRow(byte(i+1), 1000)) {
+			t.Fatalf("read row %v, expected row %d at %v", scanner.RID(), i, rids[i])
+		}
+	}
+	// the scan is stopped before its end
+	if err := scanner.Close(); err != nil {
+		t.Fatalf("failed to close the scanner: %s", err)
+	}
+
+	scanner, err = OpenHeapScanner("seek")
+	if err != nil {
+		t.Fatalf("failed to open the scanner: %s", err)
+	}
+	defer scanner.Close()
+	scanner.Seek(RID{PageID: rids[19].PageID + 1})
+	if scanner.Next() {
+		t.Fatalf("the scan after the last page read row %v", scanner.RID())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("the scan after the last page failed: %s", err)
+	}
+}
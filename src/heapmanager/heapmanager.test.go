@@ -6,7 +6,8 @@ import (
 
 func PlayGround() {
 	CreateHeap("student")
-	AddRowToHeap("student", []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	rid, _ := AddRowToHeap("student", []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	fmt.Println(GetRowByRID("student", rid))
 	rows := GetPageRowsFromHeap("student", 0)
 	fmt.Println(rows)
 }
@@ -0,0 +1,42 @@
+package heapmanager
+
+import (
+	"testing"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// a rollback takes back the move of a row and its overflow pages
+func TestRollbackOfMovedRow(t *testing.T) {
+	createTestHeap(t, "rollbackmove")
+
+	rows := make(map[RID][]byte)
+	for i := 0; i < 8; i++ {
+		rows[addRow(t, "rollbackmove", testRow(byte(i+1), 1000))] = testRow(byte(i+1), 1000)
+	}
+	var rid RID
+	for rid = range rows {
+		break
+	}
+
+	tx, err := logmanager.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %s", err)
+	}
+	if err := UpdateRowInHeapTx(tx, "rollbackmove", rid, testRow(0xA1, 2000)); err != nil {
+		t.Fatalf("failed to move the row: %s", err)
+	}
+	if err := UpdateRowInHeapTx(tx, "rollbackmove", rid, testRow(0xA2, 40000)); err != nil {
+		t.Fatalf("failed to update the moved row to an overflow row: %s", err)
+	}
+	if _, err := AddRowToHeapTx(tx, "rollbackmove", testRow(0xA3, 3000)); err != nil {
+		t.Fatalf("failed to add a row: %s", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to roll back: %s", err)
+	}
+
+	checkRows(t, "rollbackmove", rows)
+	reopenHeap(t, "rollbackmove")
+	checkRows(t, "rollbackmove", rows)
+}
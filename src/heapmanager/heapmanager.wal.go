@@ -0,0 +1,149 @@
+package heapmanager
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/buffermanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// every change to a heap page is logged before the page is changed in the buffer pool.
+// the record holds the part of the page that changed, before and after the change:
+// | PathSize 2B | Path | PageIndex 4B | Offset 2B | Size 2B | Before | After |
+//
+// each page keeps the lsn of the last record applied to it in its header,
+// redo skips the records the page already has, and the buffer pool flushes the log
+// up to the lsn of a page before it writes the page to the heap file.
+// the changes to the heap header are logged as plain file writes without the size of the file
+// (see OverwriteAt in logmanager.file.go), the buffer pool extends the file outside of the log.
+
+func init() {
+	logmanager.RegisterResourceManager(logmanager.ResourceManagerHeap, heapResourceManager{})
+
+	//the buffer pool only holds heap pages
+	buffermanager.Default().SetWriteBackHook(func(page []byte) error {
+		return logmanager.FlushTo(getPageLSN(page))
+	})
+}
+
+// returns the lsn of the last logged change applied to the page
+func getPageLSN(page []byte) logmanager.LSN {
+	return logmanager.LSN(binary.BigEndian.Uint64(page[2:10]))
+}
+
+func setPageLSN(page []byte, lsn logmanager.LSN) {
+	binary.BigEndian.PutUint64(page[2:10], uint64(lsn))
+}
+
+type pageWrite struct {
+	path      string
+	pageIndex int
+	offset    int
+	before    []byte
+	after     []byte
+}
+
+func (w *pageWrite) encode() []byte {
+	data := make([]byte, 0, 10+len(w.path)+2*len(w.after))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(w.path)))
+	data = append(data, w.path...)
+	data = binary.BigEndian.AppendUint32(data, uint32(w.pageIndex))
+	data = binary.BigEndian.AppendUint16(data, uint16(w.offset))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(w.after)))
+	data = append(data, w.before...)
+	return append(data, w.after...)
+}
+
+func decodePageWrite(data []byte) *pageWrite {
+	pathSize := int(binary.BigEndian.Uint16(data[0:2]))
+	data = data[2:]
+
+	w := &pageWrite{path: string(data[:pathSize])}
+	data = data[pathSize:]
+
+	w.pageIndex = int(binary.BigEndian.Uint32(data[0:4]))
+	w.offset = int(binary.BigEndian.Uint16(data[4:6]))
+	size := int(binary.BigEndian.Uint16(data[6:8]))
+	w.before = data[8 : 8+size]
+	w.after = data[8+size : 8+2*size]
+	return w
+}
+
+// logs the change from the current page (the frame in the buffer pool) to the new page
+// and returns the lsn to set on the page, only the bytes between the first and the last
+// changed byte are logged.
+func logPageWrite(tx *logmanager.Transaction, file *os.File, pageIndex int, current []byte, page []byte) (logmanager.LSN, error) {
+	//the lsn is not part of the change, it is set when the change is applied
+	setPageLSN(page, getPageLSN(current))
+
+	start, end := 0, pageSize
+	for start < end && current[start] == page[start] {
+		start++
+	}
+	if start == end {
+		return getPageLSN(current), nil
+	}
+	for current[end-1] == page[end-1] {
+		end--
+	}
+
+	path, err := filepath.Abs(file.Name())
+	if err != nil {
+		return 0, err
+	}
+
+	w := &pageWrite{
+		path:      path,
+		pageIndex: pageIndex,
+		offset:    start,
+		before:    current[start:end],
+		after:     page[start:end],
+	}
+	return tx.Log(logmanager.ResourceManagerHeap, w.encode())
+}
+
+type heapResourceManager struct{}
+
+func (heapResourceManager) Redo(lsn logmanager.LSN, payload []byte) error {
+	w := decodePageWrite(payload)
+
+	//the heap was deleted later, its changes do not matter anymore
+	file, err := os.OpenFile(w.path, os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	pool := buffermanager.Default()
+	frame, err := pool.FetchPage(w.path, pageOffset(w.pageIndex))
+	if err != nil {
+		return err
+	}
+
+	//the page already has the change if it was written to the file after it
+	changed := getPageLSN(frame) < lsn
+	if changed {
+		copy(frame[w.offset:], w.after)
+		setPageLSN(frame, lsn)
+	}
+
+	page := make([]byte, pageSize)
+	copy(page, frame)
+	if err := pool.UnpinPage(w.path, pageOffset(w.pageIndex), changed); err != nil {
+		return err
+	}
+
+	updateFreeSpaceMap(file, w.pageIndex, page)
+	return nil
+}
+
+func (heapResourceManager) Compensate(payload []byte) []byte {
+	w := decodePageWrite(payload)
+	w.before, w.after = w.after, w.before
+	return w.encode()
+}
@@ -0,0 +1,131 @@
+package heapmanager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/buffermanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// the heaps and the log are created in the working directory, the tests run in a temporary one
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "heapmanager")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// returns a row of size bytes filled with b
+func testRow(b byte, size int) []byte {
+	return bytes.Repeat([]byte{b}, size)
+}
+
+// drops the pages and the free space map of the heap from memory, so the next reads see the file as a new process would
+func reopenHeap(t *testing.T, name string) {
+	t.Helper()
+
+	if err := logmanager.Checkpoint(); err != nil {
+		t.Fatalf("failed to checkpoint: %s", err)
+	}
+	if err := buffermanager.Default().DropFile(name); err != nil {
+		t.Fatalf("failed to drop the heap from the buffer pool: %s", err)
+	}
+	dropFreeSpaceMap(name)
+}
+
+// a rollback must not take back the pages the buffer pool wrote to the heap file during the transaction
+func TestRollbackKeepsPagesWrittenBack(t *testing.T) {
+	if err := CreateHeap("rollback"); err != nil {
+		t.Fatalf("failed to create the heap: %s", err)
+	}
+
+	rids := make([]RID, 3)
+	for i := range rids {
+		var err error
+		if rids[i], err = AddRowToHeap("rollback", testRow(byte(i+1), 3000)); err != nil {
+			t.Fatalf("failed to add row %d: %s", i, err)
+		}
+	}
+
+	tx, err := logmanager.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %s", err)
+	}
+	if _, err := AddRowToHeapTx(tx, "rollback", testRow(4, 3000)); err != nil {
+		t.Fatalf("failed to add the row of the transaction: %s", err)
+	}
+	// the same as an eviction of the pages during the transaction
+	if err := FlushHeap("rollback"); err != nil {
+		t.Fatalf("failed to flush the heap: %s", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to roll back: %s", err)
+	}
+
+	reopenHeap(t, "rollback")
+
+	for i, rid := range rids {
+		row, err := GetRowByRID("rollback", rid)
+		if err != nil {
+			t.Fatalf("failed to read row %d after the rollback: %s", i, err)
+		}
+		if !bytes.Equal(row, testRow(byte(i+1), 3000)) {
+			t.Fatalf("row %d changed after the rollback", i)
+		}
+	}
+}
+
+// a page is written to the heap file only after the log has the record of its last change
+func TestWriteBackFlushesLog(t *testing.T) {
+	createTestHeap(t, "writeback")
+	rid := addRow(t, "writeback", testRow(1, 100))
+	if err := logmanager.Checkpoint(); err != nil {
+		t.Fatalf("failed to checkpoint: %s", err)
+	}
+
+	// the record of the change stays in the buffer of the log until something flushes it
+	tx, err := logmanager.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %s", err)
+	}
+	defer tx.Rollback()
+	if err := UpdateRowInHeapTx(tx, "writeback", rid, testRow(2, 100)); err != nil {
+		t.Fatalf("failed to update the row: %s", err)
+	}
+	if err := FlushHeap("writeback"); err != nil {
+		t.Fatalf("failed to flush the heap: %s", err)
+	}
+
+	page := make([]byte, pageSize)
+	file, err := os.Open("writeback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.ReadAt(page, pageOffset(int(rid.PageID))); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(page, testRow(2, 100)) {
+		t.Fatal("the change of the page was not written to the heap file")
+	}
+
+	log, err := os.ReadFile("wal.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseLSN := logmanager.LSN(binary.BigEndian.Uint64(log[4:12]))
+	if lsn := getPageLSN(page); lsn <= baseLSN || int64(lsn-baseLSN) >= int64(len(log)) {
+		t.Fatalf("the page with lsn %d was written before its record, the log file ends at lsn %d",
+			lsn, baseLSN+logmanager.LSN(len(log)))
+	}
+}
@@ -0,0 +1,213 @@
+package indexmanager
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/keycodec"
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+// IndexKey is the key of an index entry, one value per column of the index in the order of its columns.
+// a key with fewer values than the columns of the index is a prefix, it matches every entry that starts with its values.
+// the key of a row added to an index with included columns is followed by the values of the included columns.
+// the values are compared byte by byte, TypedIndexKey encodes typed values so they sort like the values.
+type IndexKey [][]byte
+
+// IndexEntry is an entry of an index found by a lookup.
+type IndexEntry struct {
+	Key IndexKey
+	RID heapmanager.RID
+	// the values of the included columns of a covering index, in the order of the columns
+	Included IndexKey
+}
+
+// TypedIndexKey returns the key with the values of the given data types encoded by keycodec,
+// orders gives the order of each column and can be nil when all of them are ascending.
+func TypedIndexKey(dataTypes []types.DataType, values []any, orders []keycodec.Order) (IndexKey, error) {
+	if len(values) > len(dataTypes) || (orders != nil && len(orders) != len(dataTypes)) {
+		return nil, fmt.Errorf("got %d values and %d orders for %d data types", len(values), len(orders), len(dataTypes))
+	}
+
+	key := make(IndexKey, len(values))
+	for i, value := range values {
+		order := keycodec.Ascending
+		if orders != nil {
+			order = orders[i]
+		}
+
+		encoded, err := keycodec.Encode(dataTypes[i], value, order)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode value %d of the key: %w", i, err)
+		}
+		key[i] = encoded
+	}
+	return key, nil
+}
+
+// a unique index on one column that is not typed stores each key as it is, with the rid of its row as the value.
+// the other indexes encode the values of the key one after the other with keycodec.AppendBytes,
+// each value is followed by the terminator 0x00 0x01 and its 0x00 bytes are escaped as 0x00 0xFF:
+// | Escaped Value 1 | 0x00 0x01 | Escaped Value 2 | 0x00 0x01 | ...
+//
+// the terminator is smaller than any byte that can follow it in a longer value, so the encoded keys
+// keep the order of their values column by column, and the entries of a prefix of the key
+// are next to each other in the tree.
+//
+// a non-unique index can have the same key for many rows, and so can a unique typed index for a key
+// with a null, so the rid is added to the encoded key to make every entry of the tree different:
+// | Encoded Key | RID 6B |
+//
+// the value of an entry is the rid of its row, followed by the values of the included columns
+// of a covering index encoded like the keys:
+// | RID 6B | Escaped Included Value 1 | 0x00 0x01 | ...
+
+const ridSize = 6
+
+// the indexes written before the rows had rids stored the 4 bytes of the page of the row as the value
+const legacyValueSize = 4
+
+// ErrLegacyIndexEntry is returned when an entry of an index written before the rows had rids is read,
+// its value is the page of the row without the slot so the row cannot be found.
+// the index must be built again from its heap with RebuildIndex.
+var ErrLegacyIndexEntry = errors.New("the index stores page ids instead of rids, rebuild it from its heap")
+
+// the most columns an index can have
+const maxIndexColumns = 16
+
+// the index metadata needed to read and write its entries
+type indexInfo struct {
+	name    string
+	columns []string
+	include []string
+	unique  bool
+	typed   bool
+	kind    IndexKind
+}
+
+func (m IndexMetadata) info() indexInfo {
+	return indexInfo{name: m.Name, columns: m.Columns, include: m.Include, unique: m.Unique, typed: m.Typed, kind: m.Kind}
+}
+
+// reports whether the keys are stored as they are, without the encoding.
+// the keys of a typed index can be null and have the rid after them, so they are always encoded
+func (info indexInfo) rawKeys() bool {
+	return info.unique && !info.typed && len(info.columns) == 1
+}
+
+// returns the tree key of the entry of the row rid, the key must have a value for every column,
+// and may have the values of the included columns after them.
+// the key of a hash index never has the rid
+func (info indexInfo) entryKey(key IndexKey, rid heapmanager.RID) ([]byte, error) {
+	if len(key) != len(info.columns) && len(key) != len(info.columns)+len(info.include) {
+		return nil, fmt.Errorf("index %s has %d columns and %d included columns, the key has %d values", info.name, len(info.columns), len(info.include), len(key))
+	}
+	key = key[:len(info.columns)]
+
+	// the entries of a key that is not unique have the rid in their tree key, so they never collide
+	encoded := encodeIndexKey(key)
+	if info.rawKeys() {
+		encoded = key[0]
+	}
+	if !info.uniqueKey(key) && info.kind != HashIndex {
+		encoded = append(encoded[:len(encoded):len(encoded)], rid.Bytes()...)
+	}
+	return encoded, nil
+}
+
+// reports whether the index has one entry at most for the key, the key of a unique typed index is not unique
+// when one of its values is null: as in SQL a null is never equal to another null
+func (info indexInfo) uniqueKey(key IndexKey) bool {
+	if !info.unique {
+		return false
+	}
+	return !info.typed || !slices.ContainsFunc(key[:min(len(key), len(info.columns))], isNullValue)
+}
+
+// reports whether the value of a typed key is the null of keycodec, in ascending or descending order
+func isNullValue(value []byte) bool {
+	return len(value) == 1 && (keycodec.IsNull(value, keycodec.Ascending) || keycodec.IsNull(value, keycodec.Descending))
+}
+
+// returns the value of the entry of the row rid, the key must have the values of the included columns
+func (info indexInfo) entryValue(key IndexKey, rid heapmanager.RID) ([]byte, error) {
+	if len(key) != len(info.columns)+len(info.include) {
+		return nil, fmt.Errorf("index %s has %d columns and %d included columns, the key has %d values", info.name, len(info.columns), len(info.include), len(key))
+	}
+
+	value := rid.Bytes()
+	for _, included := range key[len(info.columns):] {
+		value = keycodec.AppendBytes(value, included)
+	}
+	if len(value) > math.MaxUint16 {
+		return nil, fmt.Errorf("the included values of index %s take %d bytes, more than %d", info.name, len(value)-ridSize, math.MaxUint16-ridSize)
+	}
+	return value, nil
+}
+
+// returns the rid and the values of the included columns of the value of an entry
+func (info indexInfo) decodeEntryValue(value []byte) (heapmanager.RID, IndexKey, error) {
+	if len(value) == legacyValueSize {
+		return heapmanager.RID{}, nil, fmt.Errorf("index %s: %w", info.name, ErrLegacyIndexEntry)
+	}
+	if len(value) < ridSize {
+		return heapmanager.RID{}, nil, fmt.Errorf("the value of an entry of index %s has %d bytes", info.name, len(value))
+	}
+	rid, err := heapmanager.RIDFromBytes(value[:ridSize])
+	if err != nil {
+		return heapmanager.RID{}, nil, err
+	}
+
+	included := make(IndexKey, len(info.include))
+	rest := value[ridSize:]
+	for i := range included {
+		included[i], rest, err = keycodec.DecodeBytes(rest)
+		if err != nil {
+			return heapmanager.RID{}, nil, fmt.Errorf("failed to decode included column %s of index %s: %w", info.include[i], info.name, err)
+		}
+	}
+	return rid, included, nil
+}
+
+// returns the key of the tree key of an entry
+func (info indexInfo) decodeEntryKey(entryKey []byte) IndexKey {
+	if info.rawKeys() {
+		return IndexKey{entryKey}
+	}
+
+	key := make(IndexKey, len(info.columns))
+	for i := range key {
+		//the keys were encoded by the index, they are always valid
+		key[i], entryKey, _ = keycodec.DecodeBytes(entryKey)
+	}
+	return key
+}
+
+// returns the tree key of the entry without the rid that the entries of a key that is not unique have
+func (info indexInfo) entryKeyWithoutRID(entryKey []byte) []byte {
+	key := info.decodeEntryKey(entryKey)
+	if info.rawKeys() {
+		return key[0]
+	}
+	return encodeIndexKey(key)
+}
+
+// encodes the values of the key one after the other, each one escaped and terminated
+func encodeIndexKey(key IndexKey) []byte {
+	encoded := make([]byte, 0)
+	for _, value := range key {
+		encoded = keycodec.AppendBytes(encoded, value)
+	}
+	return encoded
+}
+
+// returns the smallest tree key that is after all the entries that start with the encoded key,
+// an encoded key always ends with the terminator so its last byte can be increased
+func encodedKeyEnd(encoded []byte) []byte {
+	end := append([]byte{}, encoded...)
+	end[len(end)-1]++
+	return end
+}
@@ -0,0 +1,130 @@
+package indexmanager
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/keycodec"
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+// reads the keys and the rids of the range
+func scanRange(t *testing.T, table string, index string, options ScanOptions) ([]IndexKey, []heapmanager.RID) {
+	t.Helper()
+
+	it, err := ScanIndexRange(table, index, options)
+	if err != nil {
+		t.Fatalf("failed to scan %s: %s", index, err)
+	}
+	defer it.Close()
+
+	keys := make([]IndexKey, 0)
+	rids := make([]heapmanager.RID, 0)
+	for it.Next() {
+		keys = append(keys, it.Key())
+		rids = append(rids, it.RID())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("failed to scan %s: %s", index, err)
+	}
+	return keys, rids
+}
+
+// the keys of an index that is not typed are bytes, the ones that start like the null of keycodec are not null
+func TestRawKeysAreNeverNull(t *testing.T) {
+	createTestIndex(t, "raw", "raw_key", IndexOptions{Columns: []string{"key"}, Unique: true})
+
+	keys := []string{"\x01", "\x01abc", "\xFE", "\xFEz"}
+	for i, key := range keys {
+		addEntry(t, "raw", []IndexKey{testKey(key)}, testRID(i))
+	}
+	if err := AddEntryToTableIndexes("raw", []IndexKey{testKey("\x01")}, testRID(10)); err == nil {
+		t.Fatal("a unique index that is not typed must reject a second \\x01")
+	}
+	if err := AddEntryToTableIndexes("raw", []IndexKey{testKey("\xFE")}, testRID(11)); err == nil {
+		t.Fatal("a unique index that is not typed must reject a second \\xFE")
+	}
+
+	for i, key := range keys {
+		checkLookup(t, "raw", "raw_key", testKey(key), testRID(i))
+	}
+
+	scanned, _ := scanRange(t, "raw", "raw_key", ScanOptions{})
+	if len(scanned) != len(keys) {
+		t.Fatalf("the scan read %d keys, expected %d", len(scanned), len(keys))
+	}
+	for i, key := range scanned {
+		if !bytes.Equal(key[0], []byte(keys[i])) {
+			t.Fatalf("the scan read the key %q, expected %q", key[0], keys[i])
+		}
+	}
+
+	// the bounds are compared as bytes
+	scanned, _ = scanRange(t, "raw", "raw_key", ScanOptions{Start: testKey("\x01"), StartExclusive: true, End: testKey("\xFE")})
+	if len(scanned) != 2 || string(scanned[0][0]) != "\x01abc" || string(scanned[1][0]) != "\xFE" {
+		t.Fatalf("the scan after \\x01 up to \\xFE read %q", scanned)
+	}
+
+	stats, err := AnalyzeIndex("raw", "raw_key")
+	if err != nil {
+		t.Fatalf("failed to analyze the index: %s", err)
+	}
+	if stats.DistinctKeys != len(keys) {
+		t.Fatalf("the index has %d distinct keys, expected %d", stats.DistinctKeys, len(keys))
+	}
+}
+
+// returns the typed key of the int, nil is null
+func intKey(t *testing.T, value any) IndexKey {
+	t.Helper()
+
+	key, err := TypedIndexKey([]types.DataType{types.Int}, []any{value}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// as in SQL a null is never equal to another null, a unique typed index keeps every key with a null
+func TestTypedNullKeys(t *testing.T) {
+	for _, kind := range []IndexKind{BTreeIndex, HashIndex} {
+		index := "typed_" + kind.String()
+		createTestIndex(t, "typed", index, IndexOptions{Columns: []string{"id"}, Unique: true, Typed: true, Kind: kind})
+
+		addEntry(t, "typed", []IndexKey{intKey(t, nil)}, testRID(1))
+		addEntry(t, "typed", []IndexKey{intKey(t, nil)}, testRID(2))
+		addEntry(t, "typed", []IndexKey{intKey(t, 1)}, testRID(3))
+		if err := AddEntryToTableIndexes("typed", []IndexKey{intKey(t, 1)}, testRID(4)); err == nil {
+			t.Fatalf("%s must reject a second 1", index)
+		}
+
+		checkLookup(t, "typed", index, intKey(t, nil), testRID(1), testRID(2))
+		checkLookup(t, "typed", index, intKey(t, 1), testRID(3))
+
+		removeEntry(t, "typed", []IndexKey{intKey(t, nil)}, testRID(1))
+		checkLookup(t, "typed", index, intKey(t, nil), testRID(2))
+
+		// the tests share the table, the entries of the index are removed for the next kind
+		if err := DeleteIndex("typed", index); err != nil {
+			t.Fatalf("failed to delete %s: %s", index, err)
+		}
+	}
+}
+
+func TestTypedNullRange(t *testing.T) {
+	createTestIndex(t, "typedrange", "typedrange_id", IndexOptions{Columns: []string{"id"}, Unique: true, Typed: true})
+	for i, value := range []any{nil, nil, int64(-1), int64(0), int64(5)} {
+		addEntry(t, "typedrange", []IndexKey{intKey(t, value)}, testRID(i))
+	}
+
+	// the nulls sort first, a bound on null includes or excludes all of them
+	_, rids := scanRange(t, "typedrange", "typedrange_id", ScanOptions{Start: intKey(t, nil), StartExclusive: true})
+	if len(rids) != 3 || rids[0] != testRID(2) {
+		t.Fatalf("the scan after null read %v", rids)
+	}
+	keys, rids := scanRange(t, "typedrange", "typedrange_id", ScanOptions{End: intKey(t, nil)})
+	if len(rids) != 2 || !keycodec.IsNull(keys[0][0], keycodec.Ascending) || !keycodec.IsNull(keys[1][0], keycodec.Ascending) {
+		t.Fatalf("the scan up to null read %q", keys)
+	}
+}
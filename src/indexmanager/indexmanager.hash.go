@@ -0,0 +1,211 @@
+package indexmanager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/hashindex"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// IndexKind is the data structure an index keeps its entries in, it is chosen when the index is created.
+type IndexKind uint8
+
+const (
+	// BTreeIndex keeps the entries in key order in a B+ tree, it supports lookups, prefixes and range scans
+	BTreeIndex IndexKind = iota
+	// HashIndex keeps the entries in an extendible hash table, it only supports lookups of a full key
+	// but reads one bucket for them whatever the size of the index
+	HashIndex
+)
+
+func (kind IndexKind) String() string {
+	switch kind {
+	case BTreeIndex:
+		return "btree"
+	case HashIndex:
+		return "hash"
+	}
+	return fmt.Sprintf("IndexKind(%d)", uint8(kind))
+}
+
+// ErrHashIndexScan is returned by the operations that need the keys in order
+// (range scans, prefix lookups) when they are used on a hash index.
+var ErrHashIndexScan = errors.New("a hash index only supports lookups of a full key")
+
+// a hash index stores the key of an entry like a B+ tree, except that a non-unique index does not add the rid to it:
+// the entries of a key are all in the same bucket and the hash table keeps many values for a key.
+
+// opens the hash table of an index, the writes to its file are logged by tx, or not logged when tx is nil
+func openIndexHash(tx *logmanager.Transaction, indexPath string) (*hashindex.HashIndex, error) {
+	openFile := func(path string, flag int, perm os.FileMode) (hashindex.File, error) {
+		if tx == nil {
+			return os.OpenFile(path, flag, perm)
+		}
+		return tx.OpenFile(path, flag, perm)
+	}
+
+	hash, err := hashindex.Open(indexPath, hashindex.PageSize(indexPageSize), hashindex.OpenFile(openFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hash index %s: %w", indexPath, err)
+	}
+	return hash, nil
+}
+
+// adds the entry to the hash index, the value starts with the rid of its row.
+// unique tells whether the key must not be in the index yet
+func addEntryToHashIndex(tx *logmanager.Transaction, tableName string, index indexInfo, key []byte, value []byte, unique bool) error {
+	indexPath := path.Join("indexes", tableName, index.name+".data")
+	hash, err := openIndexHash(tx, indexPath)
+	if err != nil {
+		return err
+	}
+	defer hash.Close()
+
+	if unique {
+		values, err := hash.Get(key)
+		if err != nil {
+			return fmt.Errorf("failed to get value: %w", err)
+		}
+		if len(values) > 0 {
+			return fmt.Errorf("the key already exists in the index")
+		}
+	}
+
+	if err := hash.Put(key, value); err != nil {
+		return fmt.Errorf("failed to insert value: %w", err)
+	}
+	return nil
+}
+
+// removes the entry of the row rid from the hash index, whatever the values of its included columns
+func removeEntryFromHashIndex(tx *logmanager.Transaction, tableName string, index indexInfo, key []byte, rid heapmanager.RID) error {
+	indexPath := path.Join("indexes", tableName, index.name+".data")
+	hash, err := openIndexHash(tx, indexPath)
+	if err != nil {
+		return err
+	}
+	defer hash.Close()
+
+	values, err := hash.Get(key)
+	if err != nil {
+		return fmt.Errorf("failed to get value: %w", err)
+	}
+	for _, value := range values {
+		if bytes.HasPrefix(value, rid.Bytes()) {
+			if _, err := hash.Delete(key, value); err != nil {
+				return fmt.Errorf("failed to delete value: %w", err)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// returns the entries of the full key in the hash index
+func findHashIndexEntries(tableName string, index indexInfo, key IndexKey) ([]IndexEntry, error) {
+	if len(key) != len(index.columns) {
+		return nil, fmt.Errorf("index %s has %d columns, the key has %d values: %w", index.name, len(index.columns), len(key), ErrHashIndexScan)
+	}
+
+	// the rid is not part of the key of a hash index
+	hashKey, err := index.entryKey(key, heapmanager.RID{})
+	if err != nil {
+		return nil, err
+	}
+
+	indexPath := path.Join("indexes", tableName, index.name+".data")
+	hash, err := openIndexHash(nil, indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer hash.Close()
+
+	values, err := hash.Get(hashKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get value: %w", err)
+	}
+
+	entries := make([]IndexEntry, 0, len(values))
+	for _, value := range values {
+		rid, included, err := index.decodeEntryValue(value)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, IndexEntry{Key: key, RID: rid, Included: included})
+	}
+	return entries, nil
+}
+
+// builds a new hash index file with the entries of the sorter, like buildIndexTree
+func buildIndexHash(hashPath string, info indexInfo, sorter *entrySorter) error {
+	if err := os.Remove(hashPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	next, err := sorter.sorted()
+	if err != nil {
+		return err
+	}
+
+	hash, err := hashindex.Open(hashPath, hashindex.PageSize(indexPageSize))
+	if err != nil {
+		return fmt.Errorf("failed to open hash index %s: %w", hashPath, err)
+	}
+
+	var previous sortEntry
+	for read := 0; read < sorter.count; read++ {
+		entry, err := next()
+		if err != nil {
+			hash.Close()
+			return err
+		}
+
+		//the sorted entries of the same key are next to each other
+		if read > 0 && bytes.Equal(previous.key, entry.key) && info.uniqueKey(info.decodeEntryKey(entry.key)) {
+			hash.Close()
+			return fmt.Errorf("rows %v and %v have the same key in unique index %s", previous.rid, entry.rid, info.name)
+		}
+		previous = entry
+
+		if err := hash.Put(entry.key, entry.value()); err != nil {
+			hash.Close()
+			return err
+		}
+	}
+
+	// closing the index syncs its file
+	return hash.Close()
+}
+
+// reads all the buckets of the hash index and returns its statistics,
+// a hash table has no height and no histogram, the pages of its buckets are counted as leaves
+func analyzeHash(tableName string, info indexInfo) (IndexStats, error) {
+	indexPath := path.Join("indexes", tableName, info.name+".data")
+	hash, err := openIndexHash(nil, indexPath)
+	if err != nil {
+		return IndexStats{}, err
+	}
+	defer hash.Close()
+
+	shape, err := hash.Stats()
+	if err != nil {
+		return IndexStats{}, err
+	}
+
+	return IndexStats{
+		Keys: shape.Keys,
+		IndexAnalysis: IndexAnalysis{
+			Analyzed:     true,
+			LeafNodes:    shape.Pages,
+			Fill:         shape.Fill,
+			DistinctKeys: shape.DistinctKeys,
+			Histogram:    make([]HistogramBucket, 0),
+		},
+		index: info,
+	}, nil
+}
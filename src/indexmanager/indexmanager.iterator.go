@@ -0,0 +1,244 @@
+package indexmanager
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/fbptree"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+)
+
+// ScanOptions describes the range of keys read by ScanIndexRange.
+// the zero value reads the whole index in ascending order.
+type ScanOptions struct {
+	// the bounds of the range, a nil bound leaves the range open on that side.
+	// a bound of a composite index can be a prefix, it includes or excludes all the keys that start with it
+	Start IndexKey
+	End   IndexKey
+	// the bounds are included unless they are marked as exclusive
+	StartExclusive bool
+	EndExclusive   bool
+	// reads the range from End down to Start
+	Reverse bool
+	// the maximum number of entries to read, 0 means no limit
+	Limit int
+}
+
+// IndexIterator reads the entries of an index range one by one, in key order.
+//
+//	it, err := ScanIndexRange("student", "id_idx", ScanOptions{Start: IndexKey{from}, End: IndexKey{to}})
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//
+//	for it.Next() {
+//		key, rid := it.Key(), it.RID()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+//
+// the entries are read from the tree as the loop goes, the loop can be stopped at any time.
+// the index must not be changed while the iterator is open.
+type IndexIterator struct {
+	tree    *fbptree.FBPTree
+	cursor  *fbptree.Cursor
+	options ScanOptions
+	index   indexInfo
+	// the range of the tree keys of the entries to read
+	treeRange keyRange
+
+	started bool
+	done    bool
+	count   int
+
+	key      IndexKey
+	rid      heapmanager.RID
+	included IndexKey
+	err      error
+}
+
+// ScanIndexRange opens the index and returns an iterator over its entries within the range of options.
+// the entries of a non-unique index with the same key are read in the order of their rids.
+// a hash index has no order and returns ErrHashIndexScan.
+func ScanIndexRange(tableName string, indexName string, options ScanOptions) (*IndexIterator, error) {
+	indexMetadata, err := getIndexMetadata(tableName, indexName)
+	if err != nil {
+		return nil, err
+	}
+	if indexMetadata.Kind == HashIndex {
+		return nil, fmt.Errorf("index %s is a hash index: %w", indexName, ErrHashIndexScan)
+	}
+	index := indexMetadata.info()
+
+	treeRange, err := index.keyRange(options)
+	if err != nil {
+		return nil, err
+	}
+
+	indexPath := path.Join("indexes", tableName, indexName+".data")
+	tree, err := fbptree.Open(indexPath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open B+ tree %s: %w", indexPath, err)
+	}
+
+	return &IndexIterator{tree: tree, cursor: tree.Cursor(), options: options, index: index, treeRange: treeRange}, nil
+}
+
+// a range of tree keys, a nil bound leaves the range open on that side
+type keyRange struct {
+	start, end                   []byte
+	startExclusive, endExclusive bool
+}
+
+// returns the range of the tree keys that holds the entries of the keys in the range of options.
+// a bound of an encoded index becomes the first entry that starts with it, or the first one after them
+// when it is excluded from the start or included in the end, so the end of the range is always exclusive.
+func (info indexInfo) keyRange(options ScanOptions) (keyRange, error) {
+	for _, bound := range []IndexKey{options.Start, options.End} {
+		if len(bound) > len(info.columns) {
+			return keyRange{}, fmt.Errorf("index %s has %d columns, the bound has %d values", info.name, len(info.columns), len(bound))
+		}
+	}
+
+	if info.rawKeys() {
+		r := keyRange{startExclusive: options.StartExclusive, endExclusive: options.EndExclusive}
+		if len(options.Start) > 0 {
+			r.start = options.Start[0]
+		}
+		if len(options.End) > 0 {
+			r.end = options.End[0]
+		}
+		return r, nil
+	}
+
+	r := keyRange{endExclusive: true}
+	if len(options.Start) > 0 {
+		r.start = encodeIndexKey(options.Start)
+		if options.StartExclusive {
+			r.start = encodedKeyEnd(r.start)
+		}
+	}
+	if len(options.End) > 0 {
+		r.end = encodeIndexKey(options.End)
+		if !options.EndExclusive {
+			r.end = encodedKeyEnd(r.end)
+		}
+	}
+	return r, nil
+}
+
+// Next reads the next entry of the range, it returns false when the range is over or an error happened.
+func (it *IndexIterator) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+	if it.options.Limit > 0 && it.count >= it.options.Limit {
+		it.done = true
+		return false
+	}
+
+	var ok bool
+	switch {
+	case !it.started:
+		it.started = true
+		ok = it.seekFirst()
+	case it.options.Reverse:
+		ok = it.cursor.Prev()
+	default:
+		ok = it.cursor.Next()
+	}
+
+	if !ok || !it.inRange(it.cursor.Key()) {
+		it.err = it.cursor.Err()
+		it.done = true
+		return false
+	}
+
+	rid, included, err := it.index.decodeEntryValue(it.cursor.Value())
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.key, it.rid, it.included = it.index.decodeEntryKey(it.cursor.Key()), rid, included
+	it.count++
+	return true
+}
+
+// Key returns the key of the entry read by the last call to Next.
+func (it *IndexIterator) Key() IndexKey {
+	return it.key
+}
+
+// RID returns the rid of the entry read by the last call to Next.
+func (it *IndexIterator) RID() heapmanager.RID {
+	return it.rid
+}
+
+// Included returns the values of the included columns of the entry read by the last call to Next,
+// a query that only needs them and the key does not have to read the row from the heap.
+func (it *IndexIterator) Included() IndexKey {
+	return it.included
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *IndexIterator) Err() error {
+	return it.err
+}
+
+// Close closes the index file.
+func (it *IndexIterator) Close() error {
+	return it.tree.Close()
+}
+
+// moves the cursor to the first entry of the range in the order of the scan
+func (it *IndexIterator) seekFirst() bool {
+	r := it.treeRange
+
+	if !it.options.Reverse {
+		if !it.cursor.Seek(r.start) {
+			return false
+		}
+		if r.start != nil && r.startExclusive && bytes.Equal(it.cursor.Key(), r.start) {
+			return it.cursor.Next()
+		}
+		return true
+	}
+
+	if r.end == nil {
+		return it.cursor.SeekLast()
+	}
+
+	//the last entry of the range is the one before the first key after End
+	if !it.cursor.Seek(r.end) {
+		return it.cursor.Err() == nil && it.cursor.SeekLast()
+	}
+	cmp := bytes.Compare(it.cursor.Key(), r.end)
+	if cmp > 0 || (cmp == 0 && r.endExclusive) {
+		return it.cursor.Prev()
+	}
+	return true
+}
+
+// reports whether the key is before the bound at which the scan stops
+func (it *IndexIterator) inRange(key []byte) bool {
+	r := it.treeRange
+
+	if !it.options.Reverse {
+		if r.end == nil {
+			return true
+		}
+		cmp := bytes.Compare(key, r.end)
+		return cmp < 0 || (cmp == 0 && !r.endExclusive)
+	}
+
+	if r.start == nil {
+		return true
+	}
+	cmp := bytes.Compare(key, r.start)
+	return cmp > 0 || (cmp == 0 && !r.startExclusive)
+}
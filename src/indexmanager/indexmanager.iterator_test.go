@@ -0,0 +1,109 @@
+package indexmanager
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/keycodec"
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+// returns the typed key of the ints, a prefix of the columns in orders (all ascending when it is nil)
+func intsKey(t *testing.T, orders []keycodec.Order, values ...any) IndexKey {
+	t.Helper()
+
+	dataTypes := make([]types.DataType, max(len(orders), len(values)))
+	for i := range dataTypes {
+		dataTypes[i] = types.Int
+	}
+	key, err := TypedIndexKey(dataTypes, values, orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// checks the rids read by the scan, in order
+func checkScan(t *testing.T, table string, index string, options ScanOptions, expected ...heapmanager.RID) {
+	t.Helper()
+
+	if _, rids := scanRange(t, table, index, options); !slices.Equal(rids, expected) {
+		t.Fatalf("the scan %+v of %s read %v, expected %v", options, index, rids, expected)
+	}
+}
+
+// a non-unique index keeps an entry for every row with the key, a lookup or a scan returns all of them
+func TestNonUniqueIndex(t *testing.T) {
+	createTestIndex(t, "nonunique", "nonunique_name", IndexOptions{Columns: []string{"name"}})
+	addEntry(t, "nonunique", []IndexKey{testKey("ann")}, testRID(301))
+	addEntry(t, "nonunique", []IndexKey{testKey("bob")}, testRID(2))
+	addEntry(t, "nonunique", []IndexKey{testKey("ann")}, testRID(1))
+	addEntry(t, "nonunique", []IndexKey{testKey("ann")}, testRID(7))
+
+	checkLookup(t, "nonunique", "nonunique_name", testKey("ann"), testRID(1), testRID(7), testRID(301))
+	checkLookup(t, "nonunique", "nonunique_name", testKey("bob"), testRID(2))
+
+	// the entries of a key are read in the order of their rids
+	checkScan(t, "nonunique", "nonunique_name", ScanOptions{}, testRID(1), testRID(7), testRID(301), testRID(2))
+	checkScan(t, "nonunique", "nonunique_name", ScanOptions{Reverse: true}, testRID(2), testRID(301), testRID(7), testRID(1))
+
+	// a bound holds all the entries of its key
+	checkScan(t, "nonunique", "nonunique_name", ScanOptions{Start: testKey("ann"), StartExclusive: true}, testRID(2))
+	checkScan(t, "nonunique", "nonunique_name", ScanOptions{End: testKey("bob"), EndExclusive: true, Reverse: true},
+		testRID(301), testRID(7), testRID(1))
+	checkScan(t, "nonunique", "nonunique_name", ScanOptions{Start: testKey("ann"), Limit: 2}, testRID(1), testRID(7))
+
+	removeEntry(t, "nonunique", []IndexKey{testKey("ann")}, testRID(7))
+	checkLookup(t, "nonunique", "nonunique_name", testKey("ann"), testRID(1), testRID(301))
+}
+
+// the bounds of a composite index can be prefixes of its keys, an exclusive one leaves out every key that starts with it
+func TestScanExclusiveBounds(t *testing.T) {
+	createTestIndex(t, "bounds", "bounds_a_b", IndexOptions{Columns: []string{"a", "b"}, Typed: true})
+
+	// the row 10*a+b has the key (a, b), a goes from 1 to 3 and b from 1 to 2
+	for a := 1; a <= 3; a++ {
+		for b := 1; b <= 2; b++ {
+			addEntry(t, "bounds", []IndexKey{intsKey(t, nil, a, b)}, testRID(10*a+b))
+		}
+	}
+
+	checkScan(t, "bounds", "bounds_a_b", ScanOptions{Start: intsKey(t, nil, 1), End: intsKey(t, nil, 3), StartExclusive: true, EndExclusive: true},
+		testRID(21), testRID(22))
+	checkScan(t, "bounds", "bounds_a_b", ScanOptions{Start: intsKey(t, nil, 1), End: intsKey(t, nil, 2)},
+		testRID(11), testRID(12), testRID(21), testRID(22))
+	checkScan(t, "bounds", "bounds_a_b", ScanOptions{Start: intsKey(t, nil, 1, 2), End: intsKey(t, nil, 3, 1), StartExclusive: true, EndExclusive: true},
+		testRID(21), testRID(22))
+	checkScan(t, "bounds", "bounds_a_b", ScanOptions{Start: intsKey(t, nil, 2), StartExclusive: true, Reverse: true},
+		testRID(32), testRID(31))
+	checkScan(t, "bounds", "bounds_a_b", ScanOptions{End: intsKey(t, nil, 2, 1), EndExclusive: true, Reverse: true},
+		testRID(12), testRID(11))
+	checkScan(t, "bounds", "bounds_a_b", ScanOptions{Start: intsKey(t, nil, 2, 2), End: intsKey(t, nil, 2, 2), StartExclusive: true})
+
+	if _, err := ScanIndexRange("bounds", "bounds_a_b", ScanOptions{Start: intsKey(t, nil, 1, 2, 3)}); err == nil {
+		t.Fatal("a bound with more values than the columns of the index must be refused")
+	}
+}
+
+// a descending column is encoded in descending order, so its entries are read from the largest value
+// and its bounds are given in the order of the tree
+func TestScanDescendingColumn(t *testing.T) {
+	createTestIndex(t, "descending", "descending_a_b", IndexOptions{Columns: []string{"a", "b"}, Typed: true})
+	orders := []keycodec.Order{keycodec.Ascending, keycodec.Descending}
+
+	for a := 1; a <= 2; a++ {
+		for b := 1; b <= 3; b++ {
+			addEntry(t, "descending", []IndexKey{intsKey(t, orders, a, b)}, testRID(10*a+b))
+		}
+	}
+	addEntry(t, "descending", []IndexKey{intsKey(t, orders, 1, nil)}, testRID(100))
+
+	// b goes down within each a, the null of a descending column sorts last
+	checkScan(t, "descending", "descending_a_b", ScanOptions{Start: intsKey(t, orders, 1), End: intsKey(t, orders, 1)},
+		testRID(13), testRID(12), testRID(11), testRID(100))
+	checkScan(t, "descending", "descending_a_b", ScanOptions{Start: intsKey(t, orders, 2, 3), End: intsKey(t, orders, 2, 1), StartExclusive: true, EndExclusive: true},
+		testRID(22))
+	checkScan(t, "descending", "descending_a_b", ScanOptions{Start: intsKey(t, orders, 2, 2), Reverse: true},
+		testRID(21), testRID(22))
+}
@@ -1,28 +1,95 @@
 package indexmanager
 
 import (
-	"encoding/binary"
 	"fmt"
 	"os"
 	"path"
-	"strings"
+	"slices"
 	"sync"
-	"github.com/krasun/fbptree"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/fbptree"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
 )
 
 const (
-	// IndexRebuildThreshold = 30
-	indexPageSize     = 4096
-	indexMetadataSize = 52
-	metaDataFileName  = "meta.data"
-	indexOrder        = 128
+	indexPageSize    = 4096
+	metaDataFileName = "meta.data"
+	indexOrder       = 128
+
+	// the bits of the flags of the index metadata
+	indexFlagUnique   = 1 << 0
+	indexFlagAnalyzed = 1 << 1
+	indexFlagTyped    = 1 << 2
 )
 
 var metaFilEMutex sync.Mutex
 
-// InitializeIndex creates a new index for a given table and index name.
-// init the index metadata and data structures
-func InitializeIndex(tableName string, indexName string, ColumnName string, clustered bool) error {
+// the tables whose index builds were installed by the first read of their metadata, see installIndexBuilds.
+// it is guarded by metaFilEMutex
+var installedTables = make(map[string]bool)
+
+// IndexOptions describes the index created by InitializeIndex.
+//
+//	indexmanager.InitializeIndex("student", "name_idx", indexmanager.IndexOptions{Columns: []string{"lastName", "firstName"}})
+type IndexOptions struct {
+	// the columns of the index in order, a composite index has more than one column
+	Columns []string
+	// the columns whose values are stored in the entries next to the rid of their row (a covering index),
+	// they are returned by the lookups and the scans but cannot be searched. it can be empty
+	Include []string
+	// a unique index rejects a key that is already in it, a non-unique one keeps an entry for every row with the key
+	Unique bool
+	// the values of the keys are typed values encoded by keycodec (see TypedIndexKey), so a null can be told
+	// from the other values and a unique index keeps every key with a null. the values of the keys of an index
+	// that is not typed are bytes, none of them is null
+	Typed bool
+	// the data structure of the index, a B+ tree by default. a hash index only supports lookups of a full key
+	Kind IndexKind
+}
+
+// InitializeIndex creates an empty index of the table described by options (its columns, included columns,
+// uniqueness, typed keys and kind) and adds them to the metadata of the table's indexes.
+func InitializeIndex(tableName string, indexName string, options IndexOptions) error {
+	if err := checkIndexOptions(indexName, options); err != nil {
+		return err
+	}
+
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(initializeIndex(tx, tableName, indexName, options))
+}
+
+// InitializeIndexTx creates the empty index like InitializeIndex, the writes are logged by tx.
+func InitializeIndexTx(tx *logmanager.Transaction, tableName string, indexName string, options IndexOptions) error {
+	if err := checkIndexOptions(indexName, options); err != nil {
+		return err
+	}
+	return initializeIndex(tx, tableName, indexName, options)
+}
+
+// checks that the options describe an index that can be created
+func checkIndexOptions(indexName string, options IndexOptions) error {
+	if len(options.Columns) == 0 || len(options.Columns) > maxIndexColumns {
+		return fmt.Errorf("an index must have between 1 and %d columns", maxIndexColumns)
+	}
+	if len(options.Include) > maxIndexColumns {
+		return fmt.Errorf("an index can include %d columns at most", maxIndexColumns)
+	}
+	for _, column := range options.Include {
+		if slices.Contains(options.Columns, column) {
+			return fmt.Errorf("column %s is both a column and an included column of index %s", column, indexName)
+		}
+	}
+	if options.Kind != BTreeIndex && options.Kind != HashIndex {
+		return fmt.Errorf("unknown index kind %v", options.Kind)
+	}
+	return nil
+}
+
+func initializeIndex(tx *logmanager.Transaction, tableName string, indexName string, options IndexOptions) error {
 	// Construct index directory path
 	indexDir := path.Join("indexes", tableName)
 
@@ -37,94 +104,101 @@ func InitializeIndex(tableName string, indexName string, ColumnName string, clus
 	indexPath := path.Join(indexDir, indexName+".data")
 	fmt.Println(indexPath)
 
-	if _, err := fbptree.Open(indexPath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder)); err != nil {
-		return fmt.Errorf("failed to open B+ tree %s: %w", indexPath, err)
-	}
-
-	// Check if the metadata file exists, if not, it is a clustered index
-	metaDataPath := path.Join(indexDir, metaDataFileName)
-	if _, err := os.Stat(metaDataPath); os.IsNotExist(err) {
-		metaFile, err := os.Create(metaDataPath)
+	if options.Kind == HashIndex {
+		hash, err := openIndexHash(tx, indexPath)
 		if err != nil {
-			return fmt.Errorf("failed to create metadata file: %w", err)
+			return err
 		}
-		defer metaFile.Close()
-
-		// Write table name and index number to the header
-		header := make([]byte, 24)
-		copy(header[:20], []byte(tableName))
-		binary.BigEndian.PutUint32(header[20:], uint32(0))
-
-		// Write the header to the file
-		if _, err := metaFile.WriteAt(header, 0); err != nil {
-			return fmt.Errorf("error writing header to metadata file: %w", err)
+		hash.Close()
+	} else {
+		tree, err := openIndexTree(tx, indexPath)
+		if err != nil {
+			return err
 		}
+		tree.Close()
 	}
 
-	// Open the metadata file
-	metaFile, err := os.OpenFile(metaDataPath, os.O_RDWR, 0644)
-	if err != nil {
-		return fmt.Errorf("error opening the metadata file: %w", err)
-	}
-	defer metaFile.Close()
+	return addIndexMetadata(tx, tableName, newIndexMetadata(indexName, options))
+}
 
-	// Lock mutex to synchronize access to metadata file
-	metaFilEMutex.Lock()
-	defer metaFilEMutex.Unlock()
+// returns the metadata of a new index with the options
+func newIndexMetadata(indexName string, options IndexOptions) IndexMetadata {
+	return IndexMetadata{Name: indexName, Columns: options.Columns, Include: options.Include, Unique: options.Unique, Typed: options.Typed, Kind: options.Kind}
+}
 
-	// Read the header
-	header := make([]byte, 24)
-	if _, err := metaFile.ReadAt(header, 0); err != nil {
-		return fmt.Errorf("error reading the metadata file: %w", err)
+// adds the metadata of a new index to the metadata file of the table, the directory of the indexes of the table must exist
+func addIndexMetadata(tx *logmanager.Transaction, tableName string, indexMetadata IndexMetadata) error {
+	// the first index of the table creates its metadata file, without indexes
+	metaDataPath := path.Join("indexes", tableName, metaDataFileName)
+	if _, err := os.Stat(metaDataPath); os.IsNotExist(err) {
+		metaFilEMutex.Lock()
+		err := writeIndexesMetadata(tx, tableName, nil)
+		metaFilEMutex.Unlock()
+		if err != nil {
+			return err
+		}
 	}
 
-	// Get the number of indexes
-	indexesCount := binary.BigEndian.Uint32(header[20:])
-
-	// Write the index metadata to the file
-	indexMetadataBytes := make([]byte, indexMetadataSize)
-	copy(indexMetadataBytes[:20], []byte(indexName))
-	copy(indexMetadataBytes[20:40], []byte(ColumnName))
-	binary.BigEndian.PutUint32(indexMetadataBytes[40:44], uint32(0))
-	binary.BigEndian.PutUint32(indexMetadataBytes[44:48], uint32(0))
-	binary.BigEndian.PutUint32(indexMetadataBytes[48:52], uint32(0))
+	// the options are kept in the metadata of the index, appended to the file
+	// the changes are on the disk once the log of the transaction is flushed by the commit
+	return changeIndexesMetadata(tx, tableName, func(indexes []IndexMetadata) ([]IndexMetadata, error) {
+		for _, index := range indexes {
+			if index.Name == indexMetadata.Name {
+				return nil, fmt.Errorf("index %s of table %s already exists", indexMetadata.Name, tableName)
+			}
+		}
 
-	// Write the index metadata to the file
-	if _, err := metaFile.WriteAt(indexMetadataBytes, int64(24+indexesCount*indexMetadataSize)); err != nil {
-		return fmt.Errorf("error writing index metadata to metadata file: %w", err)
-	}
+		return append(indexes, indexMetadata), nil
+	})
+}
 
-	// Update the header
-	binary.BigEndian.PutUint32(header[20:], indexesCount+1)
-	if _, err := metaFile.WriteAt(header, 0); err != nil {
-		return fmt.Errorf("error updating header of metadata file: %w", err)
+// opens the B+ tree of an index for changes, the writes to the tree file are logged by tx
+func openIndexTree(tx *logmanager.Transaction, indexPath string) (*fbptree.FBPTree, error) {
+	openFile := func(path string, flag int, perm os.FileMode) (fbptree.File, error) {
+		return tx.OpenFile(path, flag, perm)
 	}
 
-	// Flush changes to disk
-	if err := metaFile.Sync(); err != nil {
-		return fmt.Errorf("error syncing metadata file: %w", err)
+	tree, err := fbptree.Open(indexPath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder), fbptree.OpenFile(openFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open B+ tree %s: %w", indexPath, err)
 	}
-
-	return nil
+	return tree, nil
 }
 
 // the first function to add entry to a specific index of the table
-func addEntryToIndex(tableName string, indexName string, key []byte, pageID int32) error {
+func addEntryToIndex(tx *logmanager.Transaction, tableName string, index indexInfo, indexKey IndexKey, rid heapmanager.RID) error {
+	key, err := index.entryKey(indexKey, rid)
+	if err != nil {
+		return err
+	}
+	value, err := index.entryValue(indexKey, rid)
+	if err != nil {
+		return err
+	}
+	if index.kind == HashIndex {
+		return addEntryToHashIndex(tx, tableName, index, key, value, index.uniqueKey(indexKey))
+	}
 
 	//open the index file if it exists
 	indexDir := path.Join("indexes", tableName)
-	indexPath := path.Join(indexDir, indexName+".data")
+	indexPath := path.Join(indexDir, index.name+".data")
 
-	tree, err := fbptree.Open(indexPath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder))
+	tree, err := openIndexTree(tx, indexPath)
 	if err != nil {
-		return fmt.Errorf("failed to open B+ tree %s: %w", indexPath, err)
+		return err
 	}
 	defer tree.Close()
 
+	// the entries of a key that is not unique never collide, the rid is part of their key
+	if !index.uniqueKey(indexKey) {
+		if _, _, err := tree.Put(key, value); err != nil {
+			return fmt.Errorf("failed to insert value: %w", err)
+		}
+		return nil
+	}
+
 	// add the key to the index
-	//make the page id array of three bytes using big endian
-	pageIDBytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(pageIDBytes, uint32(pageID))
+	// the value stored with the key is the rid of the row in the table heap, with the included values
 	//get the key first to check if it exists
 	_, ok, err := tree.Get(key)
 	if err != nil {
@@ -132,7 +206,7 @@ func addEntryToIndex(tableName string, indexName string, key []byte, pageID int3
 	}
 
 	if !ok {
-		if _, _, err := tree.Put(key, pageIDBytes); err != nil {
+		if _, _, err := tree.Put(key, value); err != nil {
 			return fmt.Errorf("failed to insert value: %w", err)
 		}
 
@@ -145,120 +219,107 @@ func addEntryToIndex(tableName string, indexName string, key []byte, pageID int3
 }
 
 // the second function to add entry to all indexes of the table
-func AddEntryToTableIndexes(tableName string, keys [][]byte, pageID int32) error {
-	indexes, err := GetIndexesMetadata(tableName)
-
+// the entries and the metadata are changed in one transaction, so either all the indexes get the entry or none
+// keys has the key of the row for each index of the table, in the order of the indexes.
+func AddEntryToTableIndexes(tableName string, keys []IndexKey, rid heapmanager.RID) error {
+	tx, err := logmanager.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to get indexes metadata: %w", err)
+		return err
 	}
+	return tx.Finish(addEntryToTableIndexes(tx, tableName, keys, rid))
+}
 
-	for i, index := range indexes {
-		indexName := string(index[:20])
-		indexName = strings.Trim(indexName, "\x00")
+// AddEntryToTableIndexesTx adds the entry to all indexes of the table as part of the transaction tx.
+// if it fails for one of the indexes, the entries already added are removed and tx stays open.
+func AddEntryToTableIndexesTx(tx *logmanager.Transaction, tableName string, keys []IndexKey, rid heapmanager.RID) error {
+	savepoint := tx.Savepoint()
+	return tx.EndStatement(savepoint, addEntryToTableIndexes(tx, tableName, keys, rid))
+}
 
-		if err := addEntryToIndex(tableName, indexName, keys[i], pageID); err != nil {
-			return fmt.Errorf("failed to add entry to index %s: %w", indexName, err)
-		}
+func addEntryToTableIndexes(tx *logmanager.Transaction, tableName string, keys []IndexKey, rid heapmanager.RID) error {
+	indexes, err := GetIndexesMetadata(tableName)
 
-		keysCount := binary.BigEndian.Uint32(index[48:52])
-		keysCount++
-		binary.BigEndian.PutUint32(index[48:52], keysCount)
+	if err != nil {
+		return fmt.Errorf("failed to get indexes metadata: %w", err)
 	}
 
-	// update the index metadata
-	// open the metadata file
-	metaDataPath := path.Join("indexes", tableName, metaDataFileName)
-	metaFile, err := os.OpenFile(metaDataPath, os.O_RDWR, 0644)
-	if err != nil {
-		return fmt.Errorf("error opening the metadata file: %w", err)
+	if len(keys) != len(indexes) {
+		return fmt.Errorf("table %s has %d indexes, got %d keys", tableName, len(indexes), len(keys))
 	}
-	defer metaFile.Close()
 
-	// Lock mutex to synchronize access to metadata file
-	metaFilEMutex.Lock()
-	defer metaFilEMutex.Unlock()
+	for i, index := range indexes {
+		if err := addEntryToIndex(tx, tableName, index.info(), keys[i], rid); err != nil {
+			return fmt.Errorf("failed to add entry to index %s: %w", index.Name, err)
+		}
+	}
 
-	// Write the index metadata to the file
+	// update the number of keys of the indexes
+	return changeIndexesMetadata(tx, tableName, func(indexes []IndexMetadata) ([]IndexMetadata, error) {
+		for i := range indexes {
+			indexes[i].Keys++
+		}
+		return indexes, nil
+	})
+}
 
-	//flatten the indexes array
-	flatIndexes := make([]byte, 0)
-	for _, index := range indexes {
-		flatIndexes = append(flatIndexes, index...)
+// RemoveEntryFromTableIndexes removes the entry of the row rid from all indexes for a given key.
+func RemoveEntryFromTableIndexes(tableName string, keys []IndexKey, rid heapmanager.RID) error {
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
 	}
-	metaFile.WriteAt(flatIndexes, 24)
+	return tx.Finish(removeEntryFromTableIndexes(tx, tableName, keys, rid))
+}
 
-	// Flush changes to disk
-	if err := metaFile.Sync(); err != nil {
-		return fmt.Errorf("error syncing metadata file: %w", err)
-	}
-	return nil
+// RemoveEntryFromTableIndexesTx removes the entry from all indexes of the table as part of the transaction tx.
+// if it fails for one of the indexes, the entries already removed are added back and tx stays open.
+func RemoveEntryFromTableIndexesTx(tx *logmanager.Transaction, tableName string, keys []IndexKey, rid heapmanager.RID) error {
+	savepoint := tx.Savepoint()
+	return tx.EndStatement(savepoint, removeEntryFromTableIndexes(tx, tableName, keys, rid))
 }
 
-// RemoveEntryFromTableIndexes removes an entry from all indexes for a given key.
-func RemoveEntryFromTableIndexes(tableName string, keys [][]byte) error {
+func removeEntryFromTableIndexes(tx *logmanager.Transaction, tableName string, keys []IndexKey, rid heapmanager.RID) error {
 	indexes, err := GetIndexesMetadata(tableName)
 
 	if err != nil {
 		return fmt.Errorf("failed to get indexes metadata: %w", err)
 	}
 
-	for i, index := range indexes {
-		indexName := string(index[:20])
-		indexName = strings.Trim(indexName, "\x00")
+	if len(keys) != len(indexes) {
+		return fmt.Errorf("table %s has %d indexes, got %d keys", tableName, len(indexes), len(keys))
+	}
 
-		if err := removeEntryFromIndex(tableName, indexName, keys[i]); err != nil {
-			return fmt.Errorf("failed to remove entry from index %s: %w", indexName, err)
+	for i, index := range indexes {
+		if err := removeEntryFromIndex(tx, tableName, index.info(), keys[i], rid); err != nil {
+			return fmt.Errorf("failed to remove entry from index %s: %w", index.Name, err)
 		}
-
-		updatesCount := binary.BigEndian.Uint32(index[40:44])
-		keysCount := binary.BigEndian.Uint32(index[48:52])
-		updatesCount++
-		keysCount--
-		binary.BigEndian.PutUint32(index[40:44], updatesCount)
-		binary.BigEndian.PutUint32(index[48:52], keysCount)
-
-		indexes[i] = index
 	}
 
-	indexDir := path.Join("indexes", tableName)
-	metaDataPath := path.Join(indexDir, metaDataFileName)
+	// update the number of keys and the updates of the indexes
+	return changeIndexesMetadata(tx, tableName, func(indexes []IndexMetadata) ([]IndexMetadata, error) {
+		for i := range indexes {
+			indexes[i].UpdatesCount++
+			indexes[i].Keys--
+		}
+		return indexes, nil
+	})
+}
 
-	// Open the metadata file
-	metaFile, err := os.OpenFile(metaDataPath, os.O_RDWR, 0644)
+// RemoveEntryFromIndex removes an entry from a specific index for a given key.
+// only the entry of the row rid is removed from a non-unique index.
+func removeEntryFromIndex(tx *logmanager.Transaction, tableName string, index indexInfo, indexKey IndexKey, rid heapmanager.RID) error {
+	key, err := index.entryKey(indexKey, rid)
 	if err != nil {
-		return fmt.Errorf("error opening the metadata file: %w", err)
+		return err
 	}
-	defer metaFile.Close()
-
-	// flat the indexes metadata
-	flatIndexesMetadata := make([]byte, 0)
-	for _, index := range indexes {
-		flatIndexesMetadata = append(flatIndexesMetadata, index...)
+	if index.kind == HashIndex {
+		return removeEntryFromHashIndex(tx, tableName, index, key, rid)
 	}
 
-	// Lock mutex to synchronize access to metadata file
-	metaFilEMutex.Lock()
-	defer metaFilEMutex.Unlock()
-
-	// Write the indexes metadata to the file
-	if _, err := metaFile.WriteAt(flatIndexesMetadata, 24); err != nil {
-		return fmt.Errorf("error writing indexes metadata to metadata file: %w", err)
-	}
-
-	// Flush changes to disk
-	if err := metaFile.Sync(); err != nil {
-		return fmt.Errorf("error syncing metadata file: %w", err)
-	}
-
-	return nil
-}
-
-// RemoveEntryFromIndex removes an entry from a specific index for a given key.
-func removeEntryFromIndex(tableName string, indexName string, key []byte) error {
-	indexPath := path.Join("indexes", tableName, indexName+".data")
-	tree, err := fbptree.Open(indexPath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder))
+	indexPath := path.Join("indexes", tableName, index.name+".data")
+	tree, err := openIndexTree(tx, indexPath)
 	if err != nil {
-		return fmt.Errorf("failed to open B+ tree %s: %w", indexPath, err)
+		return err
 	}
 	defer tree.Close()
 
@@ -271,63 +332,49 @@ func removeEntryFromIndex(tableName string, indexName string, key []byte) error
 	return nil
 }
 
-// SearchIndexEntry searches for an entry in the index for a given key, returning the page id.
-func FindIndexEntry(tableName string, indexName string, key []byte) (int32, error) {
-	// open the index and search for the key
-	indexPath := path.Join("indexes", tableName, indexName+".data")
-	tree, err := fbptree.Open(indexPath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder))
+// FindIndexEntry searches for the entries in the index for a given key, returning the rids of their rows.
+// a unique index returns one rid at most, a non-unique one (or a unique typed one for a key with a null) returns the rids of all the rows with the key.
+// a key with fewer values than the columns of a composite index returns the rows that start with its values,
+// except for a hash index which needs a value for every column.
+func FindIndexEntry(tableName string, indexName string, key IndexKey) ([]heapmanager.RID, error) {
+	entries, err := FindIndexEntries(tableName, indexName, key)
 	if err != nil {
-		return 0, fmt.Errorf("failed to open B+ tree %s: %w", indexPath, err)
+		return nil, err
 	}
 
-	defer tree.Close()
-	// search for the key
-	PageID, ok, err := tree.Get(key)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get value: %w", err)
-	}
-	if !ok {
-		return 0, fmt.Errorf("failed to find value")
+	rids := make([]heapmanager.RID, 0, len(entries))
+	for _, entry := range entries {
+		rids = append(rids, entry.RID)
 	}
-
-	return int32(binary.BigEndian.Uint32(PageID)), nil
+	return rids, nil
 }
 
-// ScanIndexRange scans the index for entries within a specified key range, returning a list of page IDs corresponding to keys within the range.
-func ScanIndexRange(tableName string, indexName string, startKey []byte, endKey []byte) ([]int32, error) {
-	//convert the start and end key to int32
-	// open the index and scan the range
-	indexPath := path.Join("indexes", tableName, indexName+".data")
-	tree, err := fbptree.Open(indexPath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder))
+// FindIndexEntries searches for the entries in the index for a given key like FindIndexEntry,
+// returning their full keys and the values of the included columns of a covering index with their rids.
+func FindIndexEntries(tableName string, indexName string, key IndexKey) ([]IndexEntry, error) {
+	indexMetadata, err := getIndexMetadata(tableName, indexName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open B+ tree %s: %w", indexPath, err)
+		return nil, err
+	}
+	if indexMetadata.Kind == HashIndex {
+		return findHashIndexEntries(tableName, indexMetadata.info(), key)
 	}
-	defer tree.Close()
-
-	startKeyInt32 := binary.BigEndian.Uint32(startKey)
-	endKeyInt32 := binary.BigEndian.Uint32(endKey)
-
-	//loop from start to end and get the key from the index
-
-	result := make([]int32, 0)
-	for i := startKeyInt32; i <= endKeyInt32; i++ {
 
-		//convert i to byte array
-		key := make([]byte, 4)
-		binary.BigEndian.PutUint32(key, i)
+	it, err := ScanIndexRange(tableName, indexName, ScanOptions{Start: key, End: key})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
 
-		pageId, ok, err := tree.Get(key)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get value: %w", err)
-		}
-		if ok {
-			//convert the page id to int32
-			result = append(result, int32(binary.BigEndian.Uint32(pageId)))
-		}
+	entries := make([]IndexEntry, 0)
+	for it.Next() {
+		entries = append(entries, IndexEntry{Key: it.Key(), RID: it.RID(), Included: it.Included()})
+	}
+	if err := it.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get value: %w", err)
 	}
 
-	return result, nil
-	// open the index and scan the range
+	return entries, nil
 }
 
 // DeleteIndex deletes the index for a given table, following the same logic of the add index entry function
@@ -342,10 +389,8 @@ func DeleteIndex(tableName string, indexName string) error {
 			return fmt.Errorf("failed to get indexes metadata: %w", err)
 		}
 		for _, idx := range indexes {
-			idxName := string(idx[:20])
-			idxName = strings.Trim(idxName, "\x00")
-			if err := deleteIndex(tableName, idxName); err != nil {
-				return fmt.Errorf("failed to delete index %s: %w", idxName, err)
+			if err := deleteIndex(tableName, idx.Name); err != nil {
+				return fmt.Errorf("failed to delete index %s: %w", idx.Name, err)
 			}
 		}
 	} else {
@@ -368,160 +413,46 @@ func deleteIndex(tableName, indexName string) error {
 	indexPath := path.Join(indexDir, indexName+".data")
 	fmt.Println(indexPath)
 
-	if err := os.Remove(indexPath); err != nil {
-		return fmt.Errorf("failed to delete index file %s: %w", indexPath, err)
-	}
-
-	// Remove the index metadata from the metadata file
-	metaDataPath := path.Join("indexes", tableName, metaDataFileName)
-	metaFile, err := os.OpenFile(metaDataPath, os.O_RDWR, 0644)
+	// the file is only removed once the metadata change is committed
+	tx, err := logmanager.Begin()
 	if err != nil {
-		return fmt.Errorf("error opening the metadata file: %w", err)
+		return err
 	}
-	defer metaFile.Close()
-
-	// Lock mutex to synchronize access to metadata file
-
-	// Read the indexes metadata
-	indexes, err := GetIndexesMetadata(tableName)
-	if err != nil {
-		return fmt.Errorf("failed to get indexes metadata: %w", err)
+	if err := tx.Finish(removeIndexMetadata(tx, tableName, indexName)); err != nil {
+		return err
 	}
 
-	metaFilEMutex.Lock()
-	defer metaFilEMutex.Unlock()
-
-	// Find and remove the metadata of the deleted index
-	var updatedMetadata []byte
-	for _, idx := range indexes {
-		if strings.Trim(string(idx[:20]), "\x00") != indexName {
-			updatedMetadata = append(updatedMetadata, idx...)
-		}
+	// the log may still have changes of the index file, they are applied before it is removed
+	// so recovery never replays them on a new index with the same name
+	if err := logmanager.Checkpoint(); err != nil {
+		return err
 	}
 
-	// Write the updated indexes metadata to the file
-	if _, err := metaFile.WriteAt(updatedMetadata, 24); err != nil {
-		return fmt.Errorf("error writing updated indexes metadata to metadata file: %w", err)
-	}
-
-	// Flush changes to disk
-	if err := metaFile.Sync(); err != nil {
-		return fmt.Errorf("error syncing metadata file: %w", err)
-	}
-
-	return nil
-
-}
-
-// GetIndexMetadata returns the metadata for a given table.
-func GetIndexesMetadata(tableName string) ([][]byte, error) {
-	// read the indexes meta to know all the indexes for the table
-	indexDir := path.Join("indexes", tableName)
-	metaDataPath := path.Join(indexDir, metaDataFileName)
-
-	// Open the metadata file
-	metaFile, err := os.OpenFile(metaDataPath, os.O_RDWR, 0644)
-	if err != nil {
-		return nil, fmt.Errorf("error opening the metadata file: %w", err)
-	}
-	defer metaFile.Close()
-
-	// Lock mutex to synchronize access to metadata file
-	metaFilEMutex.Lock()
-	defer metaFilEMutex.Unlock()
-
-	// Read the header
-	header := make([]byte, 24)
-	if _, err := metaFile.ReadAt(header, 0); err != nil {
-		return nil, fmt.Errorf("error reading the metadata file: %w", err)
-	}
-
-	// Get the number of indexes
-	indexesCount := binary.BigEndian.Uint32(header[20:])
-	indexesMetadata := make([][]byte, indexesCount)
-
-	// Read the indexes metadata
-	for i := uint32(0); i < indexesCount; i++ {
-		indexMetadata := make([]byte, indexMetadataSize)
-		if _, err := metaFile.ReadAt(indexMetadata, int64(24+i*indexMetadataSize)); err != nil {
-			return nil, fmt.Errorf("error reading index metadata from metadata file: %w", err)
-		}
-		indexesMetadata[i] = indexMetadata
-	}
-
-	return indexesMetadata, nil
-}
-
-// update the index metadata
-func UpdateIndexMetadata(tableName string, indexName string, indexMetadata []byte) error {
-	// Calculate the offset of the index metadata in the metadata file
-	indexMetadataOffset := int64(24) + getIndexOffset(tableName, indexName)
-
-	// Open the metadata file
-	metaDataPath := path.Join("indexes", tableName, metaDataFileName)
-	metaFile, err := os.OpenFile(metaDataPath, os.O_RDWR, 0644)
-	if err != nil {
-		return fmt.Errorf("error opening the metadata file: %w", err)
-	}
-	defer metaFile.Close()
-
-	// Lock mutex to synchronize access to metadata file
-	metaFilEMutex.Lock()
-	defer metaFilEMutex.Unlock()
-
-	// Write the index metadata to the file
-	if _, err := metaFile.WriteAt(indexMetadata, indexMetadataOffset); err != nil {
-		return fmt.Errorf("error writing index metadata to metadata file: %w", err)
+	if err := os.Remove(indexPath); err != nil {
+		return fmt.Errorf("failed to delete index file %s: %w", indexPath, err)
 	}
-
-	// Flush changes to disk
-	if err := metaFile.Sync(); err != nil {
-		return fmt.Errorf("error syncing metadata file: %w", err)
+	// a build of the index that was not committed is not installed on a new index with the same name
+	if err := removeIndexBuilds(tableName, indexName); err != nil {
+		return fmt.Errorf("failed to delete the builds of index %s: %w", indexName, err)
 	}
 
 	return nil
 }
 
-func getIndexOffset(tableName string, indexName string) int64 {
-	// Open the metadata file
-	metaDataPath := path.Join("indexes", tableName, metaDataFileName)
-	metaFile, err := os.OpenFile(metaDataPath, os.O_RDWR, 0644)
-	if err != nil {
-		return 0 // Return 0 in case of error
-	}
-	defer metaFile.Close()
-
-	// Read the header to get the number of indexes
-	header := make([]byte, 24)
-	if _, err := metaFile.ReadAt(header, 0); err != nil {
-		return 0 // Return 0 in case of error
-	}
-
-	// Get the number of indexes
-	indexesCount := binary.BigEndian.Uint32(header[20:])
-
-	// Calculate the offset of the specified index
-	indexOffset := int64(24 + indexesCount*indexMetadataSize)
-
-	// Search for the index in the metadata file
-	for i := uint32(0); i < indexesCount; i++ {
-		indexMetadata := make([]byte, indexMetadataSize)
-		if _, err := metaFile.ReadAt(indexMetadata, indexOffset); err != nil {
-			return 0 // Return 0 in case of error
-		}
-		existingIndexName := string(indexMetadata[:20])
-		existingIndexName = strings.Trim(existingIndexName, "\x00")
-		if existingIndexName == indexName {
-			return indexOffset // Return the offset of the index
+// removes the metadata of the index from the metadata file of the table
+func removeIndexMetadata(tx *logmanager.Transaction, tableName, indexName string) error {
+	return changeIndexesMetadata(tx, tableName, func(indexes []IndexMetadata) ([]IndexMetadata, error) {
+		// Find and remove the metadata of the deleted index
+		updatedIndexes := make([]IndexMetadata, 0, len(indexes))
+		for _, index := range indexes {
+			if index.Name != indexName {
+				updatedIndexes = append(updatedIndexes, index)
+			}
 		}
-		indexOffset += indexMetadataSize
-	}
-
-	return 0 // Return 0 if the index is not found
+		return updatedIndexes, nil
+	})
 }
 
-
-
 // GetIndexSize returns the size of the index in bytes.
 // It should return the size of the index data structures.
 func GetIndexSize(tableName string, indexName string) (int32, error) {
@@ -532,42 +463,3 @@ func GetIndexSize(tableName string, indexName string) (int32, error) {
 	}
 	return int32(fileInfo.Size()), nil
 }
-
-// GetIndexHeight returns the height of the index.
-
-// func GetIndexHeight(tableName string, indexName string) (int32, error) {
-// 	return 0, nil
-// 	/*
-// 		check if the index exists
-// 		return the height of the index
-// 	*/
-// }
-
-// [-- TO BE IMPLEMENTED --]
-// func CheckIndexRebuild(tableName string) (string, error) {
-
-// 	/* read the indexes meta to know all the indexes for the table
-// 	     iterate over all indexes and check if the index needs to be rebuilt
-// 		 if the index needs to be rebuilt, call the rebuild index function
-
-// 		 This fucntion to be called after each update or delete operation happens on the table ,
-
-// 		 --
-// 		NOte : i know it is not the best solution to call this function after each update or delete operation
-// 				but it is the best solution for the first submission
-
-// 		 --
-// 	*/
-
-// 	return "", nil
-// }
-
-// [-- TO BE IMPLEMENTED --]
-// func RebuildIndex(tableName string, indexName string) error {
-// 	return nil
-
-// 	/*
-// 		check if the index exists
-// 		rebuild the index by creating a new B+ tree and adding the entries from the table or reblancing the tree
-// 	*/
-// }
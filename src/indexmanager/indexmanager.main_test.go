@@ -0,0 +1,86 @@
+package indexmanager
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+)
+
+// the heaps, the indexes and the log are created in the working directory, the tests run in a temporary one
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "indexmanager")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// creates the index of the table, the test fails if it cannot
+func createTestIndex(t *testing.T, table string, index string, options IndexOptions) {
+	t.Helper()
+
+	if err := InitializeIndex(table, index, options); err != nil {
+		t.Fatalf("failed to create index %s of %s: %s", index, table, err)
+	}
+}
+
+// returns the key with one value per column
+func testKey(values ...string) IndexKey {
+	key := make(IndexKey, len(values))
+	for i, value := range values {
+		key[i] = []byte(value)
+	}
+	return key
+}
+
+// returns the rid of the row i of a page, the indexes do not read the heap so the rows do not have to exist
+func testRID(i int) heapmanager.RID {
+	return heapmanager.RID{PageID: uint32(i / 100), SlotID: uint16(i % 100)}
+}
+
+func addEntry(t *testing.T, table string, keys []IndexKey, rid heapmanager.RID) {
+	t.Helper()
+
+	if err := AddEntryToTableIndexes(table, keys, rid); err != nil {
+		t.Fatalf("failed to add the entry %v of %v: %s", keys, rid, err)
+	}
+}
+
+func removeEntry(t *testing.T, table string, keys []IndexKey, rid heapmanager.RID) {
+	t.Helper()
+
+	if err := RemoveEntryFromTableIndexes(table, keys, rid); err != nil {
+		t.Fatalf("failed to remove the entry %v of %v: %s", keys, rid, err)
+	}
+}
+
+// checks that the lookup of the key finds the rows, in any order
+func checkLookup(t *testing.T, table string, index string, key IndexKey, expected ...heapmanager.RID) {
+	t.Helper()
+
+	rids, err := FindIndexEntry(table, index, key)
+	if err != nil {
+		t.Fatalf("failed to look up %q in %s: %s", key, index, err)
+	}
+	slices.SortFunc(rids, compareRIDs)
+	expected = slices.Clone(expected)
+	slices.SortFunc(expected, compareRIDs)
+	if !slices.Equal(rids, expected) {
+		t.Fatalf("the lookup of %q in %s found %v, expected %v", key, index, rids, expected)
+	}
+}
+
+func compareRIDs(a heapmanager.RID, b heapmanager.RID) int {
+	if a.PageID != b.PageID {
+		return int(a.PageID) - int(b.PageID)
+	}
+	return int(a.SlotID) - int(b.SlotID)
+}
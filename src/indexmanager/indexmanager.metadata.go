@@ -0,0 +1,510 @@
+package indexmanager
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// meta.data keeps the metadata of all the indexes of a table:
+// | Magic 4B | FormatVersion 2B | TableNameSize 2B | TableName | IndexCount 4B | Entries ... |
+//
+// entry:
+// | Size 4B | Checksum 4B | NameSize 2B | Name | ColumnCount 2B | ColumnSize 2B | Column | ... |
+// | Flags 4B | UpdatesCount 4B | Version 4B | Keys 4B | Kind 1B |
+// | IncludeCount 2B | IncludeSize 2B | Include | ... | Statistics |
+//
+// the size and the checksum (crc32) cover the rest of the entry. the statistics are only there
+// once the index is analyzed (indexFlagAnalyzed):
+// | Height 4B | LeafNodes 4B | InternalNodes 4B | Fill 8B | DistinctKeys 4B | BucketCount 2B | Buckets ... |
+//
+// bucket of the histogram:
+// | Keys 4B | DistinctKeys 4B | BoundSize 2B | UpperBound |
+//
+// the bytes of an entry after the fields known by the reader are skipped, so a later version can add
+// fields at the end of the entries. the whole file is written again by every change of the metadata.
+//
+// the files written before the format had a version (the legacy layout) have a fixed header and records
+// of 52 bytes. they are read as they are, the next change of the metadata writes them in the current format:
+// | TableName 20B | IndexCount 4B | Records ... |
+//
+// record:
+// | Name 20B | Column 20B | UpdatesCount 4B | Version 4B | Keys 4B |
+
+const (
+	metadataMagic         = 0x53494458
+	metadataFormatVersion = 1
+	metadataEntryHeader   = 8
+	// the names are prefixed with their size on 2 bytes
+	maxMetadataNameSize = math.MaxUint16
+
+	legacyHeaderSize = 24
+	legacyRecordSize = 52
+)
+
+// IndexMetadata is the metadata of an index, kept in the meta.data file of its table.
+type IndexMetadata struct {
+	Name string
+	// the columns of the index in order, a composite index has more than one
+	Columns []string
+	// the columns whose values are stored in the entries of a covering index next to the rid
+	Include []string
+	// a unique index has one entry at most for each key
+	Unique bool
+	// the values of the keys are encoded by keycodec and can be null, see IndexOptions
+	Typed bool
+	// the data structure of the index
+	Kind IndexKind
+	// the entries removed from the index since it was built
+	UpdatesCount uint32
+	// bumped every time the index is rebuilt
+	Version uint32
+	// the number of entries of the index
+	Keys uint32
+	// the statistics written by AnalyzeIndex
+	Analysis IndexAnalysis
+}
+
+// GetIndexesMetadata returns the metadata of all the indexes of the table, in the order they were created.
+func GetIndexesMetadata(tableName string) ([]IndexMetadata, error) {
+	metaFilEMutex.Lock()
+	defer metaFilEMutex.Unlock()
+
+	return readIndexesMetadata(tableName)
+}
+
+// returns the metadata of the index of the table
+func getIndexMetadata(tableName string, indexName string) (IndexMetadata, error) {
+	indexes, err := GetIndexesMetadata(tableName)
+	if err != nil {
+		return IndexMetadata{}, fmt.Errorf("failed to get indexes metadata: %w", err)
+	}
+
+	for _, index := range indexes {
+		if index.Name == indexName {
+			return index, nil
+		}
+	}
+	return IndexMetadata{}, fmt.Errorf("index %s of table %s does not exist", indexName, tableName)
+}
+
+// UpdateIndexMetadata replaces the metadata of the index.
+func UpdateIndexMetadata(tableName string, indexName string, indexMetadata IndexMetadata) error {
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(updateIndexMetadata(tx, tableName, indexName, indexMetadata))
+}
+
+func updateIndexMetadata(tx *logmanager.Transaction, tableName string, indexName string, indexMetadata IndexMetadata) error {
+	return changeIndexesMetadata(tx, tableName, func(indexes []IndexMetadata) ([]IndexMetadata, error) {
+		for i, index := range indexes {
+			if index.Name == indexName {
+				indexes[i] = indexMetadata
+				return indexes, nil
+			}
+		}
+		return nil, fmt.Errorf("index %s of table %s does not exist", indexName, tableName)
+	})
+}
+
+// reads the metadata of the indexes of the table and writes it back with the changes of change,
+// the write is logged by tx.
+func changeIndexesMetadata(tx *logmanager.Transaction, tableName string, change func([]IndexMetadata) ([]IndexMetadata, error)) error {
+	metaFilEMutex.Lock()
+	defer metaFilEMutex.Unlock()
+
+	indexes, err := readIndexesMetadata(tableName)
+	if err != nil {
+		return err
+	}
+
+	indexes, err = change(indexes)
+	if err != nil {
+		return err
+	}
+
+	return writeIndexesMetadata(tx, tableName, indexes)
+}
+
+// reads the metadata file of the table, in the current format or in the legacy layout.
+// the log is recovered first, so the metadata has the committed writes and the builds it installs are committed.
+// it must be called with metaFilEMutex locked
+func readIndexesMetadata(tableName string) ([]IndexMetadata, error) {
+	if err := logmanager.Recover(); err != nil {
+		return nil, err
+	}
+
+	metaDataPath := path.Join("indexes", tableName, metaDataFileName)
+	data, err := os.ReadFile(metaDataPath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the metadata file: %w", err)
+	}
+
+	if len(data) >= 4 && binary.BigEndian.Uint32(data[0:4]) == metadataMagic {
+		indexes, err := decodeIndexesMetadata(data)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the metadata file %s: %w", metaDataPath, err)
+		}
+		if err := installIndexBuilds(tableName, indexes); err != nil {
+			return nil, err
+		}
+		return indexes, nil
+	}
+
+	// the next change of the metadata writes the file in the current format, logged like any other change
+	indexes, err := decodeLegacyMetadata(tableName, data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the legacy metadata file %s: %w", metaDataPath, err)
+	}
+	return indexes, nil
+}
+
+// renames into place the files of the index builds whose metadata was committed but whose file
+// was not renamed, because of a crash or a failed rename (see buildIndexFile). this is only done
+// the first time the metadata of the table is read, before a build of its indexes can start,
+// so the file of a build whose transaction is still open is never installed.
+// it must be called with metaFilEMutex locked
+func installIndexBuilds(tableName string, indexes []IndexMetadata) error {
+	if installedTables[tableName] {
+		return nil
+	}
+
+	installed := false
+	for _, index := range indexes {
+		// an index created empty has no build file until it is rebuilt
+		if index.Version == 0 {
+			continue
+		}
+
+		buildPath := indexBuildPath(tableName, index.Name, index.Version)
+		indexPath := path.Join("indexes", tableName, index.Name+".data")
+		err := os.Rename(buildPath, indexPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to install the rebuilt index file %s: %w", buildPath, err)
+		}
+		installed = true
+	}
+
+	if installed {
+		if err := syncDir(path.Join("indexes", tableName)); err != nil {
+			return err
+		}
+	}
+	installedTables[tableName] = true
+	return nil
+}
+
+// writes the metadata file of the table in the current format, the writes are logged by tx.
+// it must be called with metaFilEMutex locked
+func writeIndexesMetadata(tx *logmanager.Transaction, tableName string, indexes []IndexMetadata) error {
+	data, err := encodeIndexesMetadata(tableName, indexes)
+	if err != nil {
+		return err
+	}
+
+	metaDataPath := path.Join("indexes", tableName, metaDataFileName)
+	metaFile, err := tx.OpenFile(metaDataPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening the metadata file: %w", err)
+	}
+	defer metaFile.Close()
+
+	if _, err := metaFile.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("error writing the metadata file: %w", err)
+	}
+
+	// the file of fewer or shorter entries is cut after the last one
+	info, err := metaFile.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > int64(len(data)) {
+		if err := metaFile.Truncate(int64(len(data))); err != nil {
+			return fmt.Errorf("error truncating the metadata file: %w", err)
+		}
+	}
+	return nil
+}
+
+func encodeIndexesMetadata(tableName string, indexes []IndexMetadata) ([]byte, error) {
+	if len(tableName) > maxMetadataNameSize {
+		return nil, fmt.Errorf("the table name is longer than %d bytes", maxMetadataNameSize)
+	}
+
+	data := binary.BigEndian.AppendUint32(nil, metadataMagic)
+	data = binary.BigEndian.AppendUint16(data, metadataFormatVersion)
+	data = appendName(data, tableName)
+	data = binary.BigEndian.AppendUint32(data, uint32(len(indexes)))
+
+	for _, index := range indexes {
+		entry, err := encodeIndexMetadata(index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the metadata of index %s: %w", index.Name, err)
+		}
+
+		data = binary.BigEndian.AppendUint32(data, uint32(len(entry)))
+		data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(entry))
+		data = append(data, entry...)
+	}
+	return data, nil
+}
+
+func encodeIndexMetadata(index IndexMetadata) ([]byte, error) {
+	if len(index.Columns) == 0 || len(index.Columns) > maxIndexColumns {
+		return nil, fmt.Errorf("an index must have between 1 and %d columns", maxIndexColumns)
+	}
+	if len(index.Include) > maxIndexColumns {
+		return nil, fmt.Errorf("an index can include %d columns at most", maxIndexColumns)
+	}
+	for _, name := range append(append([]string{index.Name}, index.Columns...), index.Include...) {
+		if len(name) > maxMetadataNameSize {
+			return nil, fmt.Errorf("the name %.20s... is longer than %d bytes", name, maxMetadataNameSize)
+		}
+	}
+
+	var flags uint32
+	if index.Unique {
+		flags |= indexFlagUnique
+	}
+	if index.Analysis.Analyzed {
+		flags |= indexFlagAnalyzed
+	}
+	if index.Typed {
+		flags |= indexFlagTyped
+	}
+
+	entry := appendName(nil, index.Name)
+	entry = binary.BigEndian.AppendUint16(entry, uint16(len(index.Columns)))
+	for _, column := range index.Columns {
+		entry = appendName(entry, column)
+	}
+	entry = binary.BigEndian.AppendUint32(entry, flags)
+	entry = binary.BigEndian.AppendUint32(entry, index.UpdatesCount)
+	entry = binary.BigEndian.AppendUint32(entry, index.Version)
+	entry = binary.BigEndian.AppendUint32(entry, index.Keys)
+	entry = append(entry, byte(index.Kind))
+	entry = binary.BigEndian.AppendUint16(entry, uint16(len(index.Include)))
+	for _, column := range index.Include {
+		entry = appendName(entry, column)
+	}
+
+	if index.Analysis.Analyzed {
+		entry = appendIndexAnalysis(entry, index.Analysis)
+	}
+	return entry, nil
+}
+
+func appendIndexAnalysis(data []byte, analysis IndexAnalysis) []byte {
+	data = binary.BigEndian.AppendUint32(data, uint32(analysis.Height))
+	data = binary.BigEndian.AppendUint32(data, uint32(analysis.LeafNodes))
+	data = binary.BigEndian.AppendUint32(data, uint32(analysis.InternalNodes))
+	data = binary.BigEndian.AppendUint64(data, math.Float64bits(analysis.Fill))
+	data = binary.BigEndian.AppendUint32(data, uint32(analysis.DistinctKeys))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(analysis.Histogram)))
+
+	for _, bucket := range analysis.Histogram {
+		data = binary.BigEndian.AppendUint32(data, uint32(bucket.Keys))
+		data = binary.BigEndian.AppendUint32(data, uint32(bucket.DistinctKeys))
+		data = binary.BigEndian.AppendUint16(data, uint16(len(bucket.UpperBound)))
+		data = append(data, bucket.UpperBound...)
+	}
+	return data
+}
+
+func appendName(data []byte, name string) []byte {
+	data = binary.BigEndian.AppendUint16(data, uint16(len(name)))
+	return append(data, name...)
+}
+
+func decodeIndexesMetadata(data []byte) ([]IndexMetadata, error) {
+	r := &metadataReader{data: data[4:]}
+	version := r.uint16()
+	if r.err == nil && version > metadataFormatVersion {
+		return nil, fmt.Errorf("the format version %d is newer than the supported version %d", version, metadataFormatVersion)
+	}
+	r.name()
+	count := r.uint32()
+	if r.err != nil {
+		return nil, fmt.Errorf("invalid header: %w", r.err)
+	}
+
+	indexes := make([]IndexMetadata, 0, min(count, 1024))
+	for i := uint32(0); i < count; i++ {
+		size := r.uint32()
+		checksum := r.uint32()
+		entry := r.bytes(int(size))
+		if r.err != nil {
+			return nil, fmt.Errorf("invalid entry %d: %w", i, r.err)
+		}
+		if crc32.ChecksumIEEE(entry) != checksum {
+			return nil, fmt.Errorf("the checksum of entry %d does not match, the entry is corrupted", i)
+		}
+
+		index, err := decodeIndexMetadata(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry %d: %w", i, err)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+func decodeIndexMetadata(entry []byte) (IndexMetadata, error) {
+	r := &metadataReader{data: entry}
+
+	index := IndexMetadata{Name: r.name()}
+	columnCount := int(r.uint16())
+	for i := 0; i < columnCount && r.err == nil; i++ {
+		index.Columns = append(index.Columns, r.name())
+	}
+	flags := r.uint32()
+	index.Unique = flags&indexFlagUnique != 0
+	index.Typed = flags&indexFlagTyped != 0
+	index.UpdatesCount = r.uint32()
+	index.Version = r.uint32()
+	index.Keys = r.uint32()
+	if kind := r.bytes(1); kind != nil {
+		index.Kind = IndexKind(kind[0])
+	}
+	includeCount := int(r.uint16())
+	for i := 0; i < includeCount && r.err == nil; i++ {
+		index.Include = append(index.Include, r.name())
+	}
+
+	if flags&indexFlagAnalyzed != 0 {
+		index.Analysis = readIndexAnalysis(r)
+	}
+	return index, r.err
+}
+
+func readIndexAnalysis(r *metadataReader) IndexAnalysis {
+	analysis := IndexAnalysis{
+		Analyzed:      true,
+		Height:        int(r.uint32()),
+		LeafNodes:     int(r.uint32()),
+		InternalNodes: int(r.uint32()),
+		Fill:          math.Float64frombits(r.uint64()),
+		DistinctKeys:  int(r.uint32()),
+	}
+
+	bucketCount := int(r.uint16())
+	analysis.Histogram = make([]HistogramBucket, 0, bucketCount)
+	for i := 0; i < bucketCount && r.err == nil; i++ {
+		bucket := HistogramBucket{
+			Keys:         int(r.uint32()),
+			DistinctKeys: int(r.uint32()),
+		}
+		bucket.UpperBound = append([]byte{}, r.bytes(int(r.uint16()))...)
+		analysis.Histogram = append(analysis.Histogram, bucket)
+	}
+	return analysis
+}
+
+// decodes a metadata file of the legacy layout, its indexes have one column and are all unique.
+//
+// the old deleteIndex removed the record without decrementing the count of the header, so the records
+// it left behind are dropped: the ones with the name of a record before them and the ones whose index file is gone.
+func decodeLegacyMetadata(tableName string, data []byte) ([]IndexMetadata, error) {
+	if len(data) < legacyHeaderSize {
+		return nil, errors.New("the file is shorter than its header")
+	}
+
+	count := int(binary.BigEndian.Uint32(data[20:24]))
+	indexes := make([]IndexMetadata, 0)
+	if count == 0 {
+		return indexes, nil
+	}
+
+	if len(data) != legacyHeaderSize+count*legacyRecordSize {
+		return nil, fmt.Errorf("%d bytes of records do not make %d records of %d bytes", len(data)-legacyHeaderSize, count, legacyRecordSize)
+	}
+
+	names := make(map[string]bool)
+	for i := 0; i < count; i++ {
+		record := data[legacyHeaderSize+i*legacyRecordSize : legacyHeaderSize+(i+1)*legacyRecordSize]
+		index := decodeLegacyRecord(record)
+
+		if names[index.Name] {
+			continue
+		}
+		if _, err := os.Stat(path.Join("indexes", tableName, index.Name+".data")); os.IsNotExist(err) {
+			continue
+		}
+		names[index.Name] = true
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
+func decodeLegacyRecord(record []byte) IndexMetadata {
+	name := func(b []byte) string {
+		return strings.Trim(string(b), "\x00")
+	}
+
+	return IndexMetadata{
+		Name:         name(record[0:20]),
+		Columns:      []string{name(record[20:40])},
+		UpdatesCount: binary.BigEndian.Uint32(record[40:44]),
+		Version:      binary.BigEndian.Uint32(record[44:48]),
+		Keys:         binary.BigEndian.Uint32(record[48:52]),
+		Unique:       true,
+	}
+}
+
+// reads the fields of the metadata one after the other, the first field past the end of the data
+// sets err and the fields after it read as zero
+type metadataReader struct {
+	data []byte
+	err  error
+}
+
+func (r *metadataReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data) {
+		r.err = errors.New("unexpected end of the metadata")
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *metadataReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *metadataReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *metadataReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *metadataReader) name() string {
+	return string(r.bytes(int(r.uint16())))
+}
@@ -0,0 +1,162 @@
+package indexmanager
+
+import (
+	"encoding/binary"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// returns a record of the legacy layout
+func legacyRecord(name string, column string, updates uint32, keys uint32) []byte {
+	record := make([]byte, legacyRecordSize)
+	copy(record[0:20], name)
+	copy(record[20:40], column)
+	binary.BigEndian.PutUint32(record[40:44], updates)
+	binary.BigEndian.PutUint32(record[48:52], keys)
+	return record
+}
+
+// a file of the legacy layout is read as it is and written in the current format by the next change
+func TestLegacyMetadata(t *testing.T) {
+	dir := path.Join("indexes", "legacy")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"legacy_id", "legacy_name"} {
+		if err := os.WriteFile(path.Join(dir, name+".data"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the old DeleteIndex left the record of a dropped index and did not decrement the count,
+	// the record of legacy_gone has no index file and the second legacy_id is a stale copy
+	data := make([]byte, legacyHeaderSize)
+	copy(data[0:20], "legacy")
+	binary.BigEndian.PutUint32(data[20:24], 4)
+	data = append(data, legacyRecord("legacy_id", "id", 3, 10)...)
+	data = append(data, legacyRecord("legacy_gone", "x", 0, 0)...)
+	data = append(data, legacyRecord("legacy_name", "name", 0, 7)...)
+	data = append(data, legacyRecord("legacy_id", "id", 0, 0)...)
+	metaDataPath := path.Join(dir, metaDataFileName)
+	if err := os.WriteFile(metaDataPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	indexes, err := GetIndexesMetadata("legacy")
+	if err != nil {
+		t.Fatalf("failed to read the legacy metadata: %s", err)
+	}
+	if len(indexes) != 2 || indexes[0].Name != "legacy_id" || indexes[1].Name != "legacy_name" {
+		t.Fatalf("the legacy metadata has the indexes %v", indexes)
+	}
+	if !slices.Equal(indexes[0].Columns, []string{"id"}) || !indexes[0].Unique || indexes[0].UpdatesCount != 3 || indexes[0].Keys != 10 {
+		t.Fatalf("the legacy index was read as %+v", indexes[0])
+	}
+
+	// reading does not write the file
+	read, err := os.ReadFile(metaDataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(read, data) {
+		t.Fatal("reading the legacy metadata changed the file")
+	}
+
+	indexes[1].UpdatesCount = 5
+	if err := UpdateIndexMetadata("legacy", "legacy_name", indexes[1]); err != nil {
+		t.Fatalf("failed to update the legacy metadata: %s", err)
+	}
+	read, err = os.ReadFile(metaDataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binary.BigEndian.Uint32(read[0:4]) != metadataMagic {
+		t.Fatal("the change of the metadata did not write the current format")
+	}
+
+	updated, err := GetIndexesMetadata("legacy")
+	if err != nil {
+		t.Fatalf("failed to read the converted metadata: %s", err)
+	}
+	if len(updated) != 2 || updated[1].UpdatesCount != 5 || updated[0].Keys != 10 {
+		t.Fatalf("the converted metadata has the indexes %+v", updated)
+	}
+}
+
+// the names are stored whole, and every field of the metadata is read back as it was written
+func TestMetadataFormat(t *testing.T) {
+	table := "a_table_with_a_name_longer_than_twenty_bytes"
+	long := strings.Repeat("c", 300)
+	createTestIndex(t, table, table+"_first_index", IndexOptions{Columns: []string{"id"}, Unique: true, Typed: true})
+	createTestIndex(t, table, table+"_covering_index", IndexOptions{Columns: []string{long, "name"}, Include: []string{long + "_included"}})
+	createTestIndex(t, table, table+"_hash_index", IndexOptions{Columns: []string{"name"}, Kind: HashIndex})
+	addEntry(t, table, []IndexKey{intKey(t, 1), testKey("a", "b", "x"), testKey("b")}, testRID(1))
+	if _, err := AnalyzeIndex(table, table+"_covering_index"); err != nil {
+		t.Fatalf("failed to analyze the index: %s", err)
+	}
+
+	indexes, err := GetIndexesMetadata(table)
+	if err != nil {
+		t.Fatalf("failed to read the metadata: %s", err)
+	}
+	if len(indexes) != 3 {
+		t.Fatalf("the table has %d indexes, expected 3", len(indexes))
+	}
+	first, covering, hash := indexes[0], indexes[1], indexes[2]
+	if first.Name != table+"_first_index" || !first.Unique || !first.Typed || first.Kind != BTreeIndex || first.Keys != 1 {
+		t.Fatalf("the first index was read as %+v", first)
+	}
+	if covering.Name != table+"_covering_index" || !slices.Equal(covering.Columns, []string{long, "name"}) ||
+		!slices.Equal(covering.Include, []string{long + "_included"}) || covering.Unique || covering.Typed {
+		t.Fatalf("the covering index was read with the name %s, the columns %d and the included columns %d",
+			covering.Name, len(covering.Columns), len(covering.Include))
+	}
+	if !covering.Analysis.Analyzed || covering.Analysis.Height != 1 || len(covering.Analysis.Histogram) != 1 || covering.Analysis.DistinctKeys != 1 {
+		t.Fatalf("the analysis of the covering index was read as %+v", covering.Analysis)
+	}
+	if hash.Kind != HashIndex || hash.Analysis.Analyzed {
+		t.Fatalf("the hash index was read as %+v", hash)
+	}
+
+	// the count of the header follows the deletes
+	if err := DeleteIndex(table, table+"_first_index"); err != nil {
+		t.Fatalf("failed to delete the index: %s", err)
+	}
+	metaDataPath := path.Join("indexes", table, metaDataFileName)
+	data, err := os.ReadFile(metaDataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	countOffset := 8 + len(table)
+	if count := binary.BigEndian.Uint32(data[countOffset:]); count != 2 {
+		t.Fatalf("the header counts %d indexes after the delete, expected 2", count)
+	}
+	if binary.BigEndian.Uint16(data[4:6]) != metadataFormatVersion || string(data[8:countOffset]) != table {
+		t.Fatal("the header does not have the format version and the whole name of the table")
+	}
+	indexes, err = GetIndexesMetadata(table)
+	if err != nil {
+		t.Fatalf("failed to read the metadata after the delete: %s", err)
+	}
+	if len(indexes) != 2 || indexes[0].Name != covering.Name || indexes[1].Name != hash.Name {
+		t.Fatalf("the table has the indexes %v after the delete", indexes)
+	}
+
+	// a changed byte of an entry fails its checksum, and a newer format is not read
+	corrupted := slices.Clone(data)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	if _, err := decodeIndexesMetadata(corrupted); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Fatalf("expected a checksum error for the corrupted entry, got %v", err)
+	}
+	newer := slices.Clone(data)
+	binary.BigEndian.PutUint16(newer[4:6], metadataFormatVersion+1)
+	if _, err := decodeIndexesMetadata(newer); err == nil {
+		t.Fatal("a file of a newer format version must not be read")
+	}
+	if _, err := decodeIndexesMetadata(data[:len(data)-1]); err == nil {
+		t.Fatal("a cut file must not be read")
+	}
+}
@@ -0,0 +1,368 @@
+package indexmanager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/fbptree"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// KeyFunc returns the key of the index for a row of the table heap,
+// followed by the values of the included columns of a covering index.
+type KeyFunc func(row []byte) (IndexKey, error)
+
+// RebuildThreshold tells CheckIndexRebuild when an index needs a rebuild.
+type RebuildThreshold struct {
+	// the entries removed from the index since it was built
+	Updates uint32
+	// the minimum average fill of the tree nodes, between 0 and 1.
+	// a tree of a single node is never rebuilt for its fill
+	MinFill float64
+}
+
+// IndexRebuildThreshold is the threshold used by CheckIndexRebuild, it can be changed by the engine.
+var IndexRebuildThreshold = RebuildThreshold{Updates: 1000, MinFill: 0.4}
+
+// BulkLoadOptions tells how the indexes are built from the rows of the table heap.
+type BulkLoadOptions struct {
+	// how full the tree nodes are, between 0.5 and 1, the room left is used by the later inserts
+	FillFactor float64
+	// the memory used to sort the entries, the entries that do not fit are sorted in run files
+	MemoryLimit int
+}
+
+// IndexBulkLoad is used by CreateIndexFromHeap and RebuildIndex, it can be changed by the engine.
+var IndexBulkLoad = BulkLoadOptions{FillFactor: 0.9, MemoryLimit: 64 << 20}
+
+// an entry of the index tree, included has the encoded values of the included columns
+type sortEntry struct {
+	key      []byte
+	rid      heapmanager.RID
+	included []byte
+}
+
+// returns the value of the entry in the index
+func (e sortEntry) value() []byte {
+	return append(e.rid.Bytes(), e.included...)
+}
+
+// CheckIndexRebuild returns the names of the indexes of the table that need a rebuild,
+// the ones with more updates than IndexRebuildThreshold.Updates or a tree that is filled less than IndexRebuildThreshold.MinFill.
+func CheckIndexRebuild(tableName string) ([]string, error) {
+	indexes, err := GetIndexesMetadata(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get indexes metadata: %w", err)
+	}
+
+	names := make([]string, 0)
+	for _, index := range indexes {
+		if index.UpdatesCount >= IndexRebuildThreshold.Updates {
+			names = append(names, index.Name)
+			continue
+		}
+		// the buckets of a hash table are split as it grows, they never get emptier than after the split
+		if index.Kind == HashIndex {
+			continue
+		}
+
+		stats, err := treeStats(tableName, index.Name)
+		if err != nil {
+			return nil, err
+		}
+		if stats.LeafNodes+stats.InternalNodes > 1 && stats.Fill < IndexRebuildThreshold.MinFill {
+			names = append(names, index.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// CreateIndexFromHeap creates an index on a table that already has rows, the index is built
+// from the rows of the table heap at once instead of adding them one by one, keyOf returns the key of each row.
+// nothing is created if the index cannot be built.
+func CreateIndexFromHeap(tableName string, indexName string, options IndexOptions, heapName string, keyOf KeyFunc) error {
+	tx, err := logmanager.BeginAfterCheckpoint()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(CreateIndexFromHeapTx(tx, tableName, indexName, options, heapName, keyOf))
+}
+
+// CreateIndexFromHeapTx creates the index from the rows of the table heap like CreateIndexFromHeap, in tx.
+// like RebuildIndexTx, the heap is read with the changes tx made to it and the file of the index is
+// installed when tx is committed, tx must be started with logmanager.BeginAfterCheckpoint.
+func CreateIndexFromHeapTx(tx *logmanager.Transaction, tableName string, indexName string, options IndexOptions, heapName string, keyOf KeyFunc) error {
+	if err := checkIndexOptions(indexName, options); err != nil {
+		return err
+	}
+
+	indexDir := path.Join("indexes", tableName)
+	if err := os.MkdirAll(indexDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	indexMetadata, buildPath, err := buildIndexFile(tableName, newIndexMetadata(indexName, options), heapName, keyOf)
+	if err != nil {
+		return err
+	}
+	if err := addIndexMetadata(tx, tableName, indexMetadata); err != nil {
+		os.Remove(buildPath)
+		return err
+	}
+	tx.OnCommit(func() error {
+		return installIndexBuild(tableName, indexName, buildPath)
+	})
+	return nil
+}
+
+// RebuildIndex builds the index again from the rows of the table heap, keyOf returns the key of each row.
+// the new tree is built next to the old one and replaces it once it is complete, so a failure or a crash
+// during the rebuild leaves the old index as it was. the version of the index is bumped and its updates are reset.
+// the rebuild runs in a transaction, the table does not change from the read of the heap to the replacement of the index.
+func RebuildIndex(tableName string, indexName string, heapName string, keyOf KeyFunc) error {
+	indexMetadata, err := getIndexMetadata(tableName, indexName)
+	if err != nil {
+		return err
+	}
+	return replaceIndex(tableName, indexMetadata, heapName, keyOf)
+}
+
+// builds the index from the heap and replaces the index file and its metadata with the new ones in a transaction
+func replaceIndex(tableName string, indexMetadata IndexMetadata, heapName string, keyOf KeyFunc) error {
+	tx, err := logmanager.BeginAfterCheckpoint()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(rebuildIndexTx(tx, tableName, indexMetadata, heapName, keyOf))
+}
+
+// RebuildIndexTx builds the index again from the rows of the table heap like RebuildIndex, in tx.
+// the heap is read with the changes tx made to it, the metadata of the new version is written in tx
+// and the new file replaces the index when tx is committed, before another transaction can write the index.
+//
+// the file is replaced without the log, so the log must have no write of the old index when the file
+// is replaced: tx must be started with logmanager.BeginAfterCheckpoint and must not write the index.
+//
+//	tx, err := logmanager.BeginAfterCheckpoint()
+//	if err != nil {
+//		return err
+//	}
+//	if err := heapmanager.UpdateRowInHeapTx(tx, "student", rid, row); err != nil {
+//		return tx.Finish(err)
+//	}
+//	return tx.Finish(indexmanager.RebuildIndexTx(tx, "student", "student_name", "student", keyOf))
+func RebuildIndexTx(tx *logmanager.Transaction, tableName string, indexName string, heapName string, keyOf KeyFunc) error {
+	indexMetadata, err := getIndexMetadata(tableName, indexName)
+	if err != nil {
+		return err
+	}
+	return rebuildIndexTx(tx, tableName, indexMetadata, heapName, keyOf)
+}
+
+// returns the path of the file built for the given version of the index
+func indexBuildPath(tableName string, indexName string, version uint32) string {
+	return path.Join("indexes", tableName, fmt.Sprintf("%s.data.rebuild-%d", indexName, version))
+}
+
+// removes the files left by the builds of the index that were not committed
+func removeIndexBuilds(tableName string, indexName string) error {
+	paths, err := filepath.Glob(path.Join("indexes", tableName, indexName+".data.rebuild-*"))
+	if err != nil {
+		return err
+	}
+	for _, buildPath := range paths {
+		if err := os.Remove(buildPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// builds the index from the heap in the file of its next version and writes the metadata of that version in tx.
+// the file is renamed over the index when tx commits. a crash between the two leaves the file of the committed
+// version, which the next read of the metadata renames into place (see installIndexBuilds).
+func rebuildIndexTx(tx *logmanager.Transaction, tableName string, indexMetadata IndexMetadata, heapName string, keyOf KeyFunc) error {
+	indexMetadata, buildPath, err := buildIndexFile(tableName, indexMetadata, heapName, keyOf)
+	if err != nil {
+		return err
+	}
+	if err := updateIndexMetadata(tx, tableName, indexMetadata.Name, indexMetadata); err != nil {
+		os.Remove(buildPath)
+		return err
+	}
+	tx.OnCommit(func() error {
+		return installIndexBuild(tableName, indexMetadata.Name, buildPath)
+	})
+	return nil
+}
+
+// builds a new tree (or hash table) for the index from the rows of the heap in the file of its next version,
+// it returns the metadata of that version and the path of the file. the file is synced, so it can replace
+// the index once the metadata is committed. the file of a version that was not committed is removed by the next build.
+func buildIndexFile(tableName string, indexMetadata IndexMetadata, heapName string, keyOf KeyFunc) (IndexMetadata, string, error) {
+	if err := removeIndexBuilds(tableName, indexMetadata.Name); err != nil {
+		return IndexMetadata{}, "", fmt.Errorf("failed to remove the old builds of index %s: %w", indexMetadata.Name, err)
+	}
+
+	info := indexMetadata.info()
+	indexMetadata.UpdatesCount = 0
+	indexMetadata.Version++
+	buildPath := indexBuildPath(tableName, info.name, indexMetadata.Version)
+
+	sorter := newEntrySorter(path.Dir(buildPath), info.name, IndexBulkLoad.MemoryLimit)
+	defer sorter.close()
+
+	if err := readIndexEntries(info, heapName, keyOf, sorter); err != nil {
+		return IndexMetadata{}, "", fmt.Errorf("failed to read the entries of index %s: %w", info.name, err)
+	}
+
+	buildFile := buildIndexTree
+	if info.kind == HashIndex {
+		buildFile = buildIndexHash
+	}
+	if err := buildFile(buildPath, info, sorter); err != nil {
+		os.Remove(buildPath)
+		return IndexMetadata{}, "", fmt.Errorf("failed to build index %s: %w", info.name, err)
+	}
+	if err := syncDir(path.Dir(buildPath)); err != nil {
+		os.Remove(buildPath)
+		return IndexMetadata{}, "", err
+	}
+	indexMetadata.Keys = uint32(sorter.count)
+	return indexMetadata, buildPath, nil
+}
+
+// renames the new file of the index over the index file, once the metadata of the build is committed
+func installIndexBuild(tableName string, indexName string, buildPath string) error {
+	indexPath := path.Join("indexes", tableName, indexName+".data")
+
+	metaFilEMutex.Lock()
+	err := os.Rename(buildPath, indexPath)
+	// a read of the metadata may have installed the file already
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		// the next read of the metadata tries again
+		delete(installedTables, tableName)
+	}
+	metaFilEMutex.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to replace index file %s: %w", indexPath, err)
+	}
+	return syncDir(path.Dir(indexPath))
+}
+
+// reads the rows of the heap and adds the entries of the index to the sorter
+func readIndexEntries(info indexInfo, heapName string, keyOf KeyFunc, sorter *entrySorter) error {
+	scanner, err := heapmanager.OpenHeapScanner(heapName)
+	if err != nil {
+		return err
+	}
+	defer scanner.Close()
+
+	for scanner.Next() {
+		key, err := keyOf(scanner.Row())
+		if err != nil {
+			return fmt.Errorf("failed to get the key of row %v: %w", scanner.RID(), err)
+		}
+
+		entryKey, err := info.entryKey(key, scanner.RID())
+		if err != nil {
+			return err
+		}
+		value, err := info.entryValue(key, scanner.RID())
+		if err != nil {
+			return err
+		}
+
+		if err := sorter.add(sortEntry{key: entryKey, rid: scanner.RID(), included: value[ridSize:]}); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// builds a new tree file bottom-up with the sorted entries, its writes are not logged
+// since the file is synced before it replaces the index.
+func buildIndexTree(treePath string, info indexInfo, sorter *entrySorter) error {
+	if err := os.Remove(treePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	next, err := sorter.sorted()
+	if err != nil {
+		return err
+	}
+
+	tree, err := fbptree.Open(treePath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder))
+	if err != nil {
+		return fmt.Errorf("failed to open B+ tree %s: %w", treePath, err)
+	}
+
+	var previous sortEntry
+	read := 0
+	err = tree.BulkLoad(sorter.count, func() ([]byte, []byte, error) {
+		entry, err := next()
+		if err == io.EOF {
+			return nil, nil, errors.New("the sorted entries ended early")
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		//only the keys of a unique index without a null can be the same, the others have the rid in the key
+		if read > 0 && bytes.Equal(previous.key, entry.key) {
+			return nil, nil, fmt.Errorf("rows %v and %v have the same key in unique index %s", previous.rid, entry.rid, info.name)
+		}
+		previous = entry
+		read++
+
+		return entry.key, entry.value(), nil
+	}, IndexBulkLoad.FillFactor)
+	if err != nil {
+		tree.Close()
+		return err
+	}
+
+	// closing the tree syncs its file
+	return tree.Close()
+}
+
+// returns the statistics of the tree of the index
+func treeStats(tableName string, indexName string) (fbptree.Stats, error) {
+	indexPath := path.Join("indexes", tableName, indexName+".data")
+	tree, err := fbptree.Open(indexPath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder))
+	if err != nil {
+		return fbptree.Stats{}, fmt.Errorf("failed to open B+ tree %s: %w", indexPath, err)
+	}
+	defer tree.Close()
+
+	stats, err := tree.Stats()
+	if err != nil {
+		return fbptree.Stats{}, fmt.Errorf("failed to read the statistics of index %s: %w", indexName, err)
+	}
+	return stats, nil
+}
+
+// syncs the directory so a file renamed in it stays renamed after a crash
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package indexmanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// the key of a row of the tests is the row itself
+func rowKey(row []byte) (IndexKey, error) {
+	return IndexKey{row}, nil
+}
+
+// adds the rows to the heap and their entries to the indexes of the table
+func addRows(t *testing.T, table string, rows ...string) []heapmanager.RID {
+	t.Helper()
+
+	rids := make([]heapmanager.RID, len(rows))
+	for i, row := range rows {
+		rid, err := heapmanager.AddRowToHeap(table, []byte(row))
+		if err != nil {
+			t.Fatalf("failed to add row %q: %s", row, err)
+		}
+		addEntry(t, table, []IndexKey{testKey(row)}, rid)
+		rids[i] = rid
+	}
+	return rids
+}
+
+func TestRebuildIndex(t *testing.T) {
+	if err := heapmanager.CreateHeap("rebuild"); err != nil {
+		t.Fatalf("failed to create the heap: %s", err)
+	}
+	createTestIndex(t, "rebuild", "rebuild_name", IndexOptions{Columns: []string{"name"}, Unique: true})
+	rids := addRows(t, "rebuild", "a", "b", "c")
+	removeEntry(t, "rebuild", []IndexKey{testKey("b")}, rids[1])
+	if err := heapmanager.DeleteRowFromHeap("rebuild", rids[1]); err != nil {
+		t.Fatalf("failed to delete a row: %s", err)
+	}
+
+	if err := RebuildIndex("rebuild", "rebuild_name", "rebuild", rowKey); err != nil {
+		t.Fatalf("failed to rebuild the index: %s", err)
+	}
+	checkLookup(t, "rebuild", "rebuild_name", testKey("a"), rids[0])
+	checkLookup(t, "rebuild", "rebuild_name", testKey("b"))
+	checkLookup(t, "rebuild", "rebuild_name", testKey("c"), rids[2])
+
+	metadata, err := getIndexMetadata("rebuild", "rebuild_name")
+	if err != nil {
+		t.Fatalf("failed to read the metadata: %s", err)
+	}
+	if metadata.Version != 1 || metadata.UpdatesCount != 0 || metadata.Keys != 2 {
+		t.Fatalf("the index has version %d, %d updates and %d keys after the rebuild, expected 1, 0 and 2",
+			metadata.Version, metadata.UpdatesCount, metadata.Keys)
+	}
+}
+
+// a write of the table that starts during the rebuild waits for the new index, it is not lost when the index is replaced
+func TestRebuildIndexKeepsConcurrentWrites(t *testing.T) {
+	if err := heapmanager.CreateHeap("concurrent"); err != nil {
+		t.Fatalf("failed to create the heap: %s", err)
+	}
+	createTestIndex(t, "concurrent", "concurrent_name", IndexOptions{Columns: []string{"name"}})
+	rids := addRows(t, "concurrent", "a", "b")
+
+	done := make(chan error, 1)
+	started := false
+	keyOf := func(row []byte) (IndexKey, error) {
+		if !started {
+			started = true
+			go func() {
+				done <- AddEntryToTableIndexes("concurrent", []IndexKey{testKey("z")}, testRID(500))
+			}()
+		}
+		return rowKey(row)
+	}
+	if err := RebuildIndex("concurrent", "concurrent_name", "concurrent", keyOf); err != nil {
+		t.Fatalf("failed to rebuild the index: %s", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("failed to add the entry during the rebuild: %s", err)
+	}
+
+	checkLookup(t, "concurrent", "concurrent_name", testKey("a"), rids[0])
+	checkLookup(t, "concurrent", "concurrent_name", testKey("z"), testRID(500))
+}
+
+// the rebuild reads the rows written by its transaction, and a rollback leaves the old index
+func TestRebuildIndexTx(t *testing.T) {
+	if err := heapmanager.CreateHeap("rebuildtx"); err != nil {
+		t.Fatalf("failed to create the heap: %s", err)
+	}
+	createTestIndex(t, "rebuildtx", "rebuildtx_name", IndexOptions{Columns: []string{"name"}})
+	rids := addRows(t, "rebuildtx", "a")
+
+	rebuild := func(row string) {
+		tx, err := logmanager.BeginAfterCheckpoint()
+		if err != nil {
+			t.Fatalf("failed to begin: %s", err)
+		}
+		if err := heapmanager.UpdateRowInHeapTx(tx, "rebuildtx", rids[0], []byte(row)); err != nil {
+			t.Fatalf("failed to update the row: %s", err)
+		}
+		if err := RebuildIndexTx(tx, "rebuildtx", "rebuildtx_name", "rebuildtx", rowKey); err != nil {
+			t.Fatalf("failed to rebuild the index: %s", err)
+		}
+		checkLookup(t, "rebuildtx", "rebuildtx_name", testKey("a"), rids[0])
+
+		if row == "b" {
+			if err := tx.Finish(errors.New("the change failed")); err == nil {
+				t.Fatal("Finish must return the error of the change")
+			}
+		} else if err := tx.Commit(); err != nil {
+			t.Fatalf("failed to commit: %s", err)
+		}
+	}
+
+	// the file is replaced by the commit, the old index is read until then
+	rebuild("b")
+	checkLookup(t, "rebuildtx", "rebuildtx_name", testKey("a"), rids[0])
+	checkLookup(t, "rebuildtx", "rebuildtx_name", testKey("b"))
+
+	rebuild("c")
+	checkLookup(t, "rebuildtx", "rebuildtx_name", testKey("a"))
+	checkLookup(t, "rebuildtx", "rebuildtx_name", testKey("c"), rids[0])
+
+	metadata, err := getIndexMetadata("rebuildtx", "rebuildtx_name")
+	if err != nil {
+		t.Fatalf("failed to read the metadata: %s", err)
+	}
+	if metadata.Version != 1 {
+		t.Fatalf("the index has version %d, expected 1 since the first rebuild was rolled back", metadata.Version)
+	}
+}
+
+// the tree is built with the fill factor of IndexBulkLoad, from entries sorted in run files past its memory limit
+func TestBulkLoad(t *testing.T) {
+	if err := heapmanager.CreateHeap("bulkload"); err != nil {
+		t.Fatalf("failed to create the heap: %s", err)
+	}
+	rids := make([]heapmanager.RID, 2000)
+	for i := range rids {
+		var err error
+		// the rows are added out of key order
+		if rids[i], err = heapmanager.AddRowToHeap("bulkload", []byte(fmt.Sprintf("row%04d", (i*7)%2000))); err != nil {
+			t.Fatalf("failed to add row %d: %s", i, err)
+		}
+	}
+
+	defer func(options BulkLoadOptions) { IndexBulkLoad = options }(IndexBulkLoad)
+
+	// the run files are created next to the index, they are removed once the tree is built
+	spilled := false
+	keyOf := func(row []byte) (IndexKey, error) {
+		if runs, _ := filepath.Glob("indexes/bulkload/*.sort-*"); len(runs) > 0 {
+			spilled = true
+		}
+		return rowKey(row)
+	}
+
+	fills := make(map[float64]float64)
+	for _, fillFactor := range []float64{0.5, 1} {
+		IndexBulkLoad = BulkLoadOptions{FillFactor: fillFactor, MemoryLimit: 4096}
+		index := fmt.Sprintf("bulkload_%d", int(fillFactor*100))
+		if err := CreateIndexFromHeap("bulkload", index, IndexOptions{Columns: []string{"name"}, Unique: true}, "bulkload", keyOf); err != nil {
+			t.Fatalf("failed to create %s: %s", index, err)
+		}
+
+		for i := 0; i < len(rids); i += 99 {
+			checkLookup(t, "bulkload", index, testKey(fmt.Sprintf("row%04d", (i*7)%2000)), rids[i])
+		}
+		stats, err := AnalyzeIndex("bulkload", index)
+		if err != nil {
+			t.Fatalf("failed to analyze %s: %s", index, err)
+		}
+		if stats.Keys != len(rids) || stats.DistinctKeys != len(rids) {
+			t.Fatalf("%s has %d keys, %d distinct, expected %d", index, stats.Keys, stats.DistinctKeys, len(rids))
+		}
+		fills[fillFactor] = stats.Fill
+	}
+
+	if !spilled {
+		t.Fatal("the entries were sorted in memory past the memory limit")
+	}
+	if runs, _ := filepath.Glob("indexes/bulkload/*.sort-*"); len(runs) > 0 {
+		t.Fatalf("the run files %v were left", runs)
+	}
+	if fills[0.5] < 0.4 || fills[0.5] > 0.65 || fills[1] < 0.85 {
+		t.Fatalf("the trees built with the fill factors 0.5 and 1 have the fills %.2f and %.2f", fills[0.5], fills[1])
+	}
+
+	// a unique index with two rows of the same key is not created
+	if _, err := heapmanager.AddRowToHeap("bulkload", []byte("row0001")); err != nil {
+		t.Fatalf("failed to add a row: %s", err)
+	}
+	if err := CreateIndexFromHeap("bulkload", "bulkload_dup", IndexOptions{Columns: []string{"name"}, Unique: true}, "bulkload", rowKey); err == nil {
+		t.Fatal("a unique index must not be built from rows with the same key")
+	}
+	if _, err := getIndexMetadata("bulkload", "bulkload_dup"); err == nil {
+		t.Fatal("the metadata of the failed index was written")
+	}
+	if _, err := os.Stat("indexes/bulkload/bulkload_dup.data"); !os.IsNotExist(err) {
+		t.Fatalf("the file of the failed index was created: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package indexmanager
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+)
+
+// the entries of an index being built are sorted in memory up to a limit,
+// then each sorted batch is written to a run file next to the index and the runs are merged:
+// | KeySize 2B | Key | RID 6B | IncludedSize 2B | Included | ...
+
+// an entry takes its key and rid and about this much memory for the slice headers
+const sortEntryOverhead = 48
+
+// sorts the entries of an index, in memory or with run files when they do not fit
+type entrySorter struct {
+	// the directory of the run files and the prefix of their names
+	dir, prefix string
+	memoryLimit int
+
+	entries []sortEntry
+	size    int
+	count   int
+	runs    []*os.File
+}
+
+func newEntrySorter(dir string, prefix string, memoryLimit int) *entrySorter {
+	return &entrySorter{dir: dir, prefix: prefix, memoryLimit: memoryLimit}
+}
+
+func (s *entrySorter) add(entry sortEntry) error {
+	s.entries = append(s.entries, entry)
+	s.size += len(entry.key) + ridSize + len(entry.included) + sortEntryOverhead
+	s.count++
+
+	if s.size >= s.memoryLimit {
+		return s.spill()
+	}
+	return nil
+}
+
+// writes the sorted entries in memory to a new run file
+func (s *entrySorter) spill() error {
+	s.sortEntries()
+
+	file, err := os.CreateTemp(s.dir, s.prefix+".sort-*")
+	if err != nil {
+		return fmt.Errorf("failed to create a sort run: %w", err)
+	}
+	s.runs = append(s.runs, file)
+
+	w := bufio.NewWriter(file)
+	for _, entry := range s.entries {
+		if err := binary.Write(w, binary.BigEndian, uint16(len(entry.key))); err != nil {
+			return err
+		}
+		if _, err := w.Write(entry.key); err != nil {
+			return err
+		}
+		if _, err := w.Write(entry.rid.Bytes()); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.BigEndian, uint16(len(entry.included))); err != nil {
+			return err
+		}
+		if _, err := w.Write(entry.included); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write a sort run: %w", err)
+	}
+
+	s.entries = s.entries[:0]
+	s.size = 0
+	return nil
+}
+
+func (s *entrySorter) sortEntries() {
+	sort.Slice(s.entries, func(i, j int) bool {
+		return bytes.Compare(s.entries[i].key, s.entries[j].key) < 0
+	})
+}
+
+// returns a function that reads the sorted entries one by one, it returns io.EOF after the last one
+func (s *entrySorter) sorted() (func() (sortEntry, error), error) {
+	if len(s.runs) == 0 {
+		s.sortEntries()
+		i := 0
+		return func() (sortEntry, error) {
+			if i == len(s.entries) {
+				return sortEntry{}, io.EOF
+			}
+			i++
+			return s.entries[i-1], nil
+		}, nil
+	}
+
+	if len(s.entries) > 0 {
+		if err := s.spill(); err != nil {
+			return nil, err
+		}
+	}
+
+	merge := &runMerge{}
+	for _, run := range s.runs {
+		if _, err := run.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if err := merge.push(&runReader{r: bufio.NewReader(run)}); err != nil {
+			return nil, err
+		}
+	}
+	return merge.next, nil
+}
+
+// removes the run files
+func (s *entrySorter) close() {
+	for _, run := range s.runs {
+		run.Close()
+		os.Remove(run.Name())
+	}
+	s.runs = nil
+}
+
+// reads the entries of a run file
+type runReader struct {
+	r     *bufio.Reader
+	entry sortEntry
+}
+
+func (r *runReader) read() error {
+	var keySize uint16
+	if err := binary.Read(r.r, binary.BigEndian, &keySize); err != nil {
+		return err
+	}
+
+	data := make([]byte, int(keySize)+ridSize+2)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return fmt.Errorf("failed to read a sort run: %w", err)
+	}
+
+	rid, err := heapmanager.RIDFromBytes(data[keySize : int(keySize)+ridSize])
+	if err != nil {
+		return err
+	}
+
+	included := make([]byte, binary.BigEndian.Uint16(data[int(keySize)+ridSize:]))
+	if _, err := io.ReadFull(r.r, included); err != nil {
+		return fmt.Errorf("failed to read a sort run: %w", err)
+	}
+	r.entry = sortEntry{key: data[:keySize], rid: rid, included: included}
+	return nil
+}
+
+// merges the runs with a min heap of their next entries
+type runMerge []*runReader
+
+func (m runMerge) Len() int { return len(m) }
+func (m runMerge) Less(i, j int) bool {
+	return bytes.Compare(m[i].entry.key, m[j].entry.key) < 0
+}
+func (m runMerge) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m *runMerge) Push(x any)   { *m = append(*m, x.(*runReader)) }
+func (m *runMerge) Pop() any {
+	old := *m
+	r := old[len(old)-1]
+	*m = old[:len(old)-1]
+	return r
+}
+
+// adds the run to the merge if it has an entry
+func (m *runMerge) push(r *runReader) error {
+	err := r.read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	heap.Push(m, r)
+	return nil
+}
+
+func (m *runMerge) next() (sortEntry, error) {
+	if m.Len() == 0 {
+		return sortEntry{}, io.EOF
+	}
+
+	r := heap.Pop(m).(*runReader)
+	entry := r.entry
+	if err := m.push(r); err != nil {
+		return sortEntry{}, err
+	}
+	return entry, nil
+}
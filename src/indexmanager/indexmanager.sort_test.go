@@ -0,0 +1,47 @@
+package indexmanager
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// the entries that do not fit in the memory limit are sorted in run files, the merge of the runs reads them in order
+func TestEntrySorterSpills(t *testing.T) {
+	sorter := newEntrySorter(".", "spill", 1024)
+
+	order := rand.New(rand.NewSource(1)).Perm(500)
+	for _, i := range order {
+		if err := sorter.add(sortEntry{key: []byte(fmt.Sprintf("key%04d", i)), rid: testRID(i), included: []byte{byte(i)}}); err != nil {
+			t.Fatalf("failed to add entry %d: %s", i, err)
+		}
+	}
+	if len(sorter.runs) < 2 {
+		t.Fatalf("500 entries were sorted in %d runs with a limit of 1KB", len(sorter.runs))
+	}
+
+	next, err := sorter.sorted()
+	if err != nil {
+		t.Fatalf("failed to merge the runs: %s", err)
+	}
+	for i := 0; i < 500; i++ {
+		entry, err := next()
+		if err != nil {
+			t.Fatalf("failed to read entry %d: %s", i, err)
+		}
+		if !bytes.Equal(entry.key, []byte(fmt.Sprintf("key%04d", i))) || entry.rid != testRID(i) || !bytes.Equal(entry.included, []byte{byte(i)}) {
+			t.Fatalf("entry %d is %q %v %v", i, entry.key, entry.rid, entry.included)
+		}
+	}
+	if _, err := next(); err != io.EOF {
+		t.Fatalf("expected io.EOF after the last entry, got %v", err)
+	}
+
+	sorter.close()
+	if runs, _ := filepath.Glob("spill.sort-*"); len(runs) > 0 {
+		t.Fatalf("the runs %v were not removed", runs)
+	}
+}
@@ -0,0 +1,294 @@
+package indexmanager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"path"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/fbptree"
+)
+
+// the histogram of an index has this many buckets at most, their upper bounds are cut to this many bytes
+const (
+	histogramBuckets   = 16
+	histogramBoundSize = 28
+)
+
+// IndexStats describes the shape of the tree of an index and how its keys are spread.
+// the planner uses them to estimate how many rows a lookup or a range scan of the index reads.
+type IndexStats struct {
+	// the number of entries of the index, kept up to date by every change
+	Keys int
+	// the rest is written by AnalyzeIndex and is only as fresh as its last call
+	IndexAnalysis
+
+	index indexInfo
+}
+
+// IndexAnalysis holds the statistics of an index computed by AnalyzeIndex.
+type IndexAnalysis struct {
+	// false for an index that was never analyzed
+	Analyzed bool
+	// the number of levels of the tree and its nodes
+	Height        int
+	LeafNodes     int
+	InternalNodes int
+	// the average number of keys of a node divided by the maximum, between 0 and 1
+	Fill float64
+	// the number of different keys, the entries of a non-unique index can have the same key
+	DistinctKeys int
+	// the entries in key order split in buckets of about the same number of entries (equi-depth)
+	Histogram []HistogramBucket
+}
+
+// HistogramBucket is a bucket of the histogram of an index, it holds the entries after the upper bound
+// of the bucket before it, up to its own upper bound.
+type HistogramBucket struct {
+	// the largest key of the bucket encoded like in the tree, cut to histogramBoundSize bytes
+	UpperBound []byte
+	// the number of entries and of different keys in the bucket
+	Keys         int
+	DistinctKeys int
+}
+
+// GetIndexStats returns the statistics of the index kept in its metadata.
+func GetIndexStats(tableName string, indexName string) (IndexStats, error) {
+	indexMetadata, err := getIndexMetadata(tableName, indexName)
+	if err != nil {
+		return IndexStats{}, err
+	}
+	return indexStats(indexMetadata), nil
+}
+
+func indexStats(indexMetadata IndexMetadata) IndexStats {
+	return IndexStats{Keys: int(indexMetadata.Keys), IndexAnalysis: indexMetadata.Analysis, index: indexMetadata.info()}
+}
+
+// AnalyzeIndex reads the whole tree of the index to compute its statistics and writes them to its metadata,
+// the number of keys of the metadata is set to the number of entries of the tree.
+// it should be called again once the index had many changes, the statistics are not updated by the changes.
+func AnalyzeIndex(tableName string, indexName string) (IndexStats, error) {
+	indexMetadata, err := getIndexMetadata(tableName, indexName)
+	if err != nil {
+		return IndexStats{}, err
+	}
+
+	analyze := analyzeTree
+	if indexMetadata.Kind == HashIndex {
+		analyze = analyzeHash
+	}
+	stats, err := analyze(tableName, indexMetadata.info())
+	if err != nil {
+		return IndexStats{}, fmt.Errorf("failed to analyze index %s: %w", indexName, err)
+	}
+
+	indexMetadata.Keys = uint32(stats.Keys)
+	indexMetadata.Analysis = stats.IndexAnalysis
+	if err := UpdateIndexMetadata(tableName, indexName, indexMetadata); err != nil {
+		return IndexStats{}, err
+	}
+	return stats, nil
+}
+
+// AnalyzeTable analyzes all the indexes of the table.
+func AnalyzeTable(tableName string) error {
+	indexes, err := GetIndexesMetadata(tableName)
+	if err != nil {
+		return fmt.Errorf("failed to get indexes metadata: %w", err)
+	}
+
+	for _, index := range indexes {
+		if _, err := AnalyzeIndex(tableName, index.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetIndexHeight returns the height of the index, a hash index has no height.
+func GetIndexHeight(tableName string, indexName string) (int32, error) {
+	indexMetadata, err := getIndexMetadata(tableName, indexName)
+	if err != nil {
+		return 0, err
+	}
+	if indexMetadata.Kind == HashIndex {
+		return 0, fmt.Errorf("index %s is a hash index: %w", indexName, ErrHashIndexScan)
+	}
+
+	indexPath := path.Join("indexes", tableName, indexName+".data")
+	tree, err := fbptree.Open(indexPath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open B+ tree %s: %w", indexPath, err)
+	}
+	defer tree.Close()
+
+	height, err := tree.Height()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the height of index %s: %w", indexName, err)
+	}
+	return int32(height), nil
+}
+
+// EqualSelectivity returns the estimated fraction of the entries of the index that have the same full key.
+func (s IndexStats) EqualSelectivity() float64 {
+	if s.index.unique {
+		if s.Keys == 0 {
+			return 0
+		}
+		return 1 / float64(s.Keys)
+	}
+	if !s.Analyzed || s.DistinctKeys == 0 {
+		return 1
+	}
+	return 1 / float64(s.DistinctKeys)
+}
+
+// RangeSelectivity returns the estimated fraction of the entries of the index in the range of options,
+// Reverse and Limit are ignored. the buckets of the histogram inside the range are counted whole,
+// and the part of a bucket cut by a bound is guessed from where the bound is between the bounds of the bucket,
+// but a bucket counts for one of its keys at least when the range is inside it.
+// an index that was never analyzed returns 1, a hash index cannot be scanned and returns ErrHashIndexScan.
+func (s IndexStats) RangeSelectivity(options ScanOptions) (float64, error) {
+	if s.index.kind == HashIndex {
+		return 0, fmt.Errorf("index %s is a hash index: %w", s.index.name, ErrHashIndexScan)
+	}
+	r, err := s.index.keyRange(options)
+	if err != nil {
+		return 0, err
+	}
+	if !s.Analyzed {
+		return 1, nil
+	}
+
+	start, end := cutBound(r.start), cutBound(r.end)
+
+	total, inRange := 0.0, 0.0
+	lower := []byte{}
+	for _, bucket := range s.Histogram {
+		total += float64(bucket.Keys)
+		upper := bucket.UpperBound
+
+		// the keys of the bucket are after lower and up to upper
+		before := start != nil && bytes.Compare(upper, start) < 0
+		after := end != nil && bytes.Compare(end, lower) <= 0
+		if !before && !after {
+			from, to := 0.0, 1.0
+			startsIn := start != nil && bytes.Compare(start, lower) > 0
+			endsIn := end != nil && bytes.Compare(end, upper) <= 0
+			if startsIn {
+				from = boundPosition(start, lower, upper)
+			}
+			if endsIn {
+				to = boundPosition(end, lower, upper)
+			}
+
+			keys := float64(bucket.Keys) * max(0, to-from)
+			if startsIn && endsIn {
+				keys = max(keys, float64(bucket.Keys)/float64(max(1, bucket.DistinctKeys)))
+			}
+			inRange += keys
+		}
+		lower = upper
+	}
+
+	if total == 0 {
+		return 0, nil
+	}
+	return min(1, inRange/total), nil
+}
+
+// returns where the key is between lower and upper, from 0 to 1, as if the 8 bytes after
+// the prefix of the bounds were numbers spread evenly between them
+func boundPosition(key, lower, upper []byte) float64 {
+	prefix := 0
+	for prefix < len(lower) && prefix < len(upper) && lower[prefix] == upper[prefix] {
+		prefix++
+	}
+
+	number := func(b []byte) float64 {
+		var n [8]byte
+		if prefix < len(b) {
+			copy(n[:], b[prefix:])
+		}
+		return float64(binary.BigEndian.Uint64(n[:]))
+	}
+
+	low, high, k := number(lower), number(upper), number(key)
+	if high <= low {
+		return 0.5
+	}
+	return min(1, max(0, (k-low)/(high-low)))
+}
+
+// reads the whole tree of the index and returns its statistics
+func analyzeTree(tableName string, info indexInfo) (IndexStats, error) {
+	indexPath := path.Join("indexes", tableName, info.name+".data")
+	tree, err := fbptree.Open(indexPath, fbptree.PageSize(indexPageSize), fbptree.Order(indexOrder))
+	if err != nil {
+		return IndexStats{}, fmt.Errorf("failed to open B+ tree %s: %w", indexPath, err)
+	}
+	defer tree.Close()
+
+	shape, err := tree.Stats()
+	if err != nil {
+		return IndexStats{}, err
+	}
+
+	stats := IndexStats{
+		Keys: shape.Keys,
+		IndexAnalysis: IndexAnalysis{
+			Analyzed:      true,
+			Height:        shape.Height,
+			LeafNodes:     shape.LeafNodes,
+			InternalNodes: shape.InternalNodes,
+			Fill:          shape.Fill,
+			Histogram:     make([]HistogramBucket, 0),
+		},
+		index: info,
+	}
+
+	// the entries are split in buckets of the same size, the last entry of each bucket is its upper bound
+	buckets := min(histogramBuckets, shape.Keys)
+	bucket := HistogramBucket{}
+	var previous []byte
+
+	cursor := tree.Cursor()
+	read := 0
+	for ok := cursor.Seek(nil); ok; ok = cursor.Next() {
+		key := info.entryKeyWithoutRID(cursor.Key())
+
+		if read == 0 || !bytes.Equal(key, previous) {
+			stats.DistinctKeys++
+			bucket.DistinctKeys++
+		} else if bucket.Keys == 0 {
+			// the key goes on from the bucket before
+			bucket.DistinctKeys++
+		}
+		previous = append(previous[:0], key...)
+
+		bucket.Keys++
+		read++
+		if read == (len(stats.Histogram)+1)*shape.Keys/buckets {
+			bucket.UpperBound = cutBound(key)
+			stats.Histogram = append(stats.Histogram, bucket)
+			bucket = HistogramBucket{}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return IndexStats{}, err
+	}
+	if read != shape.Keys {
+		return IndexStats{}, fmt.Errorf("the tree has %d keys in its leaves but %d were read", shape.Keys, read)
+	}
+
+	return stats, nil
+}
+
+// returns the first bytes of the key that are kept as a bound of the histogram
+func cutBound(key []byte) []byte {
+	if key == nil {
+		return nil
+	}
+	return append([]byte{}, key[:min(len(key), histogramBoundSize)]...)
+}
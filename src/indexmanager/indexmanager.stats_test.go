@@ -0,0 +1,188 @@
+package indexmanager
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+)
+
+func analyzeIndex(t *testing.T, table string, index string) IndexStats {
+	t.Helper()
+
+	stats, err := AnalyzeIndex(table, index)
+	if err != nil {
+		t.Fatalf("failed to analyze %s: %s", index, err)
+	}
+	return stats
+}
+
+// checks that the histogram has the entries of the index in buckets of about the same size, in key order
+func checkHistogram(t *testing.T, stats IndexStats) {
+	t.Helper()
+
+	keys := 0
+	for i, bucket := range stats.Histogram {
+		keys += bucket.Keys
+		if i > 0 && bytes.Compare(stats.Histogram[i-1].UpperBound, bucket.UpperBound) > 0 {
+			t.Fatalf("the upper bound of bucket %d is before the one of bucket %d", i, i-1)
+		}
+		if diff := bucket.Keys - stats.Keys/len(stats.Histogram); diff < -1 || diff > 1 {
+			t.Fatalf("bucket %d has %d of the %d keys of %d buckets", i, bucket.Keys, stats.Keys, len(stats.Histogram))
+		}
+	}
+	if keys != stats.Keys || len(stats.Histogram) != min(histogramBuckets, stats.Keys) {
+		t.Fatalf("the histogram has %d keys in %d buckets, the index has %d keys", keys, len(stats.Histogram), stats.Keys)
+	}
+}
+
+// the number of keys follows every change, the rest of the statistics is as of the last analysis
+func TestIndexStats(t *testing.T) {
+	createTestIndex(t, "stats", "stats_name", IndexOptions{Columns: []string{"name"}})
+
+	// 30 keys with 10 rows each
+	for i := 0; i < 300; i++ {
+		addEntry(t, "stats", []IndexKey{testKey(fmt.Sprintf("name%02d", i%30))}, testRID(i))
+	}
+	stats, err := GetIndexStats("stats", "stats_name")
+	if err != nil {
+		t.Fatalf("failed to read the stats: %s", err)
+	}
+	if stats.Keys != 300 || stats.Analyzed || stats.EqualSelectivity() != 1 {
+		t.Fatalf("the index has the stats %+v before the analysis", stats)
+	}
+
+	stats = analyzeIndex(t, "stats", "stats_name")
+	if stats.Keys != 300 || stats.DistinctKeys != 30 || stats.LeafNodes < 2 || stats.InternalNodes < 1 {
+		t.Fatalf("the analysis found %d keys, %d distinct, in %d leaves and %d internal nodes",
+			stats.Keys, stats.DistinctKeys, stats.LeafNodes, stats.InternalNodes)
+	}
+	height, err := GetIndexHeight("stats", "stats_name")
+	if err != nil {
+		t.Fatalf("failed to read the height: %s", err)
+	}
+	if stats.Height != int(height) || stats.Height < 2 {
+		t.Fatalf("the analysis found a height of %d, the tree has %d", stats.Height, height)
+	}
+	if stats.Fill <= 0 || stats.Fill > 1 {
+		t.Fatalf("the analysis found a fill of %f", stats.Fill)
+	}
+	checkHistogram(t, stats)
+	if selectivity := stats.EqualSelectivity(); selectivity != 1.0/30 {
+		t.Fatalf("the selectivity of a key is %f, expected 1/30", selectivity)
+	}
+
+	// the rows of the first 15 keys are deleted
+	for i := 0; i < 300; i++ {
+		if i%30 < 15 {
+			removeEntry(t, "stats", []IndexKey{testKey(fmt.Sprintf("name%02d", i%30))}, testRID(i))
+		}
+	}
+	stats, err = GetIndexStats("stats", "stats_name")
+	if err != nil {
+		t.Fatalf("failed to read the stats: %s", err)
+	}
+	if stats.Keys != 150 || stats.DistinctKeys != 30 {
+		t.Fatalf("after the deletes the index has %d keys and %d distinct ones, expected 150 and the 30 of the last analysis",
+			stats.Keys, stats.DistinctKeys)
+	}
+
+	analyzeIndex(t, "stats", "stats_name")
+	stats, err = GetIndexStats("stats", "stats_name")
+	if err != nil {
+		t.Fatalf("failed to read the stats: %s", err)
+	}
+	if stats.Keys != 150 || stats.DistinctKeys != 15 {
+		t.Fatalf("the analysis after the deletes found %d keys, %d distinct, expected 150 and 15", stats.Keys, stats.DistinctKeys)
+	}
+	checkHistogram(t, stats)
+
+	// the deleted keys are before the range of the histogram
+	selectivity, err := stats.RangeSelectivity(ScanOptions{End: testKey("name10")})
+	if err != nil {
+		t.Fatalf("failed to estimate the selectivity: %s", err)
+	}
+	if selectivity > 0.1 {
+		t.Fatalf("the range of the deleted keys has a selectivity of %f", selectivity)
+	}
+	selectivity, err = stats.RangeSelectivity(ScanOptions{Start: testKey("name15"), End: testKey("name29")})
+	if err != nil {
+		t.Fatalf("failed to estimate the selectivity: %s", err)
+	}
+	if selectivity < 0.9 {
+		t.Fatalf("the range of all the keys has a selectivity of %f", selectivity)
+	}
+}
+
+// an index is rebuilt once it has too many updates or its tree is too empty
+func TestCheckIndexRebuild(t *testing.T) {
+	defer func(threshold RebuildThreshold) { IndexRebuildThreshold = threshold }(IndexRebuildThreshold)
+	IndexRebuildThreshold = RebuildThreshold{Updates: 20, MinFill: 0.4}
+
+	createTestIndex(t, "checkrebuild", "checkrebuild_updates", IndexOptions{Columns: []string{"name"}})
+	createTestIndex(t, "checkrebuild", "checkrebuild_hash", IndexOptions{Columns: []string{"name"}, Kind: HashIndex})
+	check := func(expected ...string) {
+		t.Helper()
+
+		names, err := CheckIndexRebuild("checkrebuild")
+		if err != nil {
+			t.Fatalf("failed to check the indexes: %s", err)
+		}
+		if !slices.Equal(names, expected) {
+			t.Fatalf("the indexes %v need a rebuild, expected %v", names, expected)
+		}
+	}
+
+	for i := 0; i < 30; i++ {
+		addEntry(t, "checkrebuild", []IndexKey{testKey(fmt.Sprint(i)), testKey(fmt.Sprint(i))}, testRID(i))
+	}
+	check()
+
+	// the updates of both kinds count, a removed entry is an update
+	for i := 0; i < 19; i++ {
+		removeEntry(t, "checkrebuild", []IndexKey{testKey(fmt.Sprint(i)), testKey(fmt.Sprint(i))}, testRID(i))
+	}
+	check()
+	removeEntry(t, "checkrebuild", []IndexKey{testKey("19"), testKey("19")}, testRID(19))
+	check("checkrebuild_updates", "checkrebuild_hash")
+
+	// a tree of many nodes filled less than MinFill needs a rebuild, a tree of one node never does
+	IndexRebuildThreshold = RebuildThreshold{Updates: 100000, MinFill: 0.6}
+	check()
+	if err := heapmanager.CreateHeap("sparse"); err != nil {
+		t.Fatalf("failed to create the heap: %s", err)
+	}
+	for i := 0; i < 1000; i++ {
+		if _, err := heapmanager.AddRowToHeap("sparse", []byte(fmt.Sprintf("%04d", i))); err != nil {
+			t.Fatalf("failed to add row %d: %s", i, err)
+		}
+	}
+
+	defer func(options BulkLoadOptions) { IndexBulkLoad = options }(IndexBulkLoad)
+	IndexBulkLoad.FillFactor = 0.5
+	if err := CreateIndexFromHeap("sparse", "sparse_name", IndexOptions{Columns: []string{"name"}}, "sparse", rowKey); err != nil {
+		t.Fatalf("failed to create the index: %s", err)
+	}
+	checkSparse := func(expected ...string) {
+		t.Helper()
+
+		names, err := CheckIndexRebuild("sparse")
+		if err != nil {
+			t.Fatalf("failed to check the index: %s", err)
+		}
+		if !slices.Equal(names, expected) {
+			stats := analyzeIndex(t, "sparse", "sparse_name")
+			t.Fatalf("the index with a fill of %f in %d nodes needs the rebuilds %v, expected %v",
+				stats.Fill, stats.LeafNodes+stats.InternalNodes, names, expected)
+		}
+	}
+	checkSparse("sparse_name")
+
+	IndexBulkLoad.FillFactor = 0.9
+	if err := RebuildIndex("sparse", "sparse_name", "sparse", rowKey); err != nil {
+		t.Fatalf("failed to rebuild the index: %s", err)
+	}
+	checkSparse()
+}
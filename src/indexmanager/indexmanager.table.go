@@ -0,0 +1,89 @@
+package indexmanager
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// DeleteTableIndexes deletes all the indexes of the table with their metadata, like DeleteIndex does for one index.
+// a table without indexes has nothing to delete.
+func DeleteTableIndexes(tableName string) error {
+	// the log may still have changes of the index files, they are applied before they are removed
+	// so recovery never replays them on the indexes of a new table with the same name
+	if err := logmanager.Checkpoint(); err != nil {
+		return err
+	}
+
+	indexDir := path.Join("indexes", tableName)
+	metaFilEMutex.Lock()
+	defer metaFilEMutex.Unlock()
+
+	if err := os.RemoveAll(indexDir); err != nil {
+		return fmt.Errorf("failed to delete the indexes of table %s: %w", tableName, err)
+	}
+	return nil
+}
+
+// RenameTableIndexes moves the indexes of the table oldName to the table newName,
+// the new table must not have indexes. a table without indexes has nothing to move.
+func RenameTableIndexes(oldName string, newName string) error {
+	oldDir, newDir := path.Join("indexes", oldName), path.Join("indexes", newName)
+	if _, err := os.Stat(oldDir); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(newDir); err == nil {
+		return fmt.Errorf("table %s already has indexes", newName)
+	}
+
+	// the log names the files it changes, its changes of the indexes are applied before they move
+	if err := logmanager.Checkpoint(); err != nil {
+		return err
+	}
+
+	metaFilEMutex.Lock()
+	err := os.Rename(oldDir, newDir)
+	metaFilEMutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to rename the indexes of table %s: %w", oldName, err)
+	}
+	if err := syncDir("indexes"); err != nil {
+		return err
+	}
+
+	// the metadata file keeps the name of its table, it is only informative and is written again with the new one
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(changeIndexesMetadata(tx, newName, func(indexes []IndexMetadata) ([]IndexMetadata, error) {
+		return indexes, nil
+	}))
+}
+
+// RenameIndexColumn renames the column in the metadata of all the indexes of the table that have it,
+// as a column of the index or as an included column. the entries of the indexes do not change.
+func RenameIndexColumn(tableName string, oldName string, newName string) error {
+	if _, err := os.Stat(path.Join("indexes", tableName, metaDataFileName)); os.IsNotExist(err) {
+		return nil
+	}
+
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(changeIndexesMetadata(tx, tableName, func(indexes []IndexMetadata) ([]IndexMetadata, error) {
+		for _, index := range indexes {
+			for _, columns := range [][]string{index.Columns, index.Include} {
+				for i, column := range columns {
+					if column == oldName {
+						columns[i] = newName
+					}
+				}
+			}
+		}
+		return indexes, nil
+	}))
+}
@@ -2,7 +2,7 @@ package indexmanager
 
 import (
 	"fmt"
-	"github.com/krasun/fbptree"
+	"github.com/SpaghettiDB/Storage-Engine/src/fbptree"
 	"path"
 )
 
@@ -0,0 +1,76 @@
+package indexmanager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+)
+
+// a rolled back transaction leaves neither the row in the heap nor its entry in the indexes
+func TestRollbackOfHeapAndIndexWrites(t *testing.T) {
+	if err := heapmanager.CreateHeap("txrows"); err != nil {
+		t.Fatalf("failed to create the heap: %s", err)
+	}
+	createTestIndex(t, "txrows", "txrows_id", IndexOptions{Columns: []string{"id"}, Unique: true})
+	createTestIndex(t, "txrows", "txrows_name", IndexOptions{Columns: []string{"name"}})
+
+	kept, err := heapmanager.AddRowToHeap("txrows", []byte("row 1"))
+	if err != nil {
+		t.Fatalf("failed to add a row: %s", err)
+	}
+	addEntry(t, "txrows", []IndexKey{testKey("1"), testKey("a")}, kept)
+
+	tx, err := logmanager.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %s", err)
+	}
+	added, err := heapmanager.AddRowToHeapTx(tx, "txrows", []byte("row 2"))
+	if err != nil {
+		t.Fatalf("failed to add a row: %s", err)
+	}
+	if err := AddEntryToTableIndexesTx(tx, "txrows", []IndexKey{testKey("2"), testKey("a")}, added); err != nil {
+		t.Fatalf("failed to add the entry of the row: %s", err)
+	}
+	if err := RemoveEntryFromTableIndexesTx(tx, "txrows", []IndexKey{testKey("1"), testKey("a")}, kept); err != nil {
+		t.Fatalf("failed to remove the entry of the row: %s", err)
+	}
+	if err := heapmanager.DeleteRowFromHeapTx(tx, "txrows", kept); err != nil {
+		t.Fatalf("failed to delete the row: %s", err)
+	}
+
+	// a failed statement takes back its own changes only, the transaction stays open
+	savepoint := tx.Savepoint()
+	err = AddEntryToTableIndexesTx(tx, "txrows", []IndexKey{testKey("3"), testKey("b")}, testRID(300))
+	if err != nil {
+		t.Fatalf("failed to add the entry of the statement: %s", err)
+	}
+	if err := tx.EndStatement(savepoint, errors.New("the statement failed")); err == nil {
+		t.Fatal("EndStatement must return the error of the statement")
+	}
+	checkLookup(t, "txrows", "txrows_id", testKey("3"))
+	checkLookup(t, "txrows", "txrows_id", testKey("2"), added)
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to roll back: %s", err)
+	}
+
+	if _, err := heapmanager.GetRowByRID("txrows", added); err == nil {
+		t.Fatal("the row added by the rolled back transaction is in the heap")
+	}
+	if row, err := heapmanager.GetRowByRID("txrows", kept); err != nil || string(row) != "row 1" {
+		t.Fatalf("the row deleted by the rolled back transaction is %q, %v", row, err)
+	}
+	checkLookup(t, "txrows", "txrows_id", testKey("2"))
+	checkLookup(t, "txrows", "txrows_id", testKey("1"), kept)
+	checkLookup(t, "txrows", "txrows_name", testKey("a"), kept)
+
+	metadata, err := getIndexMetadata("txrows", "txrows_id")
+	if err != nil {
+		t.Fatalf("failed to read the metadata: %s", err)
+	}
+	if metadata.Keys != 1 {
+		t.Fatalf("the index counts %d keys after the rollback, expected 1", metadata.Keys)
+	}
+}
@@ -0,0 +1,247 @@
+// this is keycodec package main file, it encodes typed values as keys that sort like the values
+// when they are compared byte by byte (memcomparable), so they can be used as index keys.
+//
+// every value starts with a tag, null sorts before the other values:
+// | Tag 1B (0x01 null, 0x02 value) | Value |
+//
+// the value:
+//   - int: 8 bytes big-endian with the sign bit flipped, so the negative ints come first
+//   - float: the 8 bytes of the IEEE 754 value big-endian, the sign bit is flipped for the positive values
+//     and all the bits are flipped for the negative ones
+//   - bool: 1 byte, 0 for false and 1 for true
+//   - timestamp: the seconds since the unix epoch as an int (8 bytes) then the nanoseconds (4 bytes)
+//   - string: the bytes with 0x00 escaped as 0x00 0xFF, followed by the terminator 0x00 0x01
+//
+// every value knows where it ends, so the values of a composite key can just be appended.
+// in descending order all the bytes of the value (tag included) are flipped.
+
+package keycodec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+// Order is the order of the encoded values of a column.
+type Order uint8
+
+const (
+	Ascending Order = iota
+	Descending
+)
+
+const (
+	tagNull  = 0x01
+	tagValue = 0x02
+)
+
+var errShortKey = errors.New("the key is too short")
+
+// Append appends the encoding of the value of the data type to dst, a nil value is null.
+func Append(dst []byte, dataType types.DataType, value any, order Order) ([]byte, error) {
+	value, err := types.Normalize(dataType, value)
+	if err != nil {
+		return nil, err
+	}
+
+	start := len(dst)
+	if value == nil {
+		dst = append(dst, tagNull)
+	} else {
+		dst = append(dst, tagValue)
+		switch dataType {
+		case types.Int:
+			dst = appendInt(dst, value.(int64))
+		case types.Float:
+			bits := math.Float64bits(value.(float64))
+			if bits&(1<<63) != 0 {
+				bits = ^bits
+			} else {
+				bits |= 1 << 63
+			}
+			dst = binary.BigEndian.AppendUint64(dst, bits)
+		case types.Bool:
+			if value.(bool) {
+				dst = append(dst, 1)
+			} else {
+				dst = append(dst, 0)
+			}
+		case types.Timestamp:
+			t := value.(time.Time)
+			dst = appendInt(dst, t.Unix())
+			dst = binary.BigEndian.AppendUint32(dst, uint32(t.Nanosecond()))
+		case types.String:
+			dst = AppendBytes(dst, []byte(value.(string)))
+		}
+	}
+
+	if order == Descending {
+		invert(dst[start:])
+	}
+	return dst, nil
+}
+
+// Encode returns the encoding of the value of the data type.
+func Encode(dataType types.DataType, value any, order Order) ([]byte, error) {
+	return Append(nil, dataType, value, order)
+}
+
+// IsNull reports whether the encoded value at the start of data is null.
+func IsNull(data []byte, order Order) bool {
+	if len(data) == 0 {
+		return false
+	}
+	tag := data[0]
+	if order == Descending {
+		tag = ^tag
+	}
+	return tag == tagNull
+}
+
+// Decode decodes the value of the data type at the start of data, it returns the value (nil for null)
+// and the bytes after it.
+func Decode(data []byte, dataType types.DataType, order Order) (any, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, errShortKey
+	}
+
+	r := reader{data: data}
+	if order == Descending {
+		r.mask = 0xFF
+	}
+
+	switch tag := r.byte(0); tag {
+	case tagNull:
+		return nil, data[1:], nil
+	case tagValue:
+	default:
+		return nil, nil, fmt.Errorf("invalid key tag %#x", tag)
+	}
+
+	var size int
+	switch dataType {
+	case types.Int:
+		size = 8
+	case types.Float:
+		size = 8
+	case types.Bool:
+		size = 1
+	case types.Timestamp:
+		size = 12
+	case types.String:
+		value, size, err := r.bytes(1)
+		if err != nil {
+			return nil, nil, err
+		}
+		return string(value), data[1+size:], nil
+	default:
+		return nil, nil, fmt.Errorf("unknown data type %q", dataType)
+	}
+
+	if len(data) < 1+size {
+		return nil, nil, errShortKey
+	}
+	value := r.slice(1, 1+size)
+	rest := data[1+size:]
+
+	switch dataType {
+	case types.Int:
+		return decodeInt(value), rest, nil
+	case types.Float:
+		bits := binary.BigEndian.Uint64(value)
+		if bits&(1<<63) != 0 {
+			bits &^= 1 << 63
+		} else {
+			bits = ^bits
+		}
+		return math.Float64frombits(bits), rest, nil
+	case types.Bool:
+		return value[0] != 0, rest, nil
+	default:
+		seconds := decodeInt(value[:8])
+		return time.Unix(seconds, int64(binary.BigEndian.Uint32(value[8:12]))).UTC(), rest, nil
+	}
+}
+
+// AppendBytes appends the bytes escaped and terminated, without a tag, in ascending order.
+// the encoded bytes sort like the bytes and know where they end.
+func AppendBytes(dst []byte, value []byte) []byte {
+	for _, b := range value {
+		if b == 0x00 {
+			dst = append(dst, 0x00, 0xFF)
+		} else {
+			dst = append(dst, b)
+		}
+	}
+	return append(dst, 0x00, 0x01)
+}
+
+// DecodeBytes decodes the bytes encoded by AppendBytes at the start of data and returns the bytes after them.
+func DecodeBytes(data []byte) ([]byte, []byte, error) {
+	r := reader{data: data}
+	value, size, err := r.bytes(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, data[size:], nil
+}
+
+func appendInt(dst []byte, v int64) []byte {
+	return binary.BigEndian.AppendUint64(dst, uint64(v)^(1<<63))
+}
+
+func decodeInt(data []byte) int64 {
+	return int64(binary.BigEndian.Uint64(data) ^ (1 << 63))
+}
+
+func invert(data []byte) {
+	for i := range data {
+		data[i] = ^data[i]
+	}
+}
+
+// reads the bytes of an encoded value, the mask flips them back for the descending order
+type reader struct {
+	data []byte
+	mask byte
+}
+
+func (r reader) byte(i int) byte {
+	return r.data[i] ^ r.mask
+}
+
+func (r reader) slice(start, end int) []byte {
+	value := make([]byte, end-start)
+	for i := range value {
+		value[i] = r.byte(start + i)
+	}
+	return value
+}
+
+// reads the escaped bytes that start at offset, it returns them and the size of the encoding up to the terminator
+func (r reader) bytes(offset int) ([]byte, int, error) {
+	value := make([]byte, 0)
+	for i := offset; i+1 < len(r.data); i++ {
+		b := r.byte(i)
+		if b != 0x00 {
+			value = append(value, b)
+			continue
+		}
+
+		i++
+		switch r.byte(i) {
+		case 0xFF:
+			value = append(value, 0x00)
+		case 0x01:
+			return value, i + 1 - offset, nil
+		default:
+			return nil, 0, fmt.Errorf("invalid escape in key at %d", i)
+		}
+	}
+	return nil, 0, errShortKey
+}
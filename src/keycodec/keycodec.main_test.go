@@ -0,0 +1,152 @@
+package keycodec
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+// the values of each data type in ascending order, null first
+var sortedValues = map[types.DataType][]any{
+	types.Int:   {nil, int64(math.MinInt64), int64(-1000), int64(-1), int64(0), int64(1), int64(255), int64(256), int64(math.MaxInt64)},
+	types.Float: {nil, math.Inf(-1), -1e300, -2.5, -1.0, -1e-300, 0.0, 1e-300, 0.5, 1.0, 3.25, 1e300, math.Inf(1)},
+	types.Bool:  {nil, false, true},
+	types.Timestamp: {
+		nil,
+		time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1970, 1, 1, 0, 0, 0, 1, time.UTC),
+		time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+	},
+	types.String: {nil, "", "\x00", "\x00\x00", "\x00a", "a", "a\x00", "a\x00b", "a\x01", "ab", "b", "\xff"},
+}
+
+func TestOrder(t *testing.T) {
+	for dataType, values := range sortedValues {
+		for _, order := range []Order{Ascending, Descending} {
+			var previous []byte
+			for i, value := range values {
+				encoded, err := Encode(dataType, value, order)
+				if err != nil {
+					t.Fatalf("failed to encode %s %v: %s", dataType, value, err)
+				}
+
+				if i > 0 {
+					cmp := bytes.Compare(previous, encoded)
+					if (order == Ascending && cmp >= 0) || (order == Descending && cmp <= 0) {
+						t.Fatalf("%s %v and %v are not in order %d: %x, %x", dataType, values[i-1], value, order, previous, encoded)
+					}
+				}
+				previous = encoded
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for dataType, values := range sortedValues {
+		for _, order := range []Order{Ascending, Descending} {
+			for _, value := range values {
+				encoded, err := Encode(dataType, value, order)
+				if err != nil {
+					t.Fatalf("failed to encode %s %v: %s", dataType, value, err)
+				}
+				if IsNull(encoded, order) != (value == nil) {
+					t.Fatalf("IsNull of %s %v in order %d is %v", dataType, value, order, !(value == nil))
+				}
+
+				// the bytes after the value are left for the next one
+				decoded, rest, err := Decode(append(encoded, 0xAB), dataType, order)
+				if err != nil {
+					t.Fatalf("failed to decode %s %v: %s", dataType, value, err)
+				}
+				if !bytes.Equal(rest, []byte{0xAB}) {
+					t.Fatalf("decoding %s %v left %x", dataType, value, rest)
+				}
+				if decoded != value {
+					t.Fatalf("decoded %v (%T), expected %v (%T)", decoded, decoded, value, value)
+				}
+			}
+		}
+	}
+}
+
+// the values of a composite key are appended, the keys sort column by column
+func TestCompositeOrder(t *testing.T) {
+	// the second column is in descending order, its larger ints come first and its nulls last
+	keys := [][]any{
+		{nil, int64(5)},
+		{"", int64(-1)},
+		{"", nil},
+		{"a", int64(10)},
+		{"a", int64(2)},
+		{"a\x00", int64(1)},
+		{"ab", int64(0)},
+		{"ab", nil},
+	}
+
+	var previous []byte
+	for i, key := range keys {
+		encoded, err := Encode(types.String, key[0], Ascending)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encoded, err = Append(encoded, types.Int, key[1], Descending); err != nil {
+			t.Fatal(err)
+		}
+
+		if i > 0 && bytes.Compare(previous, encoded) >= 0 {
+			t.Fatalf("keys %v and %v are not in order", keys[i-1], key)
+		}
+		previous = encoded
+
+		first, rest, err := Decode(encoded, types.String, Ascending)
+		if err != nil || first != key[0] {
+			t.Fatalf("decoded the first value of %v as %v, %v", key, first, err)
+		}
+		second, rest, err := Decode(rest, types.Int, Descending)
+		if err != nil || second != key[1] || len(rest) != 0 {
+			t.Fatalf("decoded the second value of %v as %v, %v with %x left", key, second, err, rest)
+		}
+	}
+}
+
+func TestBytes(t *testing.T) {
+	values := [][]byte{{}, {0x00}, {0x00, 0x00}, {0x00, 0x01}, {0x00, 0xFF}, {0x01}, {0xFF, 0x00}}
+
+	var previous []byte
+	for i, value := range values {
+		encoded := AppendBytes(nil, value)
+		if i > 0 && bytes.Compare(previous, encoded) >= 0 {
+			t.Fatalf("%x and %x are not in order", values[i-1], value)
+		}
+		previous = encoded
+
+		decoded, rest, err := DecodeBytes(append(encoded, 0x02))
+		if err != nil || !bytes.Equal(decoded, value) || !bytes.Equal(rest, []byte{0x02}) {
+			t.Fatalf("DecodeBytes(%x) = %x, %x, %v", encoded, decoded, rest, err)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, _, err := Decode(nil, types.Int, Ascending); err == nil {
+		t.Fatal("must return an error for an empty key")
+	}
+	if _, _, err := Decode([]byte{0x07}, types.Int, Ascending); err == nil {
+		t.Fatal("must return an error for an unknown tag")
+	}
+	if _, _, err := Decode([]byte{tagValue, 0x80, 0x00}, types.Int, Ascending); err == nil {
+		t.Fatal("must return an error for a short int")
+	}
+	if _, _, err := Decode([]byte{tagValue, 'a', 'b'}, types.String, Ascending); err == nil {
+		t.Fatal("must return an error for a string without its terminator")
+	}
+	if _, err := Encode(types.Int, "1", Ascending); err == nil {
+		t.Fatal("must return an error for a value of another type")
+	}
+}
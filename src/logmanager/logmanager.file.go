@@ -0,0 +1,246 @@
+package logmanager
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// writes to files that are not cached in the buffer pool (heap headers, index trees, index metadata)
+// are logged with the bytes before and after the write and the size of the file before and after it:
+// | PathSize 2B | Path | Offset 8B | SizeBefore 8B | SizeAfter 8B | DataSize 4B | Before | After |
+//
+// the file is written right after its record is synced to the log file: these files have no page lsn
+// and are not held back by the buffer pool, so a change that reaches the file before its record
+// could not be undone after a crash of the machine. redoing a write always applies it again,
+// which is safe since the writes are redone in order.
+//
+// a write made with OverwriteAt has no size (both sizes are -1): the file is also written outside
+// of the log, so redoing or undoing the write only writes its bytes and never truncates the file.
+
+// the size of a write that does not change the size of the file, see OverwriteAt
+const sizeNotLogged = -1
+
+type fileWrite struct {
+	path       string
+	offset     int64
+	sizeBefore int64
+	sizeAfter  int64
+	before     []byte
+	after      []byte
+}
+
+func (w *fileWrite) encode() []byte {
+	data := make([]byte, 0, 30+len(w.path)+2*len(w.after))
+	data = binary.BigEndian.AppendUint16(data, uint16(len(w.path)))
+	data = append(data, w.path...)
+	data = binary.BigEndian.AppendUint64(data, uint64(w.offset))
+	data = binary.BigEndian.AppendUint64(data, uint64(w.sizeBefore))
+	data = binary.BigEndian.AppendUint64(data, uint64(w.sizeAfter))
+	data = binary.BigEndian.AppendUint32(data, uint32(len(w.after)))
+	data = append(data, w.before...)
+	return append(data, w.after...)
+}
+
+func decodeFileWrite(data []byte) *fileWrite {
+	pathSize := int(binary.BigEndian.Uint16(data[0:2]))
+	data = data[2:]
+
+	w := &fileWrite{path: string(data[:pathSize])}
+	data = data[pathSize:]
+
+	w.offset = int64(binary.BigEndian.Uint64(data[0:8]))
+	w.sizeBefore = int64(binary.BigEndian.Uint64(data[8:16]))
+	w.sizeAfter = int64(binary.BigEndian.Uint64(data[16:24]))
+	dataSize := int(binary.BigEndian.Uint32(data[24:28]))
+	w.before = data[28 : 28+dataSize]
+	w.after = data[28+dataSize : 28+2*dataSize]
+	return w
+}
+
+type fileResourceManager struct{}
+
+func (fileResourceManager) Redo(lsn LSN, payload []byte) error {
+	w := decodeFileWrite(payload)
+
+	//the file was deleted later, its changes do not matter anymore
+	file, err := os.OpenFile(w.path, os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := applyFileWrite(file, w); err != nil {
+		return err
+	}
+	wal.addDirtyFile(w.path)
+	return nil
+}
+
+func (fileResourceManager) Compensate(payload []byte) []byte {
+	w := decodeFileWrite(payload)
+	w.before, w.after = w.after, w.before
+	w.sizeBefore, w.sizeAfter = w.sizeAfter, w.sizeBefore
+	return w.encode()
+}
+
+// writes the bytes after the change and sets the size of the file
+func applyFileWrite(file *os.File, w *fileWrite) error {
+	if _, err := file.WriteAt(w.after, w.offset); err != nil {
+		return fmt.Errorf("failed to write %s: %w", w.path, err)
+	}
+	if w.sizeAfter == sizeNotLogged {
+		return nil
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() != w.sizeAfter {
+		if err := file.Truncate(w.sizeAfter); err != nil {
+			return fmt.Errorf("failed to truncate %s: %w", w.path, err)
+		}
+	}
+	return nil
+}
+
+// WriteAt logs the write of data at offset of the file and then writes it.
+func (t *Transaction) WriteAt(file *os.File, data []byte, offset int64) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	w := &fileWrite{
+		offset:     offset,
+		sizeBefore: info.Size(),
+		sizeAfter:  max(info.Size(), offset+int64(len(data))),
+		after:      data,
+	}
+
+	//the part after the end of the file is undone by the truncate
+	w.before = make([]byte, len(data))
+	if _, err := file.ReadAt(w.before, offset); err != nil && err != io.EOF {
+		return err
+	}
+
+	return t.logFileWrite(file, w)
+}
+
+// OverwriteAt logs the write of data at offset of the file like WriteAt but not the size of the file,
+// so undoing or redoing the write never truncates it. it is for the files that are also extended
+// outside of the log, like a heap file whose pages the buffer pool writes when it evicts them.
+// the part of data after the end of the file is undone by writing zeros.
+func (t *Transaction) OverwriteAt(file *os.File, data []byte, offset int64) error {
+	w := &fileWrite{
+		offset:     offset,
+		sizeBefore: sizeNotLogged,
+		sizeAfter:  sizeNotLogged,
+		before:     make([]byte, len(data)),
+		after:      data,
+	}
+	if _, err := file.ReadAt(w.before, offset); err != nil && err != io.EOF {
+		return err
+	}
+
+	return t.logFileWrite(file, w)
+}
+
+// truncate logs the truncation of the file and then truncates it
+func (t *Transaction) truncate(file *os.File, size int64) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if size >= info.Size() {
+		return t.logFileWrite(file, &fileWrite{sizeBefore: info.Size(), sizeAfter: size})
+	}
+
+	w := &fileWrite{
+		offset:     size,
+		sizeBefore: info.Size(),
+		sizeAfter:  size,
+		before:     make([]byte, info.Size()-size),
+		after:      make([]byte, info.Size()-size),
+	}
+	if _, err := file.ReadAt(w.before, size); err != nil && err != io.EOF {
+		return err
+	}
+
+	return t.logFileWrite(file, w)
+}
+
+func (t *Transaction) logFileWrite(file *os.File, w *fileWrite) error {
+	path, err := filepath.Abs(file.Name())
+	if err != nil {
+		return err
+	}
+	w.path = path
+
+	if _, err := t.Log(ResourceManagerFile, w.encode()); err != nil {
+		return err
+	}
+
+	//the record is on the disk before the change reaches the file, so a crash
+	//never leaves a change the log cannot undo
+	if err := wal.flushAll(); err != nil {
+		return err
+	}
+
+	if err := applyFileWrite(file, w); err != nil {
+		return err
+	}
+	wal.addDirtyFile(path)
+	return nil
+}
+
+// File is a file whose writes are logged by a transaction, it is used for the index trees.
+// Sync does nothing, the writes are durable once the transaction commits
+// and the file is synced by the next checkpoint.
+type File struct {
+	file *os.File
+	tx   *Transaction
+}
+
+// OpenFile opens the file at path like os.OpenFile, the writes to the returned file are logged by t.
+func (t *Transaction) OpenFile(path string, flag int, perm os.FileMode) (*File, error) {
+	file, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &File{file: file, tx: t}, nil
+}
+
+func (f *File) ReadAt(data []byte, offset int64) (int, error) {
+	return f.file.ReadAt(data, offset)
+}
+
+func (f *File) WriteAt(data []byte, offset int64) (int, error) {
+	if err := f.tx.WriteAt(f.file, data, offset); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (f *File) Truncate(size int64) error {
+	return f.tx.truncate(f.file, size)
+}
+
+func (f *File) Stat() (fs.FileInfo, error) {
+	return f.file.Stat()
+}
+
+func (f *File) Sync() error {
+	return nil
+}
+
+func (f *File) Close() error {
+	return f.file.Close()
+}
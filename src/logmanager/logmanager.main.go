@@ -0,0 +1,399 @@
+// this is logmanager package main file, this module is responsible for the write-ahead log (wal).
+// every change to a heap page, a heap header, an index tree or an index metadata file is appended
+// to the log as a record that can redo and undo it before the change reaches the data file,
+// and a transaction is committed once its commit record is on the disk.
+// when the engine starts the log is replayed (see logmanager.recovery.go), so after a crash
+// the files come back with the changes of the committed transactions only.
+
+package logmanager
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/buffermanager"
+)
+
+// log file:
+// | Magic 4B | BaseLSN 8B | Records ... |
+//
+// record:
+// | Size 4B | Checksum 4B | LSN 8B | PrevLSN 8B | TxnID 8B | Type 1B | ResourceManager 1B | UndoNextLSN 8B | Payload |
+//
+// the lsn of a record is BaseLSN + its offset in the file, the base grows every time
+// the log is emptied by a checkpoint so the lsns never go back.
+const (
+	logFileName      = "wal.log"
+	logMagic         = 0x5357414C
+	logHeaderSize    = 12
+	recordHeaderSize = 42
+	// the commit that makes the log bigger than this also checkpoints it
+	checkpointThreshold = 32 << 20
+)
+
+// LSN (log sequence number) identifies a record of the log, a bigger lsn means a later record.
+type LSN uint64
+
+type recordType uint8
+
+const (
+	recordBegin recordType = iota + 1
+	recordCommit
+	recordAbort
+	recordEnd
+	// a change made by a transaction, it can be redone and undone
+	recordUpdate
+	// a compensation log record (clr), the change that undid an update, it is only redone
+	recordCompensation
+)
+
+type logRecord struct {
+	lsn     LSN
+	prevLSN LSN
+	txnID   uint64
+	kind    recordType
+	rm      ResourceManagerID
+	// for a clr, the next record of the transaction to undo
+	undoNextLSN LSN
+	payload     []byte
+}
+
+type writeAheadLog struct {
+	mutex   sync.Mutex
+	file    *os.File
+	baseLSN LSN
+	// the end of the records that are written and synced to the file
+	flushedLSN LSN
+	// the end of the records that are written to the file, the ones after flushedLSN are not synced yet
+	writtenLSN LSN
+	// the records appended after writtenLSN
+	buffer    []byte
+	nextTxnID uint64
+	// the data files written since the last checkpoint, they are synced by the next one
+	dirtyFiles map[string]struct{}
+}
+
+var (
+	wal     *writeAheadLog
+	walOnce sync.Once
+	walErr  error
+)
+
+// opens the log the first time it is needed and recovers the files from it
+func openLog() error {
+	walOnce.Do(func() {
+		walErr = recoverLog()
+	})
+	return walErr
+}
+
+// opens the log file or creates an empty one
+func openLogFile() (*writeAheadLog, error) {
+	file, err := os.OpenFile(logFileName, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the log: %w", err)
+	}
+
+	header := make([]byte, logHeaderSize)
+	if _, err := file.ReadAt(header, 0); err != nil && err != io.EOF {
+		file.Close()
+		return nil, fmt.Errorf("failed to read the log header: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if info.Size() < logHeaderSize {
+		binary.BigEndian.PutUint32(header[0:4], logMagic)
+		binary.BigEndian.PutUint64(header[4:12], 0)
+		if _, err := file.WriteAt(header, 0); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write the log header: %w", err)
+		}
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	} else if binary.BigEndian.Uint32(header[0:4]) != logMagic {
+		file.Close()
+		return nil, fmt.Errorf("%s is not a log file", logFileName)
+	}
+
+	baseLSN := LSN(binary.BigEndian.Uint64(header[4:12]))
+	return &writeAheadLog{
+		file:       file,
+		baseLSN:    baseLSN,
+		flushedLSN: baseLSN + logHeaderSize,
+		writtenLSN: baseLSN + logHeaderSize,
+		nextTxnID:  1,
+		dirtyFiles: make(map[string]struct{}),
+	}, nil
+}
+
+// appends the record to the log buffer and returns its lsn
+func (l *writeAheadLog) append(record *logRecord) LSN {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	record.lsn = l.writtenLSN + LSN(len(l.buffer))
+	l.buffer = append(l.buffer, encodeRecord(record)...)
+	return record.lsn
+}
+
+// writes the buffered records to the log file without syncing it
+func (l *writeAheadLog) write() error {
+	if len(l.buffer) == 0 {
+		return nil
+	}
+
+	if _, err := l.file.WriteAt(l.buffer, int64(l.writtenLSN-l.baseLSN)); err != nil {
+		return fmt.Errorf("failed to write the log: %w", err)
+	}
+
+	l.writtenLSN += LSN(len(l.buffer))
+	l.buffer = l.buffer[:0]
+	return nil
+}
+
+// writes the buffered records to the log file and syncs it
+func (l *writeAheadLog) flush() error {
+	if err := l.write(); err != nil {
+		return err
+	}
+	if l.flushedLSN == l.writtenLSN {
+		return nil
+	}
+
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync the log: %w", err)
+	}
+	l.flushedLSN = l.writtenLSN
+	return nil
+}
+
+// writes all the buffered records to the log file
+func (l *writeAheadLog) flushAll() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.flush()
+}
+
+// FlushTo makes sure the record with the given lsn and all the records before it are on the disk.
+// a data page must not be written to its file before the records of its changes are flushed.
+func FlushTo(lsn LSN) error {
+	if wal == nil {
+		return nil
+	}
+
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	if lsn < wal.flushedLSN {
+		return nil
+	}
+	return wal.flush()
+}
+
+// reads the record with the given lsn, it is written first if it is still in the buffer
+func (l *writeAheadLog) read(lsn LSN) (*logRecord, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if lsn >= l.writtenLSN {
+		if err := l.write(); err != nil {
+			return nil, err
+		}
+	}
+
+	record, err := readRecord(l.file, int64(lsn-l.baseLSN), l.baseLSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log record %d: %w", lsn, err)
+	}
+	return record, nil
+}
+
+// reads all the records of the log file in order.
+// the scan stops at the first record that is incomplete or corrupted, it was being written
+// during the crash, and the file is truncated there.
+func (l *writeAheadLog) readAll() ([]*logRecord, error) {
+	records := make([]*logRecord, 0)
+
+	offset := int64(logHeaderSize)
+	for {
+		record, err := readRecord(l.file, offset, l.baseLSN)
+		if err != nil {
+			break
+		}
+		records = append(records, record)
+		offset += int64(recordHeaderSize + len(record.payload))
+	}
+
+	if err := l.file.Truncate(offset); err != nil {
+		return nil, err
+	}
+	l.flushedLSN = l.baseLSN + LSN(offset)
+	l.writtenLSN = l.flushedLSN
+	return records, nil
+}
+
+// marks the data file as written, so the next checkpoint syncs it
+func (l *writeAheadLog) addDirtyFile(path string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.dirtyFiles[path] = struct{}{}
+}
+
+// Checkpoint writes all the changes in the buffer pool and the data files to the disk and empties the log.
+// it waits for the running transaction to end.
+func Checkpoint() error {
+	if err := openLog(); err != nil {
+		return err
+	}
+
+	txnMutex.Lock()
+	defer txnMutex.Unlock()
+
+	return checkpoint()
+}
+
+// checkpoint must be called with no transaction running
+func checkpoint() error {
+	if err := wal.flushAll(); err != nil {
+		return err
+	}
+
+	if err := buffermanager.Default().FlushAll(); err != nil {
+		return fmt.Errorf("failed to flush the buffer pool: %w", err)
+	}
+
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	for path := range wal.dirtyFiles {
+		if err := syncFile(path); err != nil {
+			return err
+		}
+	}
+	clear(wal.dirtyFiles)
+
+	//the empty log is written next to the old one and renamed over it,
+	//so a crash leaves either the old log or the new one
+	baseLSN := wal.writtenLSN
+	header := make([]byte, logHeaderSize)
+	binary.BigEndian.PutUint32(header[0:4], logMagic)
+	binary.BigEndian.PutUint64(header[4:12], uint64(baseLSN))
+
+	tempPath := logFileName + ".tmp"
+	if err := os.WriteFile(tempPath, header, 0644); err != nil {
+		return fmt.Errorf("failed to write the new log: %w", err)
+	}
+	if err := syncFile(tempPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, logFileName); err != nil {
+		return fmt.Errorf("failed to replace the log: %w", err)
+	}
+	if err := syncFile(filepath.Dir(logFileName)); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(logFileName, os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the log: %w", err)
+	}
+	wal.file.Close()
+	wal.file = file
+	wal.baseLSN = baseLSN
+	wal.flushedLSN = baseLSN + logHeaderSize
+	wal.writtenLSN = wal.flushedLSN
+	return nil
+}
+
+// returns the size of the log file including the buffered records
+func logSize() int64 {
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+
+	return int64(wal.writtenLSN-wal.baseLSN) + int64(len(wal.buffer))
+}
+
+// syncs a file that may have been deleted after it was written
+func syncFile(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", path, err)
+	}
+	return nil
+}
+
+func encodeRecord(record *logRecord) []byte {
+	data := make([]byte, recordHeaderSize+len(record.payload))
+	binary.BigEndian.PutUint32(data[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint64(data[8:16], uint64(record.lsn))
+	binary.BigEndian.PutUint64(data[16:24], uint64(record.prevLSN))
+	binary.BigEndian.PutUint64(data[24:32], record.txnID)
+	data[32] = byte(record.kind)
+	data[33] = byte(record.rm)
+	binary.BigEndian.PutUint64(data[34:42], uint64(record.undoNextLSN))
+	copy(data[recordHeaderSize:], record.payload)
+
+	binary.BigEndian.PutUint32(data[4:8], crc32.ChecksumIEEE(data[8:]))
+	return data
+}
+
+// reads the record at offset of the log file, baseLSN is used to check that
+// the record really starts there.
+func readRecord(file *os.File, offset int64, baseLSN LSN) (*logRecord, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := file.ReadAt(header, offset); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size < recordHeaderSize {
+		return nil, errors.New("invalid record size")
+	}
+
+	data := make([]byte, size)
+	if _, err := file.ReadAt(data, offset); err != nil {
+		return nil, err
+	}
+
+	if crc32.ChecksumIEEE(data[8:]) != binary.BigEndian.Uint32(data[4:8]) {
+		return nil, errors.New("record checksum mismatch")
+	}
+
+	record := &logRecord{
+		lsn:         LSN(binary.BigEndian.Uint64(data[8:16])),
+		prevLSN:     LSN(binary.BigEndian.Uint64(data[16:24])),
+		txnID:       binary.BigEndian.Uint64(data[24:32]),
+		kind:        recordType(data[32]),
+		rm:          ResourceManagerID(data[33]),
+		undoNextLSN: LSN(binary.BigEndian.Uint64(data[34:42])),
+		payload:     data[recordHeaderSize:],
+	}
+
+	if record.lsn != baseLSN+LSN(offset) {
+		return nil, errors.New("record lsn does not match its offset")
+	}
+	return record, nil
+}
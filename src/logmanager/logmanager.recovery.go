@@ -0,0 +1,164 @@
+package logmanager
+
+import (
+	"fmt"
+)
+
+// ResourceManagerID tells which resource manager applies the payload of an update record.
+type ResourceManagerID uint8
+
+const (
+	// writes to plain files, see Transaction.WriteAt
+	ResourceManagerFile ResourceManagerID = iota + 1
+	// changes to heap pages in the buffer pool, registered by heapmanager
+	ResourceManagerHeap
+)
+
+// ResourceManager applies the changes logged by one part of the storage engine.
+type ResourceManager interface {
+	// Redo applies the change described by the payload of the record with the given lsn.
+	// it is called again for changes that already reached the disk, so it must be idempotent.
+	Redo(lsn LSN, payload []byte) error
+	// Compensate returns the payload of the change that undoes the given one.
+	Compensate(payload []byte) []byte
+}
+
+var resourceManagers = map[ResourceManagerID]ResourceManager{
+	ResourceManagerFile: fileResourceManager{},
+}
+
+// RegisterResourceManager sets the resource manager for the records with the given id,
+// it must be called from an init function so recovery can find it.
+func RegisterResourceManager(id ResourceManagerID, rm ResourceManager) {
+	resourceManagers[id] = rm
+}
+
+func resourceManager(id ResourceManagerID) (ResourceManager, error) {
+	rm, ok := resourceManagers[id]
+	if !ok {
+		return nil, fmt.Errorf("no resource manager registered for id %d", id)
+	}
+	return rm, nil
+}
+
+// Recover replays the log, it should be called when the engine starts.
+// the first transaction recovers the log anyway if it was not called.
+func Recover() error {
+	return openLog()
+}
+
+// recovery follows ARIES:
+//   - analysis: the log is scanned to find the transactions that did not end
+//   - redo: every change in the log is applied again (repeating history), including the
+//     changes of the transactions that did not commit and the undos already done
+//   - undo: the changes of the transactions that did not commit are undone from their last one,
+//     each undo is logged as a clr so a crash during recovery does not undo a change twice
+//
+// the files are then flushed and the log is emptied by a checkpoint.
+func recoverLog() error {
+	l, err := openLogFile()
+	if err != nil {
+		return err
+	}
+	wal = l
+
+	records, err := wal.readAll()
+	if err != nil {
+		return fmt.Errorf("failed to read the log: %w", err)
+	}
+
+	//analysis
+	lastLSNs := make(map[uint64]LSN)
+	committed := make(map[uint64]bool)
+	for _, record := range records {
+		wal.nextTxnID = max(wal.nextTxnID, record.txnID+1)
+
+		switch record.kind {
+		case recordEnd:
+			delete(lastLSNs, record.txnID)
+			delete(committed, record.txnID)
+		case recordCommit:
+			committed[record.txnID] = true
+			lastLSNs[record.txnID] = record.lsn
+		default:
+			lastLSNs[record.txnID] = record.lsn
+		}
+	}
+
+	//redo
+	for _, record := range records {
+		if record.kind != recordUpdate && record.kind != recordCompensation {
+			continue
+		}
+
+		rm, err := resourceManager(record.rm)
+		if err != nil {
+			return err
+		}
+		if err := rm.Redo(record.lsn, record.payload); err != nil {
+			return fmt.Errorf("failed to redo log record %d: %w", record.lsn, err)
+		}
+	}
+
+	//the committed transactions only miss their end record
+	for txnID := range committed {
+		wal.append(&logRecord{prevLSN: lastLSNs[txnID], txnID: txnID, kind: recordEnd})
+		delete(lastLSNs, txnID)
+	}
+
+	//undo, the transactions run one at a time so their changes never interleave
+	//and the losers can be undone one after the other
+	for txnID, lastLSN := range lastLSNs {
+		lastLSN, err := undo(txnID, lastLSN, 0)
+		if err != nil {
+			return err
+		}
+		wal.append(&logRecord{prevLSN: lastLSN, txnID: txnID, kind: recordEnd})
+	}
+	if err := wal.flushAll(); err != nil {
+		return err
+	}
+
+	return checkpoint()
+}
+
+// undoes the changes of the transaction made after the record savepoint, going back from lastLSN.
+// every undone change is logged as a clr, it returns the lsn of the last record of the transaction.
+func undo(txnID uint64, lastLSN LSN, savepoint LSN) (LSN, error) {
+	for lsn := lastLSN; lsn > savepoint; {
+		record, err := wal.read(lsn)
+		if err != nil {
+			return 0, err
+		}
+
+		switch record.kind {
+		case recordUpdate:
+			rm, err := resourceManager(record.rm)
+			if err != nil {
+				return 0, err
+			}
+
+			payload := rm.Compensate(record.payload)
+			lastLSN = wal.append(&logRecord{
+				prevLSN:     lastLSN,
+				txnID:       txnID,
+				kind:        recordCompensation,
+				rm:          record.rm,
+				undoNextLSN: record.prevLSN,
+				payload:     payload,
+			})
+
+			if err := rm.Redo(lastLSN, payload); err != nil {
+				return 0, fmt.Errorf("failed to undo log record %d: %w", record.lsn, err)
+			}
+			lsn = record.prevLSN
+		case recordCompensation:
+			//the records before the clr up to its undo next record are already undone
+			lsn = record.undoNextLSN
+		default:
+			lsn = record.prevLSN
+		}
+	}
+
+	return lastLSN, nil
+}
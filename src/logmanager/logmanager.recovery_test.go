@@ -0,0 +1,348 @@
+package logmanager
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"testing"
+)
+
+// the log and the files of the tests are created in the working directory, the tests run in a temporary one
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "logmanager")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+
+	RegisterResourceManager(testResourceManager, slotResourceManager{})
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// simulates a crash of the process: the records that are not written to the log file are lost,
+// the running transaction is gone and the next transaction recovers the log like a new process would
+func crash(t *testing.T) {
+	t.Helper()
+
+	if wal != nil {
+		wal.file.Close()
+	}
+	wal = nil
+	walOnce = sync.Once{}
+	clear(slots)
+
+	// unlocks the mutex of the transaction that was running, if there was one
+	txnMutex.TryLock()
+	txnMutex.Unlock()
+}
+
+// simulates a crash of the machine: the records written to the log file after its last sync are lost too,
+// while the writes to the data files may have reached the disk
+func crashLosingUnsyncedLog(t *testing.T) {
+	t.Helper()
+
+	wal.mutex.Lock()
+	err := wal.write()
+	synced := int64(wal.flushedLSN - wal.baseLSN)
+	wal.mutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.file.Truncate(synced); err != nil {
+		t.Fatal(err)
+	}
+	crash(t)
+}
+
+// creates the file with the content
+func createFile(t *testing.T, name string, content string) *os.File {
+	t.Helper()
+
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to create %s: %s", name, err)
+	}
+	file, err := os.OpenFile(name, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("failed to open %s: %s", name, err)
+	}
+	t.Cleanup(func() { file.Close() })
+	return file
+}
+
+func checkFile(t *testing.T, name string, expected string) {
+	t.Helper()
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("failed to read %s: %s", name, err)
+	}
+	if !bytes.Equal(content, []byte(expected)) {
+		t.Fatalf("%s is %q, expected %q", name, content, expected)
+	}
+}
+
+func begin(t *testing.T) *Transaction {
+	t.Helper()
+
+	tx, err := Begin()
+	if err != nil {
+		t.Fatalf("failed to begin: %s", err)
+	}
+	return tx
+}
+
+func writeAt(t *testing.T, tx *Transaction, file *os.File, data string, offset int64) {
+	t.Helper()
+
+	if err := tx.WriteAt(file, []byte(data), offset); err != nil {
+		t.Fatalf("failed to write %q at %d: %s", data, offset, err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	file := createFile(t, "rollback", "0123456789")
+
+	tx := begin(t)
+	writeAt(t, tx, file, "abc", 2)
+	writeAt(t, tx, file, "xyz", 8)
+	if err := tx.truncate(file, 4); err != nil {
+		t.Fatalf("failed to truncate: %s", err)
+	}
+	checkFile(t, "rollback", "01ab")
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to roll back: %s", err)
+	}
+	checkFile(t, "rollback", "0123456789")
+}
+
+func TestRollbackTo(t *testing.T) {
+	file := createFile(t, "savepoint", "0123456789")
+
+	tx := begin(t)
+	writeAt(t, tx, file, "ab", 0)
+	savepoint := tx.Savepoint()
+	writeAt(t, tx, file, "cd", 4)
+	writeAt(t, tx, file, "efgh", 9)
+
+	err := tx.EndStatement(savepoint, os.ErrInvalid)
+	if err != os.ErrInvalid {
+		t.Fatalf("EndStatement returned %v, expected the error of the statement", err)
+	}
+	checkFile(t, "savepoint", "ab23456789")
+
+	writeAt(t, tx, file, "z", 9)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %s", err)
+	}
+	checkFile(t, "savepoint", "ab2345678z")
+}
+
+func TestRecoveryRedoesCommittedWrites(t *testing.T) {
+	file := createFile(t, "redo", "0123")
+
+	tx := begin(t)
+	writeAt(t, tx, file, "abcdef", 2)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %s", err)
+	}
+
+	// the write did not reach the disk before the crash
+	if err := os.WriteFile("redo", []byte("0123"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	crash(t)
+
+	if err := Recover(); err != nil {
+		t.Fatalf("failed to recover: %s", err)
+	}
+	checkFile(t, "redo", "01abcdef")
+}
+
+func TestRecoveryUndoesUncommittedWrites(t *testing.T) {
+	file := createFile(t, "undo", "0123")
+
+	tx := begin(t)
+	writeAt(t, tx, file, "ab", 0)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %s", err)
+	}
+
+	// the writes of a transaction that did not commit reach the file before the crash
+	tx = begin(t)
+	writeAt(t, tx, file, "xyz", 2)
+	writeAt(t, tx, file, "!", 0)
+	crash(t)
+
+	if err := Recover(); err != nil {
+		t.Fatalf("failed to recover: %s", err)
+	}
+	checkFile(t, "undo", "ab23")
+}
+
+// a crash during a rollback leaves clrs in the log, recovery undoes the rest of the transaction without undoing a change twice
+func TestRecoveryAfterPartialRollback(t *testing.T) {
+	file := createFile(t, "clr", "0000")
+
+	tx := begin(t)
+	writeAt(t, tx, file, "1", 0)
+	savepoint := tx.Savepoint()
+	writeAt(t, tx, file, "2", 1)
+	writeAt(t, tx, file, "3", 1)
+	if err := tx.RollbackTo(savepoint); err != nil {
+		t.Fatalf("failed to roll back to the savepoint: %s", err)
+	}
+	writeAt(t, tx, file, "4", 2)
+	if err := wal.flushAll(); err != nil {
+		t.Fatal(err)
+	}
+	checkFile(t, "clr", "1040")
+	crash(t)
+
+	if err := Recover(); err != nil {
+		t.Fatalf("failed to recover: %s", err)
+	}
+	checkFile(t, "clr", "0000")
+}
+
+// a write made with OverwriteAt is undone without truncating the file, which grew outside of the log
+func TestOverwriteAtKeepsTheSize(t *testing.T) {
+	file := createFile(t, "overwrite", "0123")
+
+	tx := begin(t)
+	if err := tx.OverwriteAt(file, []byte("abcdef"), 2); err != nil {
+		t.Fatalf("failed to overwrite: %s", err)
+	}
+	if _, err := file.WriteAt([]byte("XY"), 8); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to roll back: %s", err)
+	}
+
+	checkFile(t, "overwrite", "0123\x00\x00\x00\x00XY")
+}
+
+// a write to a file is on the disk only after its record is, so the records lost with the unsynced end
+// of the log never leave a change in a file that recovery cannot undo
+func TestRecoveryAfterLosingUnsyncedLog(t *testing.T) {
+	file := createFile(t, "unsynced", "0123")
+
+	tx := begin(t)
+	writeAt(t, tx, file, "ab", 0)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %s", err)
+	}
+
+	tx = begin(t)
+	writeAt(t, tx, file, "xyz", 2)
+	setSlot(t, tx, 1, 10)
+	crashLosingUnsyncedLog(t)
+
+	if err := Recover(); err != nil {
+		t.Fatalf("failed to recover: %s", err)
+	}
+	checkFile(t, "unsynced", "ab23")
+	clear(slots)
+}
+
+// the log is emptied by a checkpoint, recovery does not redo the changes made before it
+func TestCheckpoint(t *testing.T) {
+	file := createFile(t, "checkpoint", "0123")
+
+	tx := begin(t)
+	writeAt(t, tx, file, "ab", 0)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %s", err)
+	}
+	if err := Checkpoint(); err != nil {
+		t.Fatalf("failed to checkpoint: %s", err)
+	}
+
+	// a change made after the checkpoint outside of the log
+	if err := os.WriteFile("checkpoint", []byte("wxyz"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	crash(t)
+
+	if err := Recover(); err != nil {
+		t.Fatalf("failed to recover: %s", err)
+	}
+	checkFile(t, "checkpoint", "wxyz")
+}
+
+// the resource manager of the tests keeps bytes in memory, a record sets a slot:
+// | Slot 1B | Before 1B | After 1B |
+const testResourceManager ResourceManagerID = 200
+
+var slots = make(map[byte]byte)
+
+type slotResourceManager struct{}
+
+func (slotResourceManager) Redo(lsn LSN, payload []byte) error {
+	slots[payload[0]] = payload[2]
+	return nil
+}
+
+func (slotResourceManager) Compensate(payload []byte) []byte {
+	return []byte{payload[0], payload[2], payload[1]}
+}
+
+func setSlot(t *testing.T, tx *Transaction, slot byte, value byte) {
+	t.Helper()
+
+	if _, err := tx.Log(testResourceManager, []byte{slot, slots[slot], value}); err != nil {
+		t.Fatalf("failed to log: %s", err)
+	}
+	slots[slot] = value
+}
+
+// the memory of the resource manager is lost by the crash, recovery repeats the history of the log
+// and undoes the changes of the transaction that did not commit
+func TestRecoveryRepeatsHistory(t *testing.T) {
+	tx := begin(t)
+	setSlot(t, tx, 1, 10)
+	setSlot(t, tx, 2, 20)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit: %s", err)
+	}
+
+	tx = begin(t)
+	setSlot(t, tx, 1, 11)
+	setSlot(t, tx, 3, 30)
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("failed to roll back: %s", err)
+	}
+
+	tx = begin(t)
+	setSlot(t, tx, 2, 21)
+	setSlot(t, tx, 4, 40)
+	if err := wal.flushAll(); err != nil {
+		t.Fatal(err)
+	}
+	crash(t)
+
+	if err := Recover(); err != nil {
+		t.Fatalf("failed to recover: %s", err)
+	}
+	expected := map[byte]byte{1: 10, 2: 20, 3: 0, 4: 0}
+	for slot, value := range expected {
+		if slots[slot] != value {
+			t.Fatalf("slot %d is %d after the recovery, expected %d (slots %v)", slot, slots[slot], value, slots)
+		}
+	}
+
+	// the log is empty after the recovery, the next crash has nothing to redo
+	crash(t)
+	if err := Recover(); err != nil {
+		t.Fatalf("failed to recover again: %s", err)
+	}
+	if len(slots) != 0 {
+		t.Fatalf("the second recovery redid %v", slots)
+	}
+}
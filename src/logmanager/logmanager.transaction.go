@@ -0,0 +1,207 @@
+package logmanager
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// only one transaction runs at a time, it holds the mutex from Begin to Commit or Rollback.
+// this is what makes undoing a change by restoring the old bytes safe,
+// no other transaction can have changed the same bytes in the meantime.
+var txnMutex sync.Mutex
+
+// Transaction groups changes that are applied all together or not at all.
+// the changes are logged with Log (or WriteAt for plain files) and become durable with Commit,
+// Rollback undoes all of them.
+//
+//	tx, err := logmanager.Begin()
+//	if err != nil {
+//		return err
+//	}
+//	rid, err := heapmanager.AddRowToHeapTx(tx, "student", row)
+//	if err != nil {
+//		tx.Rollback()
+//		return err
+//	}
+//	if err := indexmanager.AddEntryToTableIndexesTx(tx, "student", keys, rid); err != nil {
+//		tx.Rollback()
+//		return err
+//	}
+//	return tx.Commit()
+//
+// only one transaction runs at a time, the functions that start their own transaction
+// (AddRowToHeap, AddEntryToTableIndexes, ...) must not be called while one is open
+// by the same goroutine, they would wait for it forever.
+// the reads do not wait for the running transaction, they see its changes before it commits.
+type Transaction struct {
+	id uint64
+	// the last record written by the transaction, the records of a transaction are chained by PrevLSN
+	lastLSN LSN
+	done    bool
+	// the functions called by Commit once the transaction is durable
+	onCommit []func() error
+}
+
+// Begin starts a new transaction, it waits for the running transaction to end.
+// the log is recovered by the first call.
+func Begin() (*Transaction, error) {
+	if err := openLog(); err != nil {
+		return nil, err
+	}
+
+	txnMutex.Lock()
+	return newTransaction(), nil
+}
+
+// BeginAfterCheckpoint checkpoints the log and starts a new transaction, no other transaction runs
+// in between. the log has no change made before the transaction, so a file the transaction replaces
+// as a whole (see OnCommit) gets no old change when the log is replayed.
+func BeginAfterCheckpoint() (*Transaction, error) {
+	if err := openLog(); err != nil {
+		return nil, err
+	}
+
+	txnMutex.Lock()
+	if err := checkpoint(); err != nil {
+		txnMutex.Unlock()
+		return nil, err
+	}
+	return newTransaction(), nil
+}
+
+// starts the transaction, it must be called with txnMutex locked
+func newTransaction() *Transaction {
+	wal.mutex.Lock()
+	id := wal.nextTxnID
+	wal.nextTxnID++
+	wal.mutex.Unlock()
+
+	t := &Transaction{id: id}
+	t.lastLSN = wal.append(&logRecord{txnID: id, kind: recordBegin})
+	return t
+}
+
+// Log appends an update record with the payload for the resource manager rm.
+// the change must be applied after it is logged, it returns the lsn of the record.
+func (t *Transaction) Log(rm ResourceManagerID, payload []byte) (LSN, error) {
+	if t.done {
+		return 0, errors.New("the transaction has ended")
+	}
+
+	t.lastLSN = wal.append(&logRecord{
+		prevLSN: t.lastLSN,
+		txnID:   t.id,
+		kind:    recordUpdate,
+		rm:      rm,
+		payload: payload,
+	})
+	return t.lastLSN, nil
+}
+
+// Commit makes the changes of the transaction durable and ends it.
+func (t *Transaction) Commit() error {
+	if t.done {
+		return errors.New("the transaction has ended")
+	}
+	defer t.end()
+
+	t.lastLSN = wal.append(&logRecord{prevLSN: t.lastLSN, txnID: t.id, kind: recordCommit})
+	if err := wal.flushAll(); err != nil {
+		return err
+	}
+	wal.append(&logRecord{prevLSN: t.lastLSN, txnID: t.id, kind: recordEnd})
+
+	// the transaction is committed even if a function fails, the error is returned anyway
+	var errs []error
+	for _, fn := range t.onCommit {
+		if err := fn(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if logSize() > checkpointThreshold {
+		errs = append(errs, checkpoint())
+	}
+	return errors.Join(errs...)
+}
+
+// OnCommit adds a function that Commit calls once the transaction is durable, before the next transaction
+// can start. the functions are called in the order they were added and are dropped by Rollback.
+// they must not start a transaction or checkpoint the log, they would wait for the running one forever.
+func (t *Transaction) OnCommit(fn func() error) {
+	t.onCommit = append(t.onCommit, fn)
+}
+
+// Rollback undoes all the changes of the transaction and ends it.
+func (t *Transaction) Rollback() error {
+	if t.done {
+		return errors.New("the transaction has ended")
+	}
+	defer t.end()
+
+	t.lastLSN = wal.append(&logRecord{prevLSN: t.lastLSN, txnID: t.id, kind: recordAbort})
+	lastLSN, err := undo(t.id, t.lastLSN, 0)
+	if err != nil {
+		return fmt.Errorf("failed to roll back transaction %d: %w", t.id, err)
+	}
+
+	t.lastLSN = wal.append(&logRecord{prevLSN: lastLSN, txnID: t.id, kind: recordEnd})
+	return wal.flushAll()
+}
+
+// Savepoint returns the current point of the transaction, RollbackTo undoes the changes made after it.
+func (t *Transaction) Savepoint() LSN {
+	return t.lastLSN
+}
+
+// RollbackTo undoes the changes made after the savepoint, the transaction stays open.
+func (t *Transaction) RollbackTo(savepoint LSN) error {
+	if t.done {
+		return errors.New("the transaction has ended")
+	}
+
+	lastLSN, err := undo(t.id, t.lastLSN, savepoint)
+	if err != nil {
+		return fmt.Errorf("failed to roll back transaction %d to %d: %w", t.id, savepoint, err)
+	}
+	t.lastLSN = lastLSN
+	return nil
+}
+
+// Finish commits the transaction if err is nil, otherwise it rolls it back and returns err.
+//
+//	tx, err := logmanager.Begin()
+//	if err != nil {
+//		return err
+//	}
+//	return tx.Finish(doChanges(tx))
+func (t *Transaction) Finish(err error) error {
+	if err == nil {
+		return t.Commit()
+	}
+
+	if rollbackErr := t.Rollback(); rollbackErr != nil {
+		return errors.Join(err, rollbackErr)
+	}
+	return err
+}
+
+// EndStatement keeps the changes made since the savepoint if err is nil, otherwise it undoes them
+// and returns err. the transaction stays open either way, so one failed statement does not
+// leave half of its changes in the transaction.
+func (t *Transaction) EndStatement(savepoint LSN, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if rollbackErr := t.RollbackTo(savepoint); rollbackErr != nil {
+		return errors.Join(err, rollbackErr)
+	}
+	return err
+}
+
+func (t *Transaction) end() {
+	t.done = true
+	txnMutex.Unlock()
+}
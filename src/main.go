@@ -2,10 +2,20 @@ package main
 
 import (
 	"github.com/SpaghettiDB/Storage-Engine/src/schemamanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+	// registers the heap resource manager used by the log recovery
+	_ "github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	// registers the row converter used by the schema changes
+	_ "github.com/SpaghettiDB/Storage-Engine/src/rowcodec"
 	"fmt"
 )
 
 func main() {
+	// replay the write-ahead log before anything reads the files
+	if err := logmanager.Recover(); err != nil {
+		panic(err)
+	}
+
 	// HeapManager PlayGround ----------------------------------------------------------------
 
 	// heapmanager.PlayGround()
@@ -14,16 +24,16 @@ func main() {
 
 	// indexmanager.PlayGround()
 
-	// indexmanager.InitializeIndex("test", "test", "test", false)
+	// indexmanager.InitializeIndex("test", "test", indexmanager.IndexOptions{Columns: []string{"test"}, Unique: true})
 
 	//scan test -------------------------------------------------------------------
 
-	// err := indexmanager.InitializeIndex("Student", "name", "name", false)
+	// err := indexmanager.InitializeIndex("Student", "name", indexmanager.IndexOptions{Columns: []string{"name"}})
 	// if err != nil {
 	// 	fmt.Println(err)
 	// }
 
-	// err = indexmanager.InitializeIndex("Student", "id", "id", false)
+	// err = indexmanager.InitializeIndex("Student", "id", indexmanager.IndexOptions{Columns: []string{"id"}, Unique: true})
 	// if err != nil {
 	// 	fmt.Println(err)
 	// }
@@ -31,7 +41,7 @@ func main() {
 	// d := make([]byte, 4)
 	// binary.BigEndian.PutUint32(d, 2)
 
-	// indexmanager.AddEntryToTableIndexes("Student", [][]byte{[]byte("mohammed"), d}, 2)
+	// indexmanager.AddEntryToTableIndexes("Student", []indexmanager.IndexKey{{[]byte("mohammed")}, {d}}, heapmanager.RID{PageID: 0, SlotID: 2})
 
 	// result, err := indexmanager.GetIndexSize("Student", "name")
 
@@ -44,6 +54,30 @@ func main() {
 
 
 
+	//row codec -------------------------------------------------------
+
+	// codec, err := rowcodec.New(schemamanager.Table{Name: "Student", Columns: []schemamanager.Column{{Name: "id", DataType: "int"}, {Name: "name", DataType: "string"}}})
+	// if err != nil {
+	// 	panic(err)
+	// }
+
+	// record, err := codec.Encode([]rowcodec.Value{2, "mohammed"})
+	// if err != nil {
+	// 	panic(err)
+	// }
+
+	// rid, err := heapmanager.AddRowToHeap("Student", record)
+
+	// name, err := codec.Project(record, 1)
+
+	//table manager -------------------------------------------------------
+
+	// rid, err := tablemanager.InsertRow("Student", []string{"id", "name"}, []tablemanager.Value{3, "ali"})
+	// var violation *errors.ConstraintViolationError
+	// if stderrors.As(err, &violation) {
+	// 	fmt.Println(violation.Constraint, violation.Columns)
+	// }
+
 	//schema testing -------------------------------------------------
 
 
@@ -0,0 +1,351 @@
+// this is rowcodec package main file, it turns the typed values of a row of a table into the record
+// stored in the heap and back, following the columns of the table in the schema.
+//
+// record:
+// | ColumnCount 2B | NullBitmap | Fixed Fields ... | Variable Values ... |
+//
+//   - ColumnCount is the number of columns of the table when the record was written, the columns
+//     added to the table after it are null in the record.
+//   - the null bitmap has one bit per column (ColumnCount bits rounded up to bytes), the bit i%8
+//     of the byte i/8 is set when the column i is null.
+//   - each column has a fixed field in the order of the columns, so the field of a column is always
+//     at the same place for a number of columns:
+//     int 8B (big-endian), float 8B (the bits of the IEEE 754 value), bool 1B, timestamp 12B
+//     (the seconds since the unix epoch 8B then the nanoseconds 4B), and for a string the offset
+//     from the start of the record of the end of its bytes 4B.
+//   - the bytes of the strings follow the fixed fields in the order of the columns, a string starts
+//     where the string column before it ends (or after the fixed fields for the first one).
+//
+// the field of a null column is zero, and a null string ends where the string before it ends.
+
+package rowcodec
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/indexmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/keycodec"
+	"github.com/SpaghettiDB/Storage-Engine/src/schemamanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+// Value is the value of a column, it holds the go value of the data type of the column (see types),
+// nil is null.
+type Value = any
+
+const (
+	columnCountSize = 2
+	offsetSize      = 4
+	maxColumns      = math.MaxUint16
+)
+
+var errShortRecord = errors.New("the record is too short")
+
+// Codec encodes and decodes the records of the rows of a table.
+type Codec struct {
+	table   string
+	columns []column
+}
+
+// a column of the table with where its field is
+type column struct {
+	name     string
+	dataType types.DataType
+	// the offset of the field after the null bitmap, and its size
+	field, size int
+	// the index of the string column before this one, -1 when there is none
+	previousString int
+}
+
+// New returns the codec of the rows of the table, the data types of its columns must be known by types.
+func New(table schemamanager.Table) (*Codec, error) {
+	if len(table.Columns) > maxColumns {
+		return nil, fmt.Errorf("table %s has %d columns, more than %d", table.Name, len(table.Columns), maxColumns)
+	}
+
+	codec := &Codec{table: table.Name, columns: make([]column, len(table.Columns))}
+	field, previousString := 0, -1
+	for i, c := range table.Columns {
+		dataType, err := types.ParseDataType(c.DataType)
+		if err != nil {
+			return nil, fmt.Errorf("column %s of table %s: %w", c.Name, table.Name, err)
+		}
+
+		size := fieldSize(dataType)
+		codec.columns[i] = column{name: c.Name, dataType: dataType, field: field, size: size, previousString: previousString}
+		field += size
+		if dataType == types.String {
+			previousString = i
+		}
+	}
+	return codec, nil
+}
+
+// the schema changes rewrite the rows of the tables and rebuild their indexes with the codec
+func init() {
+	schemamanager.RegisterRowConverter(Converter)
+	schemamanager.RegisterIndexKeyFunc(IndexKeyFunc)
+}
+
+// Converter returns the function that rewrites a record of a row of oldTable as a record of newTable.
+// the columns are matched by name, the columns of newTable that oldTable does not have get their default
+// value (null without one), and the value of a column whose data type changed is converted by types.Convert.
+func Converter(oldTable schemamanager.Table, newTable schemamanager.Table) (func(record []byte) ([]byte, error), error) {
+	oldCodec, err := New(oldTable)
+	if err != nil {
+		return nil, err
+	}
+	newCodec, err := New(newTable)
+	if err != nil {
+		return nil, err
+	}
+
+	// the index of each column of newTable in oldTable, -1 for a new column
+	sources := make([]int, len(newCodec.columns))
+	defaults := make([]Value, len(newCodec.columns))
+	for i, column := range newCodec.columns {
+		sources[i], err = oldCodec.ColumnIndex(column.name)
+		if err != nil {
+			sources[i] = -1
+			if defaults[i], err = newTable.Columns[i].DefaultValue(); err != nil {
+				return nil, fmt.Errorf("column %s of table %s: %w", column.name, newTable.Name, err)
+			}
+			continue
+		}
+		if oldType := oldCodec.columns[sources[i]].dataType; !types.Convertible(oldType, column.dataType) {
+			return nil, fmt.Errorf("column %s of table %s cannot be converted from %s to %s", column.name, newTable.Name, oldType, column.dataType)
+		}
+	}
+
+	return func(record []byte) ([]byte, error) {
+		oldValues, err := oldCodec.Decode(record)
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]Value, len(sources))
+		for i, source := range sources {
+			if source < 0 {
+				values[i] = defaults[i]
+				continue
+			}
+			column := newCodec.columns[i]
+			values[i], err = types.Convert(oldValues[source], oldCodec.columns[source].dataType, column.dataType)
+			if err != nil {
+				return nil, fmt.Errorf("column %s of table %s: %w", column.name, newTable.Name, err)
+			}
+		}
+		return newCodec.Encode(values)
+	}, nil
+}
+
+// IndexKeyFunc returns the function that reads the key of the index from a record of the table,
+// followed by the values of the included columns of the index. the values are encoded by keycodec
+// in ascending order, like indexmanager.TypedIndexKey does.
+func IndexKeyFunc(table schemamanager.Table, index schemamanager.Index) (indexmanager.KeyFunc, error) {
+	codec, err := New(table)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := append(slices.Clone(index.Columns()), index.IncludeColumns...)
+	positions := make([]int, len(columns))
+	for i, name := range columns {
+		if positions[i], err = codec.ColumnIndex(name); err != nil {
+			return nil, fmt.Errorf("index %s: %w", index.Name, err)
+		}
+	}
+
+	return func(record []byte) (indexmanager.IndexKey, error) {
+		key := make(indexmanager.IndexKey, len(positions))
+		for i, position := range positions {
+			value, err := codec.Project(record, position)
+			if err != nil {
+				return nil, err
+			}
+			key[i], err = keycodec.Encode(codec.columns[position].dataType, value, keycodec.Ascending)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return key, nil
+	}, nil
+}
+
+// returns the size of the fixed field of the data type
+func fieldSize(dataType types.DataType) int {
+	switch dataType {
+	case types.Int, types.Float:
+		return 8
+	case types.Bool:
+		return 1
+	case types.Timestamp:
+		return 12
+	}
+	return offsetSize
+}
+
+// ColumnIndex returns the index of the column with the name, in the order of the columns of the table.
+func (c *Codec) ColumnIndex(name string) (int, error) {
+	for i, column := range c.columns {
+		if column.name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("column %s of table %s does not exist", name, c.table)
+}
+
+// Encode returns the record of the row with the values, one for each column of the table in order.
+// the values are normalized to the go value of the data type of their column (an int becomes an int64).
+func (c *Codec) Encode(values []Value) ([]byte, error) {
+	if len(values) != len(c.columns) {
+		return nil, fmt.Errorf("table %s has %d columns, got %d values", c.table, len(c.columns), len(values))
+	}
+
+	base := headerSize(len(c.columns))
+	fieldsEnd := base + c.fieldsSize(len(c.columns))
+	record := make([]byte, fieldsEnd)
+	binary.BigEndian.PutUint16(record, uint16(len(c.columns)))
+
+	for i, column := range c.columns {
+		value, err := types.Normalize(column.dataType, values[i])
+		if err != nil {
+			return nil, fmt.Errorf("column %s of table %s: %w", column.name, c.table, err)
+		}
+
+		field := record[base+column.field : base+column.field+column.size]
+		if value == nil {
+			record[columnCountSize+i/8] |= 1 << (i % 8)
+			if column.dataType == types.String {
+				binary.BigEndian.PutUint32(field, uint32(len(record)))
+			}
+			continue
+		}
+
+		switch column.dataType {
+		case types.Int:
+			binary.BigEndian.PutUint64(field, uint64(value.(int64)))
+		case types.Float:
+			binary.BigEndian.PutUint64(field, math.Float64bits(value.(float64)))
+		case types.Bool:
+			if value.(bool) {
+				field[0] = 1
+			}
+		case types.Timestamp:
+			t := value.(time.Time)
+			binary.BigEndian.PutUint64(field, uint64(t.Unix()))
+			binary.BigEndian.PutUint32(field[8:], uint32(t.Nanosecond()))
+		case types.String:
+			record = append(record, value.(string)...)
+			if uint64(len(record)) > math.MaxUint32 {
+				return nil, fmt.Errorf("the record of table %s is longer than %d bytes", c.table, uint32(math.MaxUint32))
+			}
+			// the record may have moved
+			binary.BigEndian.PutUint32(record[base+column.field:], uint32(len(record)))
+		}
+	}
+	return record, nil
+}
+
+// Decode returns the values of the columns of the table in the record, in the order of the columns.
+// the columns added to the table after the record was written are null.
+func (c *Codec) Decode(record []byte) ([]Value, error) {
+	count, err := c.columnCount(record)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]Value, len(c.columns))
+	for i := 0; i < count; i++ {
+		values[i], err = c.decodeColumn(record, count, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Project returns the value of the column i in the record, without decoding the other columns.
+func (c *Codec) Project(record []byte, i int) (Value, error) {
+	if i < 0 || i >= len(c.columns) {
+		return nil, fmt.Errorf("table %s has %d columns, there is no column %d", c.table, len(c.columns), i)
+	}
+
+	count, err := c.columnCount(record)
+	if err != nil {
+		return nil, err
+	}
+	if i >= count {
+		return nil, nil
+	}
+	return c.decodeColumn(record, count, i)
+}
+
+// returns the number of columns of the record, after checking its fixed fields are all there
+func (c *Codec) columnCount(record []byte) (int, error) {
+	if len(record) < columnCountSize {
+		return 0, errShortRecord
+	}
+
+	count := int(binary.BigEndian.Uint16(record))
+	if count > len(c.columns) {
+		return 0, fmt.Errorf("the record has %d columns, table %s has %d", count, c.table, len(c.columns))
+	}
+	if len(record) < headerSize(count)+c.fieldsSize(count) {
+		return 0, errShortRecord
+	}
+	return count, nil
+}
+
+// decodes the column i of the record of count columns
+func (c *Codec) decodeColumn(record []byte, count int, i int) (Value, error) {
+	if record[columnCountSize+i/8]&(1<<(i%8)) != 0 {
+		return nil, nil
+	}
+
+	column := c.columns[i]
+	base := headerSize(count)
+	field := record[base+column.field : base+column.field+column.size]
+
+	switch column.dataType {
+	case types.Int:
+		return int64(binary.BigEndian.Uint64(field)), nil
+	case types.Float:
+		return math.Float64frombits(binary.BigEndian.Uint64(field)), nil
+	case types.Bool:
+		return field[0] != 0, nil
+	case types.Timestamp:
+		seconds := int64(binary.BigEndian.Uint64(field))
+		return time.Unix(seconds, int64(binary.BigEndian.Uint32(field[8:]))).UTC(), nil
+	}
+
+	start := base + c.fieldsSize(count)
+	if column.previousString >= 0 {
+		previous := c.columns[column.previousString]
+		start = int(binary.BigEndian.Uint32(record[base+previous.field:]))
+	}
+	end := int(binary.BigEndian.Uint32(field))
+	if start > end || end > len(record) {
+		return nil, fmt.Errorf("invalid offsets %d and %d of column %s in a record of %d bytes", start, end, column.name, len(record))
+	}
+	return string(record[start:end]), nil
+}
+
+// returns the size of the fixed fields of the first count columns
+func (c *Codec) fieldsSize(count int) int {
+	if count == 0 {
+		return 0
+	}
+	last := c.columns[count-1]
+	return last.field + last.size
+}
+
+// returns the size of the column count and of the null bitmap of a record of count columns
+func headerSize(count int) int {
+	return columnCountSize + (count+7)/8
+}
@@ -0,0 +1,230 @@
+package rowcodec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/keycodec"
+	"github.com/SpaghettiDB/Storage-Engine/src/schemamanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+// returns a table with a column for each pair of name and data type
+func testTable(name string, columns ...string) schemamanager.Table {
+	table := schemamanager.Table{Name: name}
+	for i := 0; i < len(columns); i += 2 {
+		table.Columns = append(table.Columns, schemamanager.Column{Name: columns[i], DataType: columns[i+1]})
+	}
+	return table
+}
+
+func testCodec(t *testing.T, table schemamanager.Table) *Codec {
+	t.Helper()
+
+	codec, err := New(table)
+	if err != nil {
+		t.Fatalf("failed to create the codec of %s: %s", table.Name, err)
+	}
+	return codec
+}
+
+func encode(t *testing.T, codec *Codec, values ...Value) []byte {
+	t.Helper()
+
+	record, err := codec.Encode(values)
+	if err != nil {
+		t.Fatalf("failed to encode %v: %s", values, err)
+	}
+	return record
+}
+
+// checks that the record decodes to the values, and that each column projects to its value
+func checkDecode(t *testing.T, codec *Codec, record []byte, expected ...Value) {
+	t.Helper()
+
+	values, err := codec.Decode(record)
+	if err != nil {
+		t.Fatalf("failed to decode the record: %s", err)
+	}
+	if !slices.Equal(values, expected) {
+		t.Fatalf("the record decodes to %v, expected %v", values, expected)
+	}
+
+	for i := range expected {
+		value, err := codec.Project(record, i)
+		if err != nil {
+			t.Fatalf("failed to project column %d: %s", i, err)
+		}
+		if value != expected[i] {
+			t.Fatalf("column %d projects to %v, expected %v", i, value, expected[i])
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	codec := testCodec(t, testTable("all",
+		"id", "int", "a", "string", "price", "float", "b", "string", "ok", "bool", "c", "string", "at", "timestamp"))
+	at := time.Date(2024, 5, 17, 10, 30, 0, 123456789, time.UTC)
+
+	// the values are normalized to the go values of the data types
+	record := encode(t, codec, 7, "first", float32(1.5), "second", true, "third", at)
+	checkDecode(t, codec, record, int64(7), "first", 1.5, "second", true, "third", at)
+
+	// a null string ends where the string before it ends, the strings after it start there
+	record = encode(t, codec, int64(-1), "first", nil, nil, false, "third", nil)
+	checkDecode(t, codec, record, int64(-1), "first", nil, nil, false, "third", nil)
+	record = encode(t, codec, nil, nil, 2.5, "second", nil, nil, at)
+	checkDecode(t, codec, record, nil, nil, 2.5, "second", nil, nil, at)
+
+	// an empty string is not null
+	record = encode(t, codec, 1, "", nil, "", nil, nil, nil)
+	checkDecode(t, codec, record, int64(1), "", nil, "", nil, nil, nil)
+
+	if _, err := codec.Encode([]Value{1, "a"}); err == nil {
+		t.Fatal("a row with fewer values than columns must be refused")
+	}
+	if _, err := codec.Encode([]Value{"1", nil, nil, nil, nil, nil, nil}); err == nil {
+		t.Fatal("a string value of an int column must be refused")
+	}
+	if _, err := New(testTable("unknown", "id", "decimal")); err == nil {
+		t.Fatal("a column with an unknown data type must be refused")
+	}
+}
+
+// a record written before columns were added to the table has them null
+func TestDecodeFewerColumns(t *testing.T) {
+	oldCodec := testCodec(t, testTable("fewer", "id", "int", "name", "string"))
+	newCodec := testCodec(t, testTable("fewer", "id", "int", "name", "string", "note", "string", "count", "int"))
+
+	record := encode(t, oldCodec, 1, "ann")
+	checkDecode(t, newCodec, record, int64(1), "ann", nil, nil)
+
+	// the record of a table with more columns does not belong to the table
+	if _, err := oldCodec.Decode(encode(t, newCodec, 1, "ann", "x", 2)); err == nil {
+		t.Fatal("a record with more columns than the table must be refused")
+	}
+	if _, err := newCodec.Project(record, 4); err == nil {
+		t.Fatal("the projection of a column the table does not have must fail")
+	}
+}
+
+func TestInvalidRecords(t *testing.T) {
+	codec := testCodec(t, testTable("invalid", "id", "int", "a", "string", "b", "string"))
+	record := encode(t, codec, 1, "ab", "cd")
+
+	for _, short := range [][]byte{nil, record[:1], record[:10]} {
+		if _, err := codec.Decode(short); !errors.Is(err, errShortRecord) {
+			t.Fatalf("expected errShortRecord for a record of %d bytes, got %v", len(short), err)
+		}
+		if _, err := codec.Project(short, 0); !errors.Is(err, errShortRecord) {
+			t.Fatalf("expected errShortRecord projecting a record of %d bytes, got %v", len(short), err)
+		}
+	}
+
+	// the field of a is after the column count, the null bitmap and the field of id
+	aField := headerSize(3) + 8
+	bField := aField + offsetSize
+
+	pastEnd := slices.Clone(record)
+	binary.BigEndian.PutUint32(pastEnd[bField:], uint32(len(record)+1))
+	if _, err := codec.Project(pastEnd, 2); err == nil || !strings.Contains(err.Error(), "invalid offsets") {
+		t.Fatalf("expected an invalid offset for a string that ends after the record, got %v", err)
+	}
+
+	// b starts where a ends, after the end of b
+	backwards := slices.Clone(record)
+	binary.BigEndian.PutUint32(backwards[aField:], uint32(len(record)))
+	binary.BigEndian.PutUint32(backwards[bField:], uint32(len(record)-1))
+	if _, err := codec.Decode(backwards); err == nil || !strings.Contains(err.Error(), "invalid offsets") {
+		t.Fatalf("expected an invalid offset for a string that ends before it starts, got %v", err)
+	}
+}
+
+func TestConverter(t *testing.T) {
+	oldTable := testTable("convert", "id", "int", "amount", "int", "code", "string", "gone", "string", "flag", "int")
+	newTable := testTable("convert", "id", "int", "amount", "float", "code", "int", "flag", "bool", "added", "string")
+	x := "x"
+	newTable.Columns[4].Default = &x
+
+	convert, err := Converter(oldTable, newTable)
+	if err != nil {
+		t.Fatalf("failed to create the converter: %s", err)
+	}
+	oldCodec := testCodec(t, oldTable)
+	newCodec := testCodec(t, newTable)
+
+	// the columns are matched by name, the dropped column is left out and the new one gets its default
+	record, err := convert(encode(t, oldCodec, 1, 250, "42", "dropped", 1))
+	if err != nil {
+		t.Fatalf("failed to convert the record: %s", err)
+	}
+	checkDecode(t, newCodec, record, int64(1), 250.0, int64(42), true, "x")
+
+	record, err = convert(encode(t, oldCodec, 2, nil, nil, nil, 0))
+	if err != nil {
+		t.Fatalf("failed to convert the record with nulls: %s", err)
+	}
+	checkDecode(t, newCodec, record, int64(2), nil, nil, false, "x")
+
+	// a value that has no value of the new data type
+	if _, err := convert(encode(t, oldCodec, 3, 1, "abc", nil, 0)); err == nil {
+		t.Fatal("the conversion of \"abc\" to int must fail")
+	}
+	if _, err := convert(encode(t, oldCodec, 4, 1, "1", nil, 2)); err == nil {
+		t.Fatal("the conversion of 2 to bool must fail")
+	}
+
+	timestamps := testTable("convert", "id", "timestamp")
+	if _, err := Converter(timestamps, testTable("convert", "id", "int")); err == nil {
+		t.Fatal("a converter from timestamp to int must be refused")
+	}
+}
+
+func TestIndexKeyFunc(t *testing.T) {
+	table := testTable("keys", "id", "int", "name", "string", "price", "float")
+	index := schemamanager.Index{Name: "keys_name_id", ColumnNames: []string{"name", "id"}, IncludeColumns: []string{"price"}}
+
+	keyOf, err := IndexKeyFunc(table, index)
+	if err != nil {
+		t.Fatalf("failed to create the key function: %s", err)
+	}
+
+	record := encode(t, testCodec(t, table), 5, "bob", nil)
+	key, err := keyOf(record)
+	if err != nil {
+		t.Fatalf("failed to read the key: %s", err)
+	}
+
+	expected := [][]byte{
+		encodeKey(t, types.String, "bob"),
+		encodeKey(t, types.Int, int64(5)),
+		encodeKey(t, types.Float, nil),
+	}
+	if len(key) != len(expected) {
+		t.Fatalf("the key has %d values, expected %d", len(key), len(expected))
+	}
+	for i := range expected {
+		if !bytes.Equal(key[i], expected[i]) {
+			t.Fatalf("value %d of the key is %x, expected %x", i, key[i], expected[i])
+		}
+	}
+
+	if _, err := IndexKeyFunc(table, schemamanager.Index{Name: "keys_missing", ColumnName: "missing"}); err == nil {
+		t.Fatal("an index on a column the table does not have must be refused")
+	}
+}
+
+func encodeKey(t *testing.T, dataType types.DataType, value any) []byte {
+	t.Helper()
+
+	encoded, err := keycodec.Encode(dataType, value, keycodec.Ascending)
+	if err != nil {
+		t.Fatalf("failed to encode %v: %s", value, err)
+	}
+	return encoded
+}
@@ -0,0 +1,173 @@
+package schemamanager
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/checkexpr"
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/indexmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+//the constraints of the columns of a table:
+//  - a NOT NULL column and a PRIMARY KEY column never hold null.
+//  - the columns with PrimaryKey are the primary key of the table (a composite key when there is more than one),
+//    and each UNIQUE column has its own key. every key has a unique index, created with the table or the column,
+//    <table>_pkey for the primary key and <table>_<column>_key for a UNIQUE column.
+//  - as in SQL a null is never equal to another null, so a UNIQUE column can hold many nulls,
+//    and a composite key is only checked when none of its values is null.
+//  - a CHECK fails when its expression is false on the row, not when it is unknown (see checkexpr).
+//the constraints are enforced when the rows are written by the tablemanager package,
+//the rows written to the heap directly are not checked
+
+//PrimaryKeyIndexName returns the name of the index of the primary key of the table
+func PrimaryKeyIndexName(table string) string {
+	return table + "_pkey"
+}
+
+//UniqueIndexName returns the name of the index of the UNIQUE column of the table
+func UniqueIndexName(table string, column string) string {
+	return table + "_" + column + "_key"
+}
+
+//Nullable reports whether the column can hold null
+func (column Column) Nullable() bool {
+	return !column.NotNull && !column.PrimaryKey
+}
+
+//DefaultValue returns the default value of the column as a value of its data type, nil when it has none
+func (column Column) DefaultValue() (any, error) {
+	if column.Default == nil {
+		return nil, nil
+	}
+	dataType, err := types.ParseDataType(column.DataType)
+	if err != nil {
+		return nil, err
+	}
+	return types.Convert(*column.Default, types.String, dataType)
+}
+
+//PrimaryKey returns the columns of the primary key of the table in order, none when it has no primary key
+func (table Table) PrimaryKey() []string {
+	columns := make([]string, 0)
+	for _, column := range table.Columns {
+		if column.PrimaryKey {
+			columns = append(columns, column.Name)
+		}
+	}
+	return columns
+}
+
+//CompileCheck returns the CHECK expression of the column, nil when the column has none
+func (table Table) CompileCheck(column Column) (*checkexpr.Expr, error) {
+	if column.Check == "" {
+		return nil, nil
+	}
+	return checkexpr.Compile(column.Check, func(name string) (types.DataType, bool) {
+		i := findColumn(table, name)
+		if i == -1 {
+			return "", false
+		}
+		dataType, err := types.ParseDataType(table.Columns[i].DataType)
+		return dataType, err == nil
+	})
+}
+
+//IndexConstraint returns the constraint that the index of the table enforces, empty for an index of no constraint.
+//the index of a foreign key has its name, the index of a key is found by its columns, its name does not change when the table or the column is renamed
+func (table Table) IndexConstraint(index string) dberrors.ConstraintType {
+	if slices.ContainsFunc(table.ForeignKeys, func(fk ForeignKey) bool { return fk.Name == index }) {
+		return dberrors.ForeignKey
+	}
+
+	i := findIndex(table, index)
+	if i == -1 || !table.Indexes[i].Unique {
+		return ""
+	}
+
+	columns := table.Indexes[i].Columns()
+	if primaryKey := table.PrimaryKey(); len(primaryKey) > 0 && slices.Equal(columns, primaryKey) {
+		return dberrors.PrimaryKey
+	}
+	if len(columns) == 1 {
+		if c := findColumn(table, columns[0]); c != -1 && table.Columns[c].Unique {
+			return dberrors.Unique
+		}
+	}
+	return ""
+}
+
+//checks the constraints of the columns of the table: their defaults have their data types and their checks compile
+func validateColumns(table Table) error {
+	for _, column := range table.Columns {
+		if _, err := column.DefaultValue(); err != nil {
+			return fmt.Errorf("INVALID DEFAULT FOR COLUMN %s: %v", column.Name, err)
+		}
+		if _, err := table.CompileCheck(column); err != nil {
+			return fmt.Errorf("INVALID CHECK FOR COLUMN %s: %v", column.Name, err)
+		}
+	}
+	return nil
+}
+
+//returns the indexes of the keys of the table that use one of the columns
+func constraintIndexes(table Table, columns []Column) []Index {
+	indexes := make([]Index, 0)
+	primaryKey := table.PrimaryKey()
+	if slices.ContainsFunc(columns, func(c Column) bool { return c.PrimaryKey }) {
+		indexes = append(indexes, Index{Name: PrimaryKeyIndexName(table.Name), ColumnNames: primaryKey, Unique: true})
+	}
+	for _, column := range columns {
+		//the primary key of one column is already unique
+		if column.Unique && !(column.PrimaryKey && len(primaryKey) == 1) {
+			indexes = append(indexes, Index{Name: UniqueIndexName(table.Name, column.Name), ColumnNames: []string{column.Name}, Unique: true})
+		}
+	}
+	return indexes
+}
+
+//creates the indexes of the table in the index manager in one transaction, none is created if one fails
+func createIndexes(table Table, indexes []Index) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	tx, err := logmanager.BeginAfterCheckpoint()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(createIndexesTx(tx, table, indexes))
+}
+
+//creates the indexes of the table in the index manager in tx, with the entries of the rows in its heap.
+//tx must be started with logmanager.BeginAfterCheckpoint (see indexmanager.CreateIndexFromHeapTx)
+func createIndexesTx(tx *logmanager.Transaction, table Table, indexes []Index) error {
+	_, statErr := os.Stat(table.Name)
+	hasHeap := statErr == nil
+	if hasHeap && len(indexes) > 0 && indexKeyFunc == nil {
+		return errNoIndexKeyFunc
+	}
+
+	for _, index := range indexes {
+		//the keys of the rows are typed values, so a key with a null is not a duplicate
+		options := indexmanager.IndexOptions{Columns: index.Columns(), Include: index.IncludeColumns, Unique: index.Unique, Typed: true}
+		if !hasHeap {
+			if err := indexmanager.InitializeIndexTx(tx, table.Name, index.Name, options); err != nil {
+				return err
+			}
+			continue
+		}
+
+		keyOf, err := indexKeyFunc(table, index)
+		if err != nil {
+			return err
+		}
+		if err := indexmanager.CreateIndexFromHeapTx(tx, table.Name, index.Name, options, table.Name, keyOf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,615 @@
+package schemamanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/checkexpr"
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/indexmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/keycodec"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+//the rows of a table are in the heap file with the name of the table,
+//and its indexes in the directory indexes/<table> of the index manager.
+//the DDL functions change them with the schema, the files are changed first
+//so a failure leaves the schema as it was.
+
+//RowConverter returns the function that rewrites a row of a table written with the columns of oldTable
+//so that it has the columns of newTable, the columns are matched by name
+type RowConverter func(oldTable Table, newTable Table) (func(row []byte) ([]byte, error), error)
+
+var rowConverter RowConverter
+
+//RegisterRowConverter sets the converter used to rewrite the rows of a table when its columns change,
+//the rowcodec package registers its own. without a converter a change that rewrites the rows of a table with a heap fails
+func RegisterRowConverter(converter RowConverter) {
+	rowConverter = converter
+}
+
+//IndexKeyFunc returns the function that reads the key of the index from a row of the table,
+//followed by the values of the included columns of the index
+type IndexKeyFunc func(table Table, index Index) (indexmanager.KeyFunc, error)
+
+var indexKeyFunc IndexKeyFunc
+
+//RegisterIndexKeyFunc sets the function used to read the keys of the rows of a table when its indexes
+//are built or checked, the rowcodec package registers its own. without one a change that reads the keys
+//of a table with a heap fails
+func RegisterIndexKeyFunc(keyFunc IndexKeyFunc) {
+	indexKeyFunc = keyFunc
+}
+
+//the errors of a change that needs a hook that is not registered, the rows of the table would not match its schema
+var (
+	errNoRowConverter = errors.New("NO ROW CONVERTER IS REGISTERED, IMPORT THE ROWCODEC PACKAGE")
+	errNoIndexKeyFunc = errors.New("NO INDEX KEY FUNCTION IS REGISTERED, IMPORT THE ROWCODEC PACKAGE")
+)
+
+//DropTable removes the table from the schema with its heap and all its indexes,
+//a table referenced by a foreign key of another table cannot be dropped
+func DropTable(table string) error {
+	schema, err := readSchema()
+	if err != nil {
+		return err
+	}
+
+	tableIndex := findTable(schema, table)
+	if tableIndex == -1 {
+		return fmt.Errorf("TABLE NOT FOUND")
+	}
+	for _, reference := range ReferencesTo(schema.Tables, table) {
+		if reference.Table.Name != table {
+			return fmt.Errorf("TABLE %s IS REFERENCED BY FOREIGN KEY %s OF TABLE %s", table, reference.ForeignKey.Name, reference.Table.Name)
+		}
+	}
+
+	if err := indexmanager.DeleteTableIndexes(table); err != nil {
+		return err
+	}
+	if err := heapmanager.DeleteHeap(table); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	schema.Tables = slices.Delete(schema.Tables, tableIndex, tableIndex+1)
+	return writeSchema(schema)
+}
+
+//DropColumn removes the column from the table and from all its rows,
+//a column used by an index of the table (as a column or an included column) cannot be dropped
+func DropColumn(table string, column string) error {
+	schema, err := readSchema()
+	if err != nil {
+		return err
+	}
+
+	tableIndex := findTable(schema, table)
+	if tableIndex == -1 {
+		return fmt.Errorf("TABLE NOT FOUND")
+	}
+	oldTable := schema.Tables[tableIndex]
+
+	columnIndex := findColumn(oldTable, column)
+	if columnIndex == -1 {
+		return fmt.Errorf("COLUMN NOT FOUND")
+	}
+
+	for _, index := range oldTable.Indexes {
+		if slices.Contains(index.Columns(), column) || slices.Contains(index.IncludeColumns, column) {
+			return fmt.Errorf("COLUMN %s IS USED BY INDEX %s", column, index.Name)
+		}
+	}
+	//the check of the column goes with it, the checks of the other columns must not use it
+	for _, c := range oldTable.Columns {
+		if c.Name == column {
+			continue
+		}
+		check, err := oldTable.CompileCheck(c)
+		if err != nil {
+			return err
+		}
+		if check != nil && slices.Contains(check.Columns(), column) {
+			return fmt.Errorf("COLUMN %s IS USED BY THE CHECK OF COLUMN %s", column, c.Name)
+		}
+	}
+
+	newTable := oldTable
+	newTable.Columns = slices.Delete(slices.Clone(oldTable.Columns), columnIndex, columnIndex+1)
+
+	//the rows are read and rewritten with the schema in one transaction, so a failure or a crash
+	//leaves either the old table or the new one
+	schema.Tables[tableIndex] = newTable
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
+	}
+	if err := tx.Finish(rewriteTableTx(tx, oldTable, newTable, schema)); err != nil {
+		return err
+	}
+
+	//the other writes of the schema are not logged, see AlterColumnType
+	return logmanager.Checkpoint()
+}
+
+//DropIndex removes the index from the table and deletes its index file,
+//the index of a PRIMARY KEY or UNIQUE constraint cannot be dropped
+func DropIndex(table string, index string) error {
+	schema, err := readSchema()
+	if err != nil {
+		return err
+	}
+
+	tableIndex := findTable(schema, table)
+	if tableIndex == -1 {
+		return fmt.Errorf("TABLE NOT FOUND")
+	}
+
+	indexIndex := findIndex(schema.Tables[tableIndex], index)
+	if indexIndex == -1 {
+		return fmt.Errorf("INDEX NOT FOUND")
+	}
+	if constraint := schema.Tables[tableIndex].IndexConstraint(index); constraint != "" {
+		return fmt.Errorf("INDEX %s IS USED BY A %s CONSTRAINT", index, constraint)
+	}
+
+	//an index of the schema that was never created in the index manager has no file
+	if err := indexmanager.DeleteIndex(table, index); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	schema.Tables[tableIndex].Indexes = slices.Delete(schema.Tables[tableIndex].Indexes, indexIndex, indexIndex+1)
+	return writeSchema(schema)
+}
+
+//RenameTable renames the table with its heap and its indexes and in the foreign keys that reference it,
+//the new name must not be used by another table
+func RenameTable(table string, newName string) error {
+	schema, err := readSchema()
+	if err != nil {
+		return err
+	}
+
+	tableIndex := findTable(schema, table)
+	if tableIndex == -1 {
+		return fmt.Errorf("TABLE NOT FOUND")
+	}
+	if findTable(schema, newName) != -1 {
+		return fmt.Errorf("TABLE ALREADY EXISTS")
+	}
+
+	heapRenamed := true
+	if err := heapmanager.RenameHeap(table, newName); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		heapRenamed = false
+	}
+
+	if err := indexmanager.RenameTableIndexes(table, newName); err != nil {
+		//the heap gets its old name back so the table stays as it was
+		if heapRenamed {
+			if renameErr := heapmanager.RenameHeap(newName, table); renameErr != nil {
+				return errors.Join(err, renameErr)
+			}
+		}
+		return err
+	}
+
+	schema.Tables[tableIndex].Name = newName
+	for i := range schema.Tables {
+		for j := range schema.Tables[i].ForeignKeys {
+			if fk := &schema.Tables[i].ForeignKeys[j]; fk.RefTable == table {
+				fk.RefTable = newName
+			}
+		}
+	}
+	return writeSchema(schema)
+}
+
+//RenameColumn renames the column of the table and in the indexes, the checks and the foreign keys that use it,
+//the rows do not change since their values are stored in the order of the columns.
+//the indexes of the constraints keep their names
+func RenameColumn(table string, column string, newName string) error {
+	schema, err := readSchema()
+	if err != nil {
+		return err
+	}
+
+	tableIndex := findTable(schema, table)
+	if tableIndex == -1 {
+		return fmt.Errorf("TABLE NOT FOUND")
+	}
+	t := &schema.Tables[tableIndex]
+
+	columnIndex := findColumn(*t, column)
+	if columnIndex == -1 {
+		return fmt.Errorf("COLUMN NOT FOUND")
+	}
+	if findColumn(*t, newName) != -1 {
+		return fmt.Errorf("COLUMN ALREADY EXISTS")
+	}
+
+	if err := indexmanager.RenameIndexColumn(table, column, newName); err != nil {
+		return err
+	}
+
+	for i := range t.Columns {
+		if t.Columns[i].Check == "" {
+			continue
+		}
+		check, err := checkexpr.RenameColumn(t.Columns[i].Check, column, newName)
+		if err != nil {
+			return err
+		}
+		t.Columns[i].Check = check
+	}
+
+	t.Columns[columnIndex].Name = newName
+	for i := range t.Indexes {
+		index := &t.Indexes[i]
+		if index.ColumnName == column {
+			index.ColumnName = newName
+		}
+		renameIn(index.ColumnNames, column, newName)
+		renameIn(index.IncludeColumns, column, newName)
+	}
+	for _, fk := range t.ForeignKeys {
+		renameIn(fk.Columns, column, newName)
+	}
+	for i := range schema.Tables {
+		for _, fk := range schema.Tables[i].ForeignKeys {
+			if fk.RefTable == table {
+				renameIn(fk.RefColumns, column, newName)
+			}
+		}
+	}
+	return writeSchema(schema)
+}
+
+//AlterColumnType changes the data type of the column (see types.Convertible for the legal changes),
+//the rows of the table are rewritten with the converted values and the indexes that use the column are rebuilt.
+//all the rows are converted and the unique indexes checked before anything changes, so a value that
+//cannot be converted or two rows with the same key leave the table as it was.
+//the rows, the schema and the indexes, built again next to the old ones, are written in one transaction,
+//so a failure or a crash leaves either the old table or the new one
+func AlterColumnType(table string, column string, newType string) error {
+	schema, err := readSchema()
+	if err != nil {
+		return err
+	}
+
+	tableIndex := findTable(schema, table)
+	if tableIndex == -1 {
+		return fmt.Errorf("TABLE NOT FOUND")
+	}
+	oldTable := schema.Tables[tableIndex]
+
+	columnIndex := findColumn(oldTable, column)
+	if columnIndex == -1 {
+		return fmt.Errorf("COLUMN NOT FOUND")
+	}
+
+	//a foreign key has the data types of the columns it references
+	for _, reference := range ReferencesTo(schema.Tables, table) {
+		if slices.Contains(reference.ForeignKey.RefColumns, column) {
+			return fmt.Errorf("COLUMN %s IS USED BY FOREIGN KEY %s", column, reference.ForeignKey.Name)
+		}
+	}
+	for _, fk := range oldTable.ForeignKeys {
+		if slices.Contains(fk.Columns, column) {
+			return fmt.Errorf("COLUMN %s IS USED BY FOREIGN KEY %s", column, fk.Name)
+		}
+	}
+
+	from, err := types.ParseDataType(oldTable.Columns[columnIndex].DataType)
+	if err != nil {
+		return err
+	}
+	to, err := types.ParseDataType(newType)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return nil
+	}
+	if !types.Convertible(from, to) {
+		return fmt.Errorf("CANNOT CONVERT COLUMN %s FROM %s TO %s", column, from, to)
+	}
+
+	newTable := oldTable
+	newTable.Columns = slices.Clone(oldTable.Columns)
+	newTable.Columns[columnIndex].DataType = string(to)
+	if err := validateColumns(newTable); err != nil {
+		return err
+	}
+
+	indexes := make([]Index, 0)
+	for _, index := range oldTable.Indexes {
+		if slices.Contains(index.Columns(), column) || slices.Contains(index.IncludeColumns, column) {
+			indexes = append(indexes, index)
+		}
+	}
+	indexes, err = builtIndexes(table, indexes)
+	if err != nil {
+		return err
+	}
+	if len(indexes) > 0 && indexKeyFunc == nil {
+		return errNoIndexKeyFunc
+	}
+
+	//the rows are read, checked and rewritten and the indexes rebuilt in one transaction, no other
+	//transaction changes the table in between
+	schema.Tables[tableIndex] = newTable
+	tx, err := logmanager.BeginAfterCheckpoint()
+	if err != nil {
+		return err
+	}
+	if err := tx.Finish(alterColumnTypeTx(tx, oldTable, newTable, schema, indexes)); err != nil {
+		return err
+	}
+
+	//the other writes of the schema are not logged, the log is emptied so recovery never writes
+	//this schema over a later one
+	return logmanager.Checkpoint()
+}
+
+//converts the rows, checks the unique indexes and writes the rows, the schema with the new data type
+//and the new indexes in tx
+func alterColumnTypeTx(tx *logmanager.Transaction, oldTable Table, newTable Table, schema Schema, indexes []Index) error {
+	rows, err := convertRows(oldTable, newTable)
+	if err != nil {
+		return err
+	}
+	if err := checkUniqueIndexes(newTable, indexes, rows); err != nil {
+		return err
+	}
+
+	if err := writeRowsTx(tx, oldTable.Name, rows); err != nil {
+		return err
+	}
+	if err := writeSchemaTx(tx, schema); err != nil {
+		return err
+	}
+	return rebuildIndexesTx(tx, newTable, indexes)
+}
+
+//builds the indexes of the table again from the rows of its heap in tx, the rows have the columns of table.
+//a table without a heap has empty indexes, they do not change
+func rebuildIndexesTx(tx *logmanager.Transaction, table Table, indexes []Index) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(table.Name); os.IsNotExist(err) {
+		return nil
+	}
+
+	for _, index := range indexes {
+		keyOf, err := indexKeyFunc(table, index)
+		if err != nil {
+			return err
+		}
+		if err := indexmanager.RebuildIndexTx(tx, table.Name, index.Name, table.Name, keyOf); err != nil {
+			return fmt.Errorf("failed to rebuild index %s: %w", index.Name, err)
+		}
+	}
+	return nil
+}
+
+//returns the indexes that were created in the index manager, an index of the schema may have no file
+func builtIndexes(table string, indexes []Index) ([]Index, error) {
+	if len(indexes) == 0 {
+		return indexes, nil
+	}
+
+	metadata, err := indexmanager.GetIndexesMetadata(table)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(indexes, func(index Index) bool {
+		return !slices.ContainsFunc(metadata, func(m indexmanager.IndexMetadata) bool { return m.Name == index.Name })
+	}), nil
+}
+
+//checks that no two of the rows have the same key in one of the unique indexes of the table
+func checkUniqueIndexes(table Table, indexes []Index, rows []heapRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	for _, index := range indexes {
+		if !index.Unique {
+			continue
+		}
+		if indexKeyFunc == nil {
+			return errNoIndexKeyFunc
+		}
+		keyOf, err := indexKeyFunc(table, index)
+		if err != nil {
+			return err
+		}
+
+		keys := make(map[string]heapmanager.RID, len(rows))
+		for _, r := range rows {
+			key, err := keyOf(r.row)
+			if err != nil {
+				return err
+			}
+			//the values of the included columns follow the key and are not part of it
+			key = key[:len(index.Columns())]
+			//as in SQL a null is never equal to another null, a key with a null is never a duplicate
+			if slices.ContainsFunc(key, func(value []byte) bool { return keycodec.IsNull(value, keycodec.Ascending) }) {
+				continue
+			}
+			k := fmt.Sprintf("%q", key)
+			if _, ok := keys[k]; ok {
+				return uniqueViolation(table, index)
+			}
+			keys[k] = r.rid
+		}
+	}
+	return nil
+}
+
+//returns the error of two rows with the same key in the unique index of the table
+func uniqueViolation(table Table, index Index) error {
+	constraint := dberrors.Unique
+	if primaryKey := table.PrimaryKey(); len(primaryKey) > 0 && slices.Equal(index.Columns(), primaryKey) {
+		constraint = dberrors.PrimaryKey
+	}
+	return &dberrors.ConstraintViolationError{Constraint: constraint, Table: table.Name, Columns: index.Columns(), Name: index.Name}
+}
+
+//a row of the heap of a table
+type heapRow struct {
+	rid heapmanager.RID
+	row []byte
+}
+
+//rewrites all the rows of the heap of the table for the columns of newTable and writes the schema in tx,
+//nothing is written if a row cannot be converted
+func rewriteTableTx(tx *logmanager.Transaction, oldTable Table, newTable Table, schema Schema) error {
+	rows, err := convertRows(oldTable, newTable)
+	if err != nil {
+		return err
+	}
+	if err := writeRowsTx(tx, oldTable.Name, rows); err != nil {
+		return err
+	}
+	return writeSchemaTx(tx, schema)
+}
+
+//reads all the rows of the heap of the table and returns them converted for the columns of newTable,
+//a table without a heap has no rows to convert
+func convertRows(oldTable Table, newTable Table) ([]heapRow, error) {
+	if _, err := os.Stat(oldTable.Name); os.IsNotExist(err) {
+		return nil, nil
+	}
+	if rowConverter == nil {
+		return nil, errNoRowConverter
+	}
+
+	convert, err := rowConverter(oldTable, newTable)
+	if err != nil {
+		return nil, err
+	}
+
+	//the rows are all read before they change, a row that moves to another page is not read twice
+	rows := make([]heapRow, 0)
+	scanner, err := heapmanager.OpenHeapScanner(oldTable.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer scanner.Close()
+
+	for scanner.Next() {
+		row, err := convert(scanner.Row())
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert row %v of table %s: %w", scanner.RID(), oldTable.Name, err)
+		}
+		rows = append(rows, heapRow{rid: scanner.RID(), row: row})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+//writes the rows over the rows of the heap with their rids, the changes are logged by tx
+func writeRowsTx(tx *logmanager.Transaction, heap string, rows []heapRow) error {
+	for _, r := range rows {
+		if err := heapmanager.UpdateRowInHeapTx(tx, heap, r.rid, r.row); err != nil {
+			return fmt.Errorf("failed to rewrite row %v of table %s: %w", r.rid, heap, err)
+		}
+	}
+	return nil
+}
+
+//helper function to read the whole schema
+func readSchema() (Schema, error) {
+	file, err := openSchemaFile()
+	if err != nil {
+		return Schema{}, err
+	}
+	defer file.Close()
+
+	var schema Schema
+	if err := json.NewDecoder(file).Decode(&schema); err != nil {
+		return Schema{}, fmt.Errorf("%v", err)
+	}
+	return schema, nil
+}
+
+//helper function to write the whole schema over the content of the file
+func writeSchema(schema Schema) error {
+	file, err := openSchemaFileWithTruncate()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(schema); err != nil {
+		return fmt.Errorf("%v", err)
+	}
+	return nil
+}
+
+//writes the whole schema over the content of the file like writeSchema, the writes are logged by tx
+func writeSchemaTx(tx *logmanager.Transaction, schema Schema) error {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("%v", err)
+	}
+	data = append(data, '\n')
+
+	file, err := tx.OpenFile(path.Join("schemamanager", "schema.json"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteAt(data, 0); err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > int64(len(data)) {
+		return file.Truncate(int64(len(data)))
+	}
+	return nil
+}
+
+//helper function to rename the column in the columns
+func renameIn(columns []string, column string, newName string) {
+	for i := range columns {
+		if columns[i] == column {
+			columns[i] = newName
+		}
+	}
+}
+
+//helper functions that return the position of the table, the column or the index with the name, -1 when there is none
+func findTable(schema Schema, name string) int {
+	return slices.IndexFunc(schema.Tables, func(t Table) bool { return t.Name == name })
+}
+
+func findColumn(table Table, name string) int {
+	return slices.IndexFunc(table.Columns, func(c Column) bool { return c.Name == name })
+}
+
+func findIndex(table Table, name string) int {
+	return slices.IndexFunc(table.Indexes, func(i Index) bool { return i.Name == name })
+}
@@ -0,0 +1,447 @@
+package schemamanager_test
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/indexmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/rowcodec"
+	"github.com/SpaghettiDB/Storage-Engine/src/schemamanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/tablemanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+// creates the index from the rows of the table and adds it to the schema, like the engine does for CREATE INDEX
+func createTestIndex(t *testing.T, table string, index schemamanager.Index) {
+	t.Helper()
+
+	keyOf, err := rowcodec.IndexKeyFunc(getTable(t, table), index)
+	if err != nil {
+		t.Fatalf("failed to get the key function of %s: %s", index.Name, err)
+	}
+	options := indexmanager.IndexOptions{Columns: index.Columns(), Include: index.IncludeColumns, Unique: index.Unique, Typed: true}
+	if err := indexmanager.CreateIndexFromHeap(table, index.Name, options, table, keyOf); err != nil {
+		t.Fatalf("failed to create index %s: %s", index.Name, err)
+	}
+	if err := schemamanager.AddIndex(table, index); err != nil {
+		t.Fatalf("failed to add index %s to the schema: %s", index.Name, err)
+	}
+}
+
+// returns the rids of the rows whose first column of the index has the value
+func lookup(t *testing.T, table string, index string, dataType types.DataType, value any) []heapmanager.RID {
+	t.Helper()
+
+	key, err := indexmanager.TypedIndexKey([]types.DataType{dataType}, []any{value}, nil)
+	if err != nil {
+		t.Fatalf("failed to encode the key %v: %s", value, err)
+	}
+	rids, err := indexmanager.FindIndexEntry(table, index, key)
+	if err != nil {
+		t.Fatalf("failed to look up %v in %s: %s", value, index, err)
+	}
+	return rids
+}
+
+func checkLookup(t *testing.T, table string, index string, dataType types.DataType, value any, expected ...heapmanager.RID) {
+	t.Helper()
+
+	if rids := lookup(t, table, index, dataType, value); !slices.Equal(rids, expected) {
+		t.Fatalf("%v is in index %s with the rows %v, expected %v", value, index, rids, expected)
+	}
+}
+
+// returns the version of the index, it grows with every build of the index
+func indexVersion(t *testing.T, table string, index string) uint32 {
+	t.Helper()
+
+	indexes, err := indexmanager.GetIndexesMetadata(table)
+	if err != nil {
+		t.Fatalf("failed to read the indexes of %s: %s", table, err)
+	}
+	for _, metadata := range indexes {
+		if metadata.Name == index {
+			return metadata.Version
+		}
+	}
+	t.Fatalf("index %s of %s does not exist", index, table)
+	return 0
+}
+
+// checks that no build of an index of the table was left next to the indexes
+func checkNoBuilds(t *testing.T, table string) {
+	t.Helper()
+
+	builds, err := filepath.Glob(path.Join("indexes", table, "*.rebuild-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(builds) > 0 {
+		t.Fatalf("the builds %v were left in the indexes of %s", builds, table)
+	}
+}
+
+func dataType(t *testing.T, table string, column string) string {
+	t.Helper()
+
+	for _, c := range getTable(t, table).Columns {
+		if c.Name == column {
+			return c.DataType
+		}
+	}
+	t.Fatalf("column %s of %s does not exist", column, table)
+	return ""
+}
+
+func TestAlterColumnType(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name: "alter",
+		Columns: []schemamanager.Column{
+			{Name: "id", DataType: "int", PrimaryKey: true},
+			{Name: "code", DataType: "string", Unique: true},
+			{Name: "amount", DataType: "int"},
+		},
+	})
+	first := insert(t, "alter", []string{"id", "code", "amount"}, 1, "10", 5)
+	second := insert(t, "alter", []string{"id", "code", "amount"}, 2, "20", 6)
+	third := insert(t, "alter", []string{"id", "amount"}, 3, 7)
+	version := indexVersion(t, "alter", "alter_code_key")
+
+	if err := schemamanager.AlterColumnType("alter", "amount", "timestamp"); err == nil {
+		t.Fatal("the change of an int to a timestamp must be refused")
+	}
+	if err := schemamanager.AlterColumnType("alter", "missing", "int"); err == nil {
+		t.Fatal("the change of a column that does not exist must be refused")
+	}
+
+	// the values are converted and the index of the column is built again with the converted keys
+	if err := schemamanager.AlterColumnType("alter", "code", "int"); err != nil {
+		t.Fatalf("failed to change the type of the column: %s", err)
+	}
+	if dataType(t, "alter", "code") != "int" {
+		t.Fatalf("the column has the type %s after the change", dataType(t, "alter", "code"))
+	}
+	checkRow(t, "alter", first, int64(1), int64(10), int64(5))
+	checkRow(t, "alter", second, int64(2), int64(20), int64(6))
+	checkRow(t, "alter", third, int64(3), nil, int64(7))
+
+	// the commit replaced the index file with the build
+	checkLookup(t, "alter", "alter_code_key", types.Int, int64(10), first)
+	checkLookup(t, "alter", "alter_code_key", types.Int, int64(20), second)
+	checkLookup(t, "alter", "alter_code_key", types.String, "10")
+	if v := indexVersion(t, "alter", "alter_code_key"); v != version+1 {
+		t.Fatalf("the index has version %d after the change, expected %d", v, version+1)
+	}
+	checkNoBuilds(t, "alter")
+
+	// the new index enforces the constraint
+	_, err := tablemanager.InsertRow("alter", []string{"id", "code"}, []tablemanager.Value{4, 20})
+	checkViolation(t, err, dberrors.Unique, "code")
+	if _, err := tablemanager.InsertRow("alter", []string{"id", "code"}, []tablemanager.Value{4, "30"}); err == nil {
+		t.Fatal("a string was inserted into the int column")
+	}
+}
+
+// a change that fails leaves the rows, the schema and the indexes as they were
+func TestAlterColumnTypeFailure(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name: "alterfail",
+		Columns: []schemamanager.Column{
+			{Name: "id", DataType: "int", PrimaryKey: true},
+			{Name: "code", DataType: "string", Unique: true},
+		},
+	})
+	first := insert(t, "alterfail", []string{"id", "code"}, 1, "1")
+	second := insert(t, "alterfail", []string{"id", "code"}, 2, "01")
+	createTestIndex(t, "alterfail", schemamanager.Index{Name: "alterfail_code_id", ColumnNames: []string{"code", "id"}})
+	version := indexVersion(t, "alterfail", "alterfail_code_key")
+
+	checkUnchanged := func() {
+		t.Helper()
+
+		if dataType(t, "alterfail", "code") != "string" {
+			t.Fatalf("the failed change left the type %s", dataType(t, "alterfail", "code"))
+		}
+		checkRow(t, "alterfail", first, int64(1), "1")
+		checkRow(t, "alterfail", second, int64(2), "01")
+		checkLookup(t, "alterfail", "alterfail_code_key", types.String, "01", second)
+		checkLookup(t, "alterfail", "alterfail_code_id", types.String, "1", first)
+		if v := indexVersion(t, "alterfail", "alterfail_code_key"); v != version {
+			t.Fatalf("the index has version %d after the failed change, expected %d", v, version)
+		}
+	}
+
+	// "1" and "01" are both 1
+	err := schemamanager.AlterColumnType("alterfail", "code", "int")
+	checkViolation(t, err, dberrors.Unique, "code")
+	checkUnchanged()
+
+	// the unique index is built, then the build of the second index fails
+	schemamanager.RegisterIndexKeyFunc(func(table schemamanager.Table, index schemamanager.Index) (indexmanager.KeyFunc, error) {
+		if index.Name == "alterfail_code_id" {
+			return func(row []byte) (indexmanager.IndexKey, error) {
+				return nil, errors.New("the key cannot be read")
+			}, nil
+		}
+		return rowcodec.IndexKeyFunc(table, index)
+	})
+	defer schemamanager.RegisterIndexKeyFunc(rowcodec.IndexKeyFunc)
+
+	if err := tablemanager.UpdateRow("alterfail", second, []string{"code"}, []tablemanager.Value{"2"}); err != nil {
+		t.Fatalf("failed to update the row: %s", err)
+	}
+	if err := schemamanager.AlterColumnType("alterfail", "code", "float"); err == nil {
+		t.Fatal("the change must fail when an index cannot be built")
+	}
+	checkRow(t, "alterfail", second, int64(2), "2")
+	if err := tablemanager.UpdateRow("alterfail", second, []string{"code"}, []tablemanager.Value{"01"}); err != nil {
+		t.Fatalf("failed to update the row: %s", err)
+	}
+	checkUnchanged()
+
+	// the file of the build that was rolled back is removed by the next build
+	schemamanager.RegisterIndexKeyFunc(rowcodec.IndexKeyFunc)
+	if err := schemamanager.AlterColumnType("alterfail", "code", "float"); err == nil {
+		t.Fatal("the change of \"01\" and \"1\" to float must fail on the unique index")
+	}
+	if err := tablemanager.UpdateRow("alterfail", second, []string{"code"}, []tablemanager.Value{"2"}); err != nil {
+		t.Fatalf("failed to update the row: %s", err)
+	}
+	if err := schemamanager.AlterColumnType("alterfail", "code", "int"); err != nil {
+		t.Fatalf("failed to change the type of the column: %s", err)
+	}
+	checkLookup(t, "alterfail", "alterfail_code_key", types.Int, int64(2), second)
+	checkLookup(t, "alterfail", "alterfail_code_id", types.Int, int64(1), first)
+	if v := indexVersion(t, "alterfail", "alterfail_code_key"); v != version+1 {
+		t.Fatalf("the index has version %d after the change, expected %d", v, version+1)
+	}
+	checkNoBuilds(t, "alterfail")
+}
+
+func TestDropTable(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name:    "parent",
+		Columns: []schemamanager.Column{{Name: "id", DataType: "int", PrimaryKey: true}},
+	})
+	createTestTable(t, schemamanager.Table{
+		Name:    "child",
+		Columns: []schemamanager.Column{{Name: "id", DataType: "int"}, {Name: "parent", DataType: "int"}},
+		ForeignKeys: []schemamanager.ForeignKey{
+			{Name: "child_parent_fkey", Columns: []string{"parent"}, RefTable: "parent", RefColumns: []string{"id"}},
+		},
+	})
+	insert(t, "parent", []string{"id"}, 1)
+	insert(t, "child", []string{"id", "parent"}, 1, 1)
+
+	if err := schemamanager.DropTable("parent"); err == nil {
+		t.Fatal("a table referenced by a foreign key must not be dropped")
+	}
+	if err := schemamanager.DropTable("missing"); err == nil {
+		t.Fatal("dropping a table that does not exist must fail")
+	}
+
+	for _, table := range []string{"child", "parent"} {
+		if err := schemamanager.DropTable(table); err != nil {
+			t.Fatalf("failed to drop %s: %s", table, err)
+		}
+		if _, err := os.Stat(table); !os.IsNotExist(err) {
+			t.Fatalf("the heap of %s is still there: %v", table, err)
+		}
+		if _, err := os.Stat(path.Join("indexes", table)); !os.IsNotExist(err) {
+			t.Fatalf("the indexes of %s are still there: %v", table, err)
+		}
+	}
+
+	tables, err := schemamanager.GetTables()
+	if err != nil {
+		t.Fatalf("failed to read the schema: %s", err)
+	}
+	for _, table := range tables {
+		if table.Name == "parent" || table.Name == "child" {
+			t.Fatalf("table %s is still in the schema", table.Name)
+		}
+	}
+}
+
+func TestDropColumn(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name: "dropcolumn",
+		Columns: []schemamanager.Column{
+			{Name: "id", DataType: "int", PrimaryKey: true},
+			{Name: "note", DataType: "string"},
+			{Name: "email", DataType: "string", Unique: true},
+			{Name: "low", DataType: "int"},
+			{Name: "high", DataType: "int", Check: "high > low"},
+		},
+	})
+	first := insert(t, "dropcolumn", []string{"id", "note", "email", "low", "high"}, 1, "a note", "a@x", 1, 2)
+	second := insert(t, "dropcolumn", []string{"id", "email"}, 2, "b@x")
+
+	if err := schemamanager.DropColumn("dropcolumn", "email"); err == nil {
+		t.Fatal("a column used by an index must not be dropped")
+	}
+	if err := schemamanager.DropColumn("dropcolumn", "low"); err == nil {
+		t.Fatal("a column used by the check of another column must not be dropped")
+	}
+	if err := schemamanager.DropColumn("dropcolumn", "missing"); err == nil {
+		t.Fatal("dropping a column that does not exist must fail")
+	}
+
+	// the string after the dropped one is read from the rewritten rows
+	if err := schemamanager.DropColumn("dropcolumn", "note"); err != nil {
+		t.Fatalf("failed to drop the column: %s", err)
+	}
+	if names := columnNames(t, "dropcolumn"); !slices.Equal(names, []string{"id", "email", "low", "high"}) {
+		t.Fatalf("the table has the columns %v", names)
+	}
+	checkRow(t, "dropcolumn", first, int64(1), "a@x", int64(1), int64(2))
+	checkRow(t, "dropcolumn", second, int64(2), "b@x", nil, nil)
+
+	// the check goes with its column
+	if err := schemamanager.DropColumn("dropcolumn", "high"); err != nil {
+		t.Fatalf("failed to drop the column with a check: %s", err)
+	}
+	checkRow(t, "dropcolumn", first, int64(1), "a@x", int64(1))
+	insert(t, "dropcolumn", []string{"id", "email", "low"}, 3, "c@x", 5)
+
+	// the indexes still have the rows
+	checkLookup(t, "dropcolumn", "dropcolumn_email_key", types.String, "b@x", second)
+	_, err := tablemanager.InsertRow("dropcolumn", []string{"id", "email"}, []tablemanager.Value{4, "a@x"})
+	checkViolation(t, err, dberrors.Unique, "email")
+}
+
+func TestDropIndex(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name: "dropindex",
+		Columns: []schemamanager.Column{
+			{Name: "id", DataType: "int", PrimaryKey: true},
+			{Name: "name", DataType: "string"},
+		},
+	})
+	rid := insert(t, "dropindex", []string{"id", "name"}, 1, "ann")
+	createTestIndex(t, "dropindex", schemamanager.Index{Name: "dropindex_name", ColumnName: "name"})
+	checkLookup(t, "dropindex", "dropindex_name", types.String, "ann", rid)
+
+	if err := schemamanager.DropIndex("dropindex", "dropindex_pkey"); err == nil {
+		t.Fatal("the index of the primary key must not be dropped")
+	}
+	if err := schemamanager.DropIndex("dropindex", "missing"); err == nil {
+		t.Fatal("dropping an index that does not exist must fail")
+	}
+
+	if err := schemamanager.DropIndex("dropindex", "dropindex_name"); err != nil {
+		t.Fatalf("failed to drop the index: %s", err)
+	}
+	if names := indexNames(t, "dropindex"); !slices.Equal(names, []string{"dropindex_pkey"}) {
+		t.Fatalf("the table has the indexes %v in the index manager", names)
+	}
+	if indexes := getTable(t, "dropindex").Indexes; len(indexes) != 1 || indexes[0].Name != "dropindex_pkey" {
+		t.Fatalf("the table has the indexes %v in the schema", indexes)
+	}
+	if _, err := os.Stat(path.Join("indexes", "dropindex", "dropindex_name.data")); !os.IsNotExist(err) {
+		t.Fatalf("the file of the index is still there: %v", err)
+	}
+
+	// the rows are written without the dropped index
+	second := insert(t, "dropindex", []string{"id", "name"}, 2, "bob")
+	checkRow(t, "dropindex", second, int64(2), "bob")
+}
+
+func TestRenameTable(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name:    "before",
+		Columns: []schemamanager.Column{{Name: "id", DataType: "int", PrimaryKey: true}},
+	})
+	createTestTable(t, schemamanager.Table{
+		Name:    "refs",
+		Columns: []schemamanager.Column{{Name: "before", DataType: "int"}},
+		ForeignKeys: []schemamanager.ForeignKey{
+			{Name: "refs_before_fkey", Columns: []string{"before"}, RefTable: "before", RefColumns: []string{"id"}},
+		},
+	})
+	createTestTable(t, schemamanager.Table{
+		Name:    "taken",
+		Columns: []schemamanager.Column{{Name: "id", DataType: "int"}},
+	})
+	rid := insert(t, "before", []string{"id"}, 1)
+	insert(t, "refs", []string{"before"}, 1)
+
+	if err := schemamanager.RenameTable("before", "taken"); err == nil {
+		t.Fatal("a table must not be renamed to the name of another table")
+	}
+	if err := schemamanager.RenameTable("missing", "other"); err == nil {
+		t.Fatal("renaming a table that does not exist must fail")
+	}
+
+	if err := schemamanager.RenameTable("before", "after"); err != nil {
+		t.Fatalf("failed to rename the table: %s", err)
+	}
+	checkRow(t, "after", rid, int64(1))
+	if _, err := os.Stat("before"); !os.IsNotExist(err) {
+		t.Fatalf("the heap kept its old name: %v", err)
+	}
+	// the indexes keep their names in the directory of the new table
+	checkLookup(t, "after", "before_pkey", types.Int, int64(1), rid)
+	_, err := tablemanager.InsertRow("after", []string{"id"}, []tablemanager.Value{1})
+	checkViolation(t, err, dberrors.PrimaryKey, "id")
+
+	// the foreign key references the new name
+	if fks := getTable(t, "refs").ForeignKeys; fks[0].RefTable != "after" {
+		t.Fatalf("the foreign key references %s", fks[0].RefTable)
+	}
+	insert(t, "refs", []string{"before"}, 1)
+	_, err = tablemanager.InsertRow("refs", []string{"before"}, []tablemanager.Value{2})
+	checkViolation(t, err, dberrors.ForeignKey, "before")
+}
+
+func TestRenameColumn(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name: "renamecolumn",
+		Columns: []schemamanager.Column{
+			{Name: "id", DataType: "int", PrimaryKey: true},
+			{Name: "mail", DataType: "string", Unique: true},
+			{Name: "price", DataType: "float", Check: "price > 0"},
+		},
+	})
+	rid := insert(t, "renamecolumn", []string{"id", "mail", "price"}, 1, "a@x", 2.5)
+
+	if err := schemamanager.RenameColumn("renamecolumn", "mail", "price"); err == nil {
+		t.Fatal("a column must not be renamed to the name of another column")
+	}
+	if err := schemamanager.RenameColumn("renamecolumn", "missing", "other"); err == nil {
+		t.Fatal("renaming a column that does not exist must fail")
+	}
+
+	if err := schemamanager.RenameColumn("renamecolumn", "mail", "email"); err != nil {
+		t.Fatalf("failed to rename the column: %s", err)
+	}
+	if err := schemamanager.RenameColumn("renamecolumn", "price", "cost"); err != nil {
+		t.Fatalf("failed to rename the column with a check: %s", err)
+	}
+	if names := columnNames(t, "renamecolumn"); !slices.Equal(names, []string{"id", "email", "cost"}) {
+		t.Fatalf("the table has the columns %v", names)
+	}
+	checkRow(t, "renamecolumn", rid, int64(1), "a@x", 2.5)
+
+	// the index of the constraint keeps its name and uses the new name of the column
+	table := getTable(t, "renamecolumn")
+	index := table.Indexes[slices.IndexFunc(table.Indexes, func(index schemamanager.Index) bool { return index.Name == "renamecolumn_mail_key" })]
+	if !slices.Equal(index.Columns(), []string{"email"}) {
+		t.Fatalf("the index of the renamed column has the columns %v", index.Columns())
+	}
+	_, err := tablemanager.InsertRow("renamecolumn", []string{"id", "email", "cost"}, []tablemanager.Value{2, "a@x", 1.0})
+	checkViolation(t, err, dberrors.Unique, "email")
+
+	// the check uses the new name
+	if table.Columns[2].Check != "cost > 0" {
+		t.Fatalf("the check of the renamed column is %q", table.Columns[2].Check)
+	}
+	_, err = tablemanager.InsertRow("renamecolumn", []string{"id", "email", "cost"}, []tablemanager.Value{3, "c@x", -1.0})
+	checkViolation(t, err, dberrors.Check, "cost")
+}
@@ -0,0 +1,234 @@
+package schemamanager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/indexmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/keycodec"
+)
+
+//a foreign key makes the Columns of a table reference the RefColumns of the table RefTable (the parent table):
+//the values of the columns of a row must be the key of a row of the parent table, unless one of them is null.
+//RefColumns are the primary key of the parent table or one of its UNIQUE columns so the row is found with their index,
+//and they have the data types of the Columns. a table can reference itself.
+//OnDelete is what happens to the rows that reference a row of the parent table when it is deleted,
+//the foreign key has an index on its Columns with its name to find them.
+//a row of the parent table cannot change its key while rows reference it
+type ForeignKey struct{
+	Name string `json:"name"`
+	Columns []string `json:"columns"`
+	RefTable string `json:"refTable"`
+	RefColumns []string `json:"refColumns"`
+	OnDelete ReferentialAction `json:"onDelete,omitempty"`
+}
+
+//ReferentialAction is what happens to the rows that reference a deleted row, RESTRICT when it is empty
+type ReferentialAction string
+
+const (
+	//the row cannot be deleted while rows reference it
+	Restrict ReferentialAction = "RESTRICT"
+	//the rows that reference it are deleted with it
+	Cascade ReferentialAction = "CASCADE"
+	//the columns of the rows that reference it are set to null
+	SetNull ReferentialAction = "SET NULL"
+)
+
+//Reference is a foreign key with the table that has it
+type Reference struct{
+	Table Table
+	ForeignKey ForeignKey
+}
+
+//ForeignKeyName returns the default name of the foreign key of the table on the columns
+func ForeignKeyName(table string, columns []string) string {
+	return table + "_" + strings.Join(columns, "_") + "_fkey"
+}
+
+//ReferencesTo returns the foreign keys of the tables that reference the table, the table itself included
+func ReferencesTo(tables []Table, table string) []Reference {
+	references := make([]Reference, 0)
+	for _, t := range tables {
+		for _, fk := range t.ForeignKeys {
+			if fk.RefTable == table {
+				references = append(references, Reference{Table: t, ForeignKey: fk})
+			}
+		}
+	}
+	return references
+}
+
+//KeyIndex returns the unique index of the primary key or the UNIQUE column that is on the columns
+func (table Table) KeyIndex(columns []string) (Index, bool) {
+	for _, index := range table.Indexes {
+		constraint := table.IndexConstraint(index.Name)
+		if (constraint == dberrors.PrimaryKey || constraint == dberrors.Unique) && slices.Equal(index.Columns(), columns) {
+			return index, true
+		}
+	}
+	return Index{}, false
+}
+
+//AddForeignKey adds the foreign key to the table with its index, the rows already in the table must reference rows of the parent table
+func AddForeignKey(table string, fk ForeignKey) error {
+	schema, err := readSchema()
+	if err != nil {
+		return err
+	}
+
+	tableIndex := findTable(schema, table)
+	if tableIndex == -1 {
+		return fmt.Errorf("TABLE NOT FOUND")
+	}
+	t := schema.Tables[tableIndex]
+
+	if err := validateForeignKey(schema, t, &fk); err != nil {
+		return err
+	}
+	parent := t
+	if fk.RefTable != table {
+		parent = schema.Tables[findTable(schema, fk.RefTable)]
+	}
+	if err := checkReferences(t, fk, parent); err != nil {
+		return err
+	}
+
+	index := foreignKeyIndex(fk)
+	if err := createIndexes(t, []Index{index}); err != nil {
+		return err
+	}
+
+	schema.Tables[tableIndex].ForeignKeys = append(t.ForeignKeys, fk)
+	schema.Tables[tableIndex].Indexes = append(t.Indexes, index)
+	return writeSchema(schema)
+}
+
+//DropForeignKey removes the foreign key from the table with its index
+func DropForeignKey(table string, name string) error {
+	schema, err := readSchema()
+	if err != nil {
+		return err
+	}
+
+	tableIndex := findTable(schema, table)
+	if tableIndex == -1 {
+		return fmt.Errorf("TABLE NOT FOUND")
+	}
+	t := &schema.Tables[tableIndex]
+
+	fkIndex := slices.IndexFunc(t.ForeignKeys, func(fk ForeignKey) bool { return fk.Name == name })
+	if fkIndex == -1 {
+		return fmt.Errorf("FOREIGN KEY NOT FOUND")
+	}
+
+	if err := indexmanager.DeleteIndex(table, name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	t.ForeignKeys = slices.Delete(t.ForeignKeys, fkIndex, fkIndex+1)
+	if i := findIndex(*t, name); i != -1 {
+		t.Indexes = slices.Delete(t.Indexes, i, i+1)
+	}
+	return writeSchema(schema)
+}
+
+//returns the index of the foreign key, it finds the rows that reference a row of the parent table
+func foreignKeyIndex(fk ForeignKey) Index {
+	return Index{Name: fk.Name, ColumnNames: fk.Columns}
+}
+
+//checks the foreign key of the table and gives it its default name and action,
+//the parent table is the table itself or a table of the schema
+func validateForeignKey(schema Schema, table Table, fk *ForeignKey) error {
+	if len(fk.Columns) == 0 || len(fk.Columns) != len(fk.RefColumns) {
+		return fmt.Errorf("FOREIGN KEY MUST HAVE AS MANY COLUMNS AS REFERENCED COLUMNS")
+	}
+	if fk.Name == "" {
+		fk.Name = ForeignKeyName(table.Name, fk.Columns)
+	}
+	if fk.OnDelete == "" {
+		fk.OnDelete = Restrict
+	}
+	if fk.OnDelete != Restrict && fk.OnDelete != Cascade && fk.OnDelete != SetNull {
+		return fmt.Errorf("UNKNOWN ON DELETE ACTION %s", fk.OnDelete)
+	}
+	if findIndex(table, fk.Name) != -1 || slices.ContainsFunc(table.ForeignKeys, func(other ForeignKey) bool { return other.Name == fk.Name }) {
+		return fmt.Errorf("FOREIGN KEY %s ALREADY EXISTS", fk.Name)
+	}
+
+	parent := table
+	if fk.RefTable != table.Name {
+		i := findTable(schema, fk.RefTable)
+		if i == -1 {
+			return fmt.Errorf("REFERENCED TABLE NOT FOUND")
+		}
+		parent = schema.Tables[i]
+	}
+	if _, ok := parent.KeyIndex(fk.RefColumns); !ok {
+		return fmt.Errorf("COLUMNS %s OF TABLE %s ARE NOT ITS PRIMARY KEY OR A UNIQUE COLUMN", strings.Join(fk.RefColumns, ", "), parent.Name)
+	}
+
+	for i, name := range fk.Columns {
+		c := findColumn(table, name)
+		if c == -1 {
+			return fmt.Errorf("COLUMN NOT FOUND")
+		}
+		column := table.Columns[c]
+		refColumn := parent.Columns[findColumn(parent, fk.RefColumns[i])]
+		if column.DataType != refColumn.DataType {
+			return fmt.Errorf("COLUMN %s IS %s BUT %s OF TABLE %s IS %s", name, column.DataType, refColumn.Name, parent.Name, refColumn.DataType)
+		}
+		if fk.OnDelete == SetNull && !column.Nullable() {
+			return fmt.Errorf("COLUMN %s CANNOT BE SET TO NULL", name)
+		}
+	}
+	return nil
+}
+
+//checks that the rows of the table reference rows of the parent table
+func checkReferences(table Table, fk ForeignKey, parent Table) error {
+	if _, err := os.Stat(table.Name); os.IsNotExist(err) {
+		return nil
+	}
+	if indexKeyFunc == nil {
+		return errNoIndexKeyFunc
+	}
+
+	keyOf, err := indexKeyFunc(table, foreignKeyIndex(fk))
+	if err != nil {
+		return err
+	}
+	parentIndex, _ := parent.KeyIndex(fk.RefColumns)
+
+	scanner, err := heapmanager.OpenHeapScanner(table.Name)
+	if err != nil {
+		return err
+	}
+	defer scanner.Close()
+
+	for scanner.Next() {
+		key, err := keyOf(scanner.Row())
+		if err != nil {
+			return err
+		}
+		if slices.ContainsFunc(key, func(value []byte) bool { return keycodec.IsNull(value, keycodec.Ascending) }) {
+			continue
+		}
+
+		entries, err := indexmanager.FindIndexEntries(parent.Name, parentIndex.Name, key)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return &dberrors.ConstraintViolationError{Constraint: dberrors.ForeignKey, Table: table.Name, Columns: fk.Columns, Name: fk.Name}
+		}
+	}
+	return scanner.Err()
+}
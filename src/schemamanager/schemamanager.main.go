@@ -9,24 +9,51 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"slices"
+
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
 )
 
 
 
+//the constraints of a column are checked when a row is written (see schemamanager.constraints.go)
+//Default is the value of the column when a row does not give one, written like a string literal of types.Convert
+//Check is an expression of checkexpr on the columns of the table
  type  Column struct{
 	Name string `json:"name"`
 	DataType string `json:"dataType"`
+	NotNull bool `json:"notNull,omitempty"`
+	Default *string `json:"default,omitempty"`
+	PrimaryKey bool `json:"primaryKey,omitempty"`
+	Unique bool `json:"unique,omitempty"`
+	Check string `json:"check,omitempty"`
 }
 
+//an index is on ColumnNames in order (a composite index has more than one column)
+//ColumnName is the column of the indexes saved before composite indexes and it is kept for them
+//IncludeColumns are stored in the entries of the index (a covering index) so they can be read without the heap
 type Index struct{
 	Name string `json:"name"`
-	ColumnName string `json:"columnName"`
+	ColumnName string `json:"columnName,omitempty"`
+	ColumnNames []string `json:"columnNames,omitempty"`
+	IncludeColumns []string `json:"includeColumns,omitempty"`
+	Unique bool `json:"unique,omitempty"`
+}
+
+//returns the columns of the index in order
+func (index Index) Columns() []string {
+	if len(index.ColumnNames) > 0 {
+		return index.ColumnNames
+	}
+	return []string{index.ColumnName}
 }
 
 type Table struct{
 	Name string `json:"name"`
 	Columns []Column `json:"columns"`
 	Indexes []Index `json:"indexes"`
+	ForeignKeys []ForeignKey `json:"foreignKeys,omitempty"`
 }
 
 
@@ -117,6 +144,34 @@ func AddTable (table Table) error {
 		}
 	}
 
+	if err := validateColumns(table); err != nil {
+		return err
+	}
+
+	//the keys of the table get their unique indexes before the table is in the schema,
+	//and its foreign keys the indexes that find the rows that reference a row
+	indexes := constraintIndexes(table, table.Columns)
+	table.ForeignKeys = slices.Clone(table.ForeignKeys)
+	for i := range table.ForeignKeys {
+		//the foreign key is checked with the indexes of the keys of the table, so it can reference the table itself
+		withIndexes := table
+		withIndexes.Indexes = append(slices.Clone(table.Indexes), indexes...)
+		withIndexes.ForeignKeys = table.ForeignKeys[:i]
+		if err := validateForeignKey(schema, withIndexes, &table.ForeignKeys[i]); err != nil {
+			return err
+		}
+		indexes = append(indexes, foreignKeyIndex(table.ForeignKeys[i]))
+	}
+	for _, index := range indexes {
+		if findIndex(table, index.Name) != -1 {
+			return fmt.Errorf("INDEX ALREADY EXISTS")
+		}
+	}
+	if err := createIndexes(table, indexes); err != nil {
+		return err
+	}
+	table.Indexes = append(slices.Clone(table.Indexes), indexes...)
+
 	//append the table to the tables array
 	schema.Tables = append(schema.Tables, table)
 
@@ -177,24 +232,69 @@ func AddColumn (table string , column Column) error {
 		}
 	}
 
-	//append the column to the columns array
-	schema.Tables[tableIndex].Columns = append(schema.Tables[tableIndex].Columns, column)
-	
-	//open the file with truncating the content of the file
-	file, err = openSchemaFileWithTruncate()
+	oldTable := schema.Tables[tableIndex]
+	newTable := oldTable
+	newTable.Columns = append(slices.Clone(oldTable.Columns), column)
 
-	if err != nil {
+	if err := validateColumns(newTable); err != nil {
 		return err
 	}
+	if column.PrimaryKey && len(oldTable.PrimaryKey()) > 0 {
+		return fmt.Errorf("TABLE ALREADY HAS A PRIMARY KEY")
+	}
+	indexes := constraintIndexes(newTable, []Column{column})
+	for _, index := range indexes {
+		if findIndex(oldTable, index.Name) != -1 {
+			return fmt.Errorf("INDEX ALREADY EXISTS")
+		}
+	}
 
-	//write the schema back to the file with overriding the whole file content
-	encoder := json.NewEncoder(file)
+	newTable.Indexes = append(slices.Clone(oldTable.Indexes), indexes...)
 
-	err = encoder.Encode(schema)
+	//replace the table with the one with the column
+	schema.Tables[tableIndex] = newTable
+
+	//the rows are checked with the constraints of the column before anything is written, then the rows,
+	//the new indexes and the schema are written in one transaction, so a failure or a crash leaves either
+	//the old table or the new one
+	tx, err := logmanager.BeginAfterCheckpoint()
 	if err != nil {
-		return fmt.Errorf("%v", err)
+		return err
 	}
-	return nil
+	if err := tx.Finish(addColumnTx(tx, oldTable, newTable, column, indexes, schema)); err != nil {
+		return err
+	}
+
+	//the other writes of the schema are not logged, see AlterColumnType
+	return logmanager.Checkpoint()
+}
+
+//checks the rows of the table with the constraints of the new column, then writes the rows with its default,
+//the indexes of its keys and the schema in tx
+func addColumnTx(tx *logmanager.Transaction, oldTable Table, newTable Table, column Column, indexes []Index, schema Schema) error {
+	//the rows already in the table get the default of the column, they are read as null without one
+	if column.Default != nil || !column.Nullable() || len(indexes) > 0 {
+		rows, err := convertRows(oldTable, newTable)
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 && !column.Nullable() && column.Default == nil {
+			return &dberrors.ConstraintViolationError{Constraint: dberrors.NotNull, Table: newTable.Name, Columns: []string{column.Name}}
+		}
+		if err := checkUniqueIndexes(newTable, indexes, rows); err != nil {
+			return err
+		}
+		if column.Default != nil {
+			if err := writeRowsTx(tx, oldTable.Name, rows); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := createIndexesTx(tx, newTable, indexes); err != nil {
+		return err
+	}
+	return writeSchemaTx(tx, schema)
 }
 
 
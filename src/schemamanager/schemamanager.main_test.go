@@ -0,0 +1,173 @@
+package schemamanager_test
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/indexmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/schemamanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/tablemanager"
+)
+
+// the tests are outside the package, the rows of the tables are written by the table manager
+// which registers the hooks of the rowcodec package.
+// the schema, the heaps and the indexes are created in the working directory, the tests run in a temporary one
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "schemamanager")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	if err := os.Mkdir("schemamanager", 0755); err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile("schemamanager/schema.json", []byte(`{"tables":[]}`+"\n"), 0644); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// creates the table with its heap, the test fails if it cannot
+func createTestTable(t *testing.T, table schemamanager.Table) {
+	t.Helper()
+
+	if err := heapmanager.CreateHeap(table.Name); err != nil {
+		t.Fatalf("failed to create the heap of %s: %s", table.Name, err)
+	}
+	if err := schemamanager.AddTable(table); err != nil {
+		t.Fatalf("failed to add table %s: %s", table.Name, err)
+	}
+}
+
+func insert(t *testing.T, table string, columns []string, values ...tablemanager.Value) heapmanager.RID {
+	t.Helper()
+
+	rid, err := tablemanager.InsertRow(table, columns, values)
+	if err != nil {
+		t.Fatalf("failed to insert %v into %s: %s", values, table, err)
+	}
+	return rid
+}
+
+// returns the table of the schema, the test fails if it is not there
+func getTable(t *testing.T, name string) schemamanager.Table {
+	t.Helper()
+
+	tables, err := schemamanager.GetTables()
+	if err != nil {
+		t.Fatalf("failed to read the schema: %s", err)
+	}
+	for _, table := range tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	t.Fatalf("table %s is not in the schema", name)
+	return schemamanager.Table{}
+}
+
+// returns the names of the columns of the table in the schema
+func columnNames(t *testing.T, name string) []string {
+	t.Helper()
+
+	names := make([]string, 0)
+	for _, column := range getTable(t, name).Columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+// returns the names of the indexes of the table in the index manager
+func indexNames(t *testing.T, table string) []string {
+	t.Helper()
+
+	indexes, err := indexmanager.GetIndexesMetadata(table)
+	if err != nil {
+		t.Fatalf("failed to read the indexes of %s: %s", table, err)
+	}
+	names := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		names = append(names, index.Name)
+	}
+	return names
+}
+
+// checks the values of the row
+func checkRow(t *testing.T, table string, rid heapmanager.RID, expected ...tablemanager.Value) {
+	t.Helper()
+
+	row, err := tablemanager.GetRow(table, rid)
+	if err != nil {
+		t.Fatalf("failed to read row %v of %s: %s", rid, table, err)
+	}
+	if !slices.Equal(row, expected) {
+		t.Fatalf("row %v of %s is %v, expected %v", rid, table, row, expected)
+	}
+}
+
+// checks that err is a violation of the constraint on the columns
+func checkViolation(t *testing.T, err error, constraint dberrors.ConstraintType, columns ...string) {
+	t.Helper()
+
+	var violation *dberrors.ConstraintViolationError
+	if !errors.As(err, &violation) {
+		t.Fatalf("expected a violation of %s, got %v", constraint, err)
+	}
+	if violation.Constraint != constraint || !slices.Equal(violation.Columns, columns) {
+		t.Fatalf("expected a violation of %s on %v, got %s", constraint, columns, violation)
+	}
+}
+
+func TestAddColumn(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name:    "addcolumn",
+		Columns: []schemamanager.Column{{Name: "id", DataType: "int", PrimaryKey: true}},
+	})
+	first := insert(t, "addcolumn", []string{"id"}, 1)
+	second := insert(t, "addcolumn", []string{"id"}, 2)
+
+	// the constraints are checked with the rows before anything is written
+	x := "x"
+	err := schemamanager.AddColumn("addcolumn", schemamanager.Column{Name: "code", DataType: "string", Unique: true, Default: &x})
+	checkViolation(t, err, dberrors.Unique, "code")
+	err = schemamanager.AddColumn("addcolumn", schemamanager.Column{Name: "count", DataType: "int", NotNull: true})
+	checkViolation(t, err, dberrors.NotNull, "count")
+	if names := columnNames(t, "addcolumn"); !slices.Equal(names, []string{"id"}) {
+		t.Fatalf("the failed changes left the columns %v", names)
+	}
+	if names := indexNames(t, "addcolumn"); !slices.Equal(names, []string{"addcolumn_pkey"}) {
+		t.Fatalf("the failed changes left the indexes %v", names)
+	}
+	checkRow(t, "addcolumn", first, int64(1))
+
+	// the rows get the default of the column
+	seven := "7"
+	if err := schemamanager.AddColumn("addcolumn", schemamanager.Column{Name: "count", DataType: "int", NotNull: true, Default: &seven}); err != nil {
+		t.Fatalf("failed to add a column with a default: %s", err)
+	}
+	checkRow(t, "addcolumn", first, int64(1), int64(7))
+	checkRow(t, "addcolumn", second, int64(2), int64(7))
+
+	// the rows are null in the new unique column, its index has their entries
+	if err := schemamanager.AddColumn("addcolumn", schemamanager.Column{Name: "email", DataType: "string", Unique: true}); err != nil {
+		t.Fatalf("failed to add a unique column: %s", err)
+	}
+	if names := indexNames(t, "addcolumn"); !slices.Equal(names, []string{"addcolumn_pkey", "addcolumn_email_key"}) {
+		t.Fatalf("the table has the indexes %v", names)
+	}
+	if err := tablemanager.UpdateRow("addcolumn", first, []string{"email"}, []tablemanager.Value{"a@x"}); err != nil {
+		t.Fatalf("failed to update the new column: %s", err)
+	}
+	err = tablemanager.UpdateRow("addcolumn", second, []string{"email"}, []tablemanager.Value{"a@x"})
+	checkViolation(t, err, dberrors.Unique, "email")
+	checkRow(t, "addcolumn", second, int64(2), int64(7), nil)
+}
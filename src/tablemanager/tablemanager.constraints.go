@@ -0,0 +1,78 @@
+package tablemanager
+
+import (
+	"fmt"
+
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/indexmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/types"
+)
+
+// checks the constraints of the columns of the table on the row and returns its record and its key for each index.
+// rid is the row being updated, its own entries in the unique indexes are not duplicates, nil for a new row.
+func (t *table) checkRow(row []Value, rid *heapmanager.RID) ([]byte, []indexmanager.IndexKey, error) {
+	for i, column := range t.schema.Columns {
+		dataType, err := types.ParseDataType(column.DataType)
+		if err != nil {
+			return nil, nil, err
+		}
+		if row[i], err = types.Normalize(dataType, row[i]); err != nil {
+			return nil, nil, fmt.Errorf("column %s of table %s: %w", column.Name, t.schema.Name, err)
+		}
+
+		if row[i] == nil && !column.Nullable() {
+			return nil, nil, t.violation(dberrors.NotNull, []string{column.Name}, "")
+		}
+	}
+
+	for _, check := range t.checks {
+		passed := check.expr.Eval(func(column string) any {
+			i, _ := t.codec.ColumnIndex(column)
+			return row[i]
+		})
+		if !passed {
+			return nil, nil, t.violation(dberrors.Check, []string{check.column}, check.expr.String())
+		}
+	}
+
+	record, err := t.codec.Encode(row)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := t.keys(record)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i, index := range t.indexes {
+		if !index.metadata.Unique {
+			continue
+		}
+
+		// the values of the included columns follow the key and are not part of it
+		key := keys[i][:len(index.metadata.Columns)]
+		// a null is never equal to another one, a key with a null has no duplicate
+		if hasNull(key) {
+			continue
+		}
+		entries, err := indexmanager.FindIndexEntries(t.schema.Name, index.metadata.Name, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, entry := range entries {
+			if rid == nil || entry.RID != *rid {
+				constraint := index.constraint
+				if constraint == "" {
+					constraint = dberrors.Unique
+				}
+				return nil, nil, t.violation(constraint, index.metadata.Columns, index.metadata.Name)
+			}
+		}
+	}
+	return record, keys, nil
+}
+
+func (t *table) violation(constraint dberrors.ConstraintType, columns []string, name string) error {
+	return &dberrors.ConstraintViolationError{Constraint: constraint, Table: t.schema.Name, Columns: columns, Name: name}
+}
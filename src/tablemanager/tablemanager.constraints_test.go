@@ -0,0 +1,153 @@
+package tablemanager
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/schemamanager"
+)
+
+// the schema, the heaps and the indexes of the tests are created in the working directory, the tests run in a temporary one
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "tablemanager")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		panic(err)
+	}
+	if err := os.Mkdir("schemamanager", 0755); err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile("schemamanager/schema.json", []byte(`{"tables":[]}`+"\n"), 0644); err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// creates the table with its heap, the test fails if it cannot
+func createTestTable(t *testing.T, table schemamanager.Table) {
+	t.Helper()
+
+	if err := heapmanager.CreateHeap(table.Name); err != nil {
+		t.Fatalf("failed to create the heap of %s: %s", table.Name, err)
+	}
+	if err := schemamanager.AddTable(table); err != nil {
+		t.Fatalf("failed to add table %s: %s", table.Name, err)
+	}
+}
+
+func insert(t *testing.T, table string, columns []string, values ...Value) heapmanager.RID {
+	t.Helper()
+
+	rid, err := InsertRow(table, columns, values)
+	if err != nil {
+		t.Fatalf("failed to insert %v into %s: %s", values, table, err)
+	}
+	return rid
+}
+
+// checks that err is a violation of the constraint on the columns
+func checkViolation(t *testing.T, err error, constraint dberrors.ConstraintType, columns ...string) {
+	t.Helper()
+
+	var violation *dberrors.ConstraintViolationError
+	if !errors.As(err, &violation) {
+		t.Fatalf("expected a violation of %s, got %v", constraint, err)
+	}
+	if violation.Constraint != constraint || !slices.Equal(violation.Columns, columns) {
+		t.Fatalf("expected a violation of %s on %v, got %s", constraint, columns, violation)
+	}
+}
+
+func TestNotNull(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name: "notnull",
+		Columns: []schemamanager.Column{
+			{Name: "id", DataType: "int", PrimaryKey: true},
+			{Name: "name", DataType: "string", NotNull: true},
+			{Name: "note", DataType: "string"},
+		},
+	})
+
+	insert(t, "notnull", []string{"id", "name"}, 1, "a")
+
+	_, err := InsertRow("notnull", []string{"id"}, []Value{2})
+	checkViolation(t, err, dberrors.NotNull, "name")
+	_, err = InsertRow("notnull", []string{"name"}, []Value{"b"})
+	checkViolation(t, err, dberrors.NotNull, "id")
+}
+
+func TestUnique(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name: "unique",
+		Columns: []schemamanager.Column{
+			{Name: "id", DataType: "int", PrimaryKey: true},
+			{Name: "email", DataType: "string", Unique: true},
+		},
+	})
+
+	first := insert(t, "unique", []string{"id", "email"}, 1, "a@x")
+	second := insert(t, "unique", []string{"id", "email"}, 2, "b@x")
+
+	_, err := InsertRow("unique", []string{"id", "email"}, []Value{1, "c@x"})
+	checkViolation(t, err, dberrors.PrimaryKey, "id")
+	_, err = InsertRow("unique", []string{"id", "email"}, []Value{3, "a@x"})
+	checkViolation(t, err, dberrors.Unique, "email")
+
+	// a row keeps its own key, and cannot take the key of another row
+	if err := UpdateRow("unique", first, []string{"email"}, []Value{"a@x"}); err != nil {
+		t.Fatalf("failed to update a row to its own key: %s", err)
+	}
+	err = UpdateRow("unique", second, []string{"email"}, []Value{"a@x"})
+	checkViolation(t, err, dberrors.Unique, "email")
+
+	// a null is not equal to another null
+	insert(t, "unique", []string{"id"}, 3)
+	insert(t, "unique", []string{"id"}, 4)
+	if err := UpdateRow("unique", second, []string{"email"}, []Value{nil}); err != nil {
+		t.Fatalf("failed to update a key to null: %s", err)
+	}
+
+	// the key of a deleted row can be used again
+	if err := DeleteRow("unique", first); err != nil {
+		t.Fatalf("failed to delete: %s", err)
+	}
+	insert(t, "unique", []string{"id", "email"}, 1, "a@x")
+}
+
+func TestCheck(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name: "check",
+		Columns: []schemamanager.Column{
+			{Name: "id", DataType: "int", PrimaryKey: true},
+			{Name: "price", DataType: "float", Check: "price > 0"},
+		},
+	})
+
+	rid := insert(t, "check", []string{"id", "price"}, 1, 2.5)
+
+	_, err := InsertRow("check", []string{"id", "price"}, []Value{2, -1.0})
+	checkViolation(t, err, dberrors.Check, "price")
+	err = UpdateRow("check", rid, []string{"price"}, []Value{0.0})
+	checkViolation(t, err, dberrors.Check, "price")
+
+	// the check is unknown on a null, the row passes
+	insert(t, "check", []string{"id"}, 3)
+
+	// the failed writes changed nothing
+	row, err := GetRow("check", rid)
+	if err != nil {
+		t.Fatalf("failed to read the row: %s", err)
+	}
+	if row[1] != 2.5 {
+		t.Fatalf("the price is %v after a failed update, expected 2.5", row[1])
+	}
+}
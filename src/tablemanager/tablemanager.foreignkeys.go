@@ -0,0 +1,163 @@
+package tablemanager
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/indexmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/keycodec"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/rowcodec"
+	"github.com/SpaghettiDB/Storage-Engine/src/schemamanager"
+)
+
+// a foreign key of the table
+type foreignKey struct {
+	schema schemamanager.ForeignKey
+	// the unique index of the parent table on the referenced columns
+	parentIndex string
+	// the positions of the columns of the foreign key in the row
+	columns []int
+	// returns the key of a row of the table in the parent index
+	keyOf indexmanager.KeyFunc
+}
+
+// a foreign key of a table that references the table
+type reference struct {
+	table      string
+	foreignKey schemamanager.ForeignKey
+	// returns the referenced key of a row of the table, the key of the rows that reference it in the index of the foreign key
+	keyOf indexmanager.KeyFunc
+}
+
+// reads the foreign keys of the table and the foreign keys that reference it from the tables of the schema
+func (t *table) openForeignKeys(tables []schemamanager.Table) error {
+	for _, fk := range t.schema.ForeignKeys {
+		parent := slices.IndexFunc(tables, func(table schemamanager.Table) bool { return table.Name == fk.RefTable })
+		if parent == -1 {
+			return fmt.Errorf("table %s of foreign key %s does not exist", fk.RefTable, fk.Name)
+		}
+		parentIndex, ok := tables[parent].KeyIndex(fk.RefColumns)
+		if !ok {
+			return fmt.Errorf("table %s has no key index for foreign key %s", fk.RefTable, fk.Name)
+		}
+
+		keyOf, err := rowcodec.IndexKeyFunc(t.schema, schemamanager.Index{Name: fk.Name, ColumnNames: fk.Columns})
+		if err != nil {
+			return err
+		}
+		columns := make([]int, len(fk.Columns))
+		for i, name := range fk.Columns {
+			if columns[i], err = t.codec.ColumnIndex(name); err != nil {
+				return err
+			}
+		}
+		t.foreignKeys = append(t.foreignKeys, foreignKey{schema: fk, parentIndex: parentIndex.Name, columns: columns, keyOf: keyOf})
+	}
+
+	for _, r := range schemamanager.ReferencesTo(tables, t.schema.Name) {
+		keyOf, err := rowcodec.IndexKeyFunc(t.schema, schemamanager.Index{Name: r.ForeignKey.Name, ColumnNames: r.ForeignKey.RefColumns})
+		if err != nil {
+			return err
+		}
+		t.references = append(t.references, reference{table: r.Table.Name, foreignKey: r.ForeignKey, keyOf: keyOf})
+	}
+	return nil
+}
+
+// checks that the row references a row of the parent table of each foreign key of the table,
+// a foreign key with a null in its columns references no row
+func (t *table) checkForeignKeys(row []Value, record []byte) error {
+	for _, fk := range t.foreignKeys {
+		if slices.ContainsFunc(fk.columns, func(i int) bool { return row[i] == nil }) {
+			continue
+		}
+
+		key, err := fk.keyOf(record)
+		if err != nil {
+			return err
+		}
+		entries, err := indexmanager.FindIndexEntries(fk.schema.RefTable, fk.parentIndex, key)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return t.violation(dberrors.ForeignKey, fk.schema.Columns, fk.schema.Name)
+		}
+	}
+	return nil
+}
+
+// checks that a row whose record changes from oldRecord to record keeps the key that rows reference
+func (t *table) checkReferences(oldRecord []byte, record []byte) error {
+	for _, r := range t.references {
+		oldKey, err := r.keyOf(oldRecord)
+		if err != nil {
+			return err
+		}
+		key, err := r.keyOf(record)
+		if err != nil {
+			return err
+		}
+		if hasNull(oldKey) || slices.EqualFunc(oldKey, key, bytes.Equal) {
+			continue
+		}
+
+		entries, err := indexmanager.FindIndexEntries(r.table, r.foreignKey.Name, oldKey)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return r.violation()
+		}
+	}
+	return nil
+}
+
+// applies the ON DELETE action of each foreign key that references the table to the rows that reference the deleted row.
+// the rows are found again after each change, a cascade may have deleted or changed the next one
+func (t *table) deleteReferences(tx *logmanager.Transaction, record []byte) error {
+	for _, r := range t.references {
+		key, err := r.keyOf(record)
+		if err != nil {
+			return err
+		}
+		if hasNull(key) {
+			continue
+		}
+
+		for {
+			entries, err := indexmanager.FindIndexEntries(r.table, r.foreignKey.Name, key)
+			if err != nil {
+				return err
+			}
+			if len(entries) == 0 {
+				break
+			}
+
+			switch r.foreignKey.OnDelete {
+			case schemamanager.Cascade:
+				err = deleteRow(tx, r.table, entries[0].RID)
+			case schemamanager.SetNull:
+				err = updateRow(tx, r.table, entries[0].RID, r.foreignKey.Columns, make([]Value, len(r.foreignKey.Columns)))
+			default:
+				err = r.violation()
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r reference) violation() error {
+	return &dberrors.ConstraintViolationError{Constraint: dberrors.ForeignKey, Table: r.table, Columns: r.foreignKey.Columns, Name: r.foreignKey.Name}
+}
+
+// reports whether one of the values of the key is null
+func hasNull(key indexmanager.IndexKey) bool {
+	return slices.ContainsFunc(key, func(value []byte) bool { return keycodec.IsNull(value, keycodec.Ascending) })
+}
@@ -0,0 +1,38 @@
+package tablemanager
+
+import (
+	"testing"
+
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/schemamanager"
+)
+
+func TestForeignKey(t *testing.T) {
+	createTestTable(t, schemamanager.Table{
+		Name: "parent",
+		Columns: []schemamanager.Column{
+			{Name: "id", DataType: "int", PrimaryKey: true},
+		},
+	})
+	createTestTable(t, schemamanager.Table{
+		Name: "child",
+		Columns: []schemamanager.Column{
+			{Name: "id", DataType: "int", PrimaryKey: true},
+			{Name: "parent", DataType: "int"},
+		},
+		ForeignKeys: []schemamanager.ForeignKey{
+			{Columns: []string{"parent"}, RefTable: "parent", RefColumns: []string{"id"}},
+		},
+	})
+
+	parent := insert(t, "parent", []string{"id"}, 1)
+	insert(t, "child", []string{"id", "parent"}, 1, 1)
+	insert(t, "child", []string{"id"}, 2)
+
+	_, err := InsertRow("child", []string{"id", "parent"}, []Value{3, 2})
+	checkViolation(t, err, dberrors.ForeignKey, "parent")
+
+	// the parent is referenced, it can neither be deleted nor change its key
+	checkViolation(t, DeleteRow("parent", parent), dberrors.ForeignKey, "parent")
+	checkViolation(t, UpdateRow("parent", parent, []string{"id"}, []Value{5}), dberrors.ForeignKey, "parent")
+}
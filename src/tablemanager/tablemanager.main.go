@@ -0,0 +1,311 @@
+// this is tablemanager package main file, it writes the rows of the tables of the schema with their typed values.
+// a write checks the constraints of the columns of the table (see schemamanager.constraints.go), encodes the row
+// with rowcodec and changes the heap of the table and all its indexes in one transaction, so a row that violates
+// a constraint changes nothing.
+//
+// a violated constraint is returned as an *errors.ConstraintViolationError of the errors package.
+
+package tablemanager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/SpaghettiDB/Storage-Engine/src/checkexpr"
+	dberrors "github.com/SpaghettiDB/Storage-Engine/src/errors"
+	"github.com/SpaghettiDB/Storage-Engine/src/heapmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/indexmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/logmanager"
+	"github.com/SpaghettiDB/Storage-Engine/src/rowcodec"
+	"github.com/SpaghettiDB/Storage-Engine/src/schemamanager"
+)
+
+// Value is the value of a column, see rowcodec.Value.
+type Value = rowcodec.Value
+
+// a table of the schema with what its writes need
+type table struct {
+	schema schemamanager.Table
+	codec  *rowcodec.Codec
+	checks []check
+	// the indexes of the table in the order of the index manager
+	indexes []index
+	// the foreign keys of the table, and the foreign keys that reference it
+	foreignKeys []foreignKey
+	references  []reference
+}
+
+// the CHECK of a column
+type check struct {
+	column string
+	expr   *checkexpr.Expr
+}
+
+type index struct {
+	metadata indexmanager.IndexMetadata
+	keyOf    indexmanager.KeyFunc
+	// the constraint the index enforces, empty for an index that is not a key
+	constraint dberrors.ConstraintType
+}
+
+// InsertRow adds a row to the table in its own transaction and returns its rid. values has the value of each
+// of the columns in order, the other columns of the table get their default value (null without one).
+func InsertRow(tableName string, columns []string, values []Value) (heapmanager.RID, error) {
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return heapmanager.RID{}, err
+	}
+	rid, err := insertRow(tx, tableName, columns, values)
+	return rid, tx.Finish(err)
+}
+
+// InsertRowTx adds the row to the table as part of the transaction tx, see InsertRow.
+// if it fails, its changes are undone and tx stays open.
+func InsertRowTx(tx *logmanager.Transaction, tableName string, columns []string, values []Value) (heapmanager.RID, error) {
+	savepoint := tx.Savepoint()
+	rid, err := insertRow(tx, tableName, columns, values)
+	return rid, tx.EndStatement(savepoint, err)
+}
+
+func insertRow(tx *logmanager.Transaction, tableName string, columns []string, values []Value) (heapmanager.RID, error) {
+	t, err := openTable(tableName)
+	if err != nil {
+		return heapmanager.RID{}, err
+	}
+
+	row := make([]Value, len(t.schema.Columns))
+	for i, column := range t.schema.Columns {
+		if row[i], err = column.DefaultValue(); err != nil {
+			return heapmanager.RID{}, err
+		}
+	}
+	if err := t.setValues(row, columns, values); err != nil {
+		return heapmanager.RID{}, err
+	}
+
+	record, keys, err := t.checkRow(row, nil)
+	if err != nil {
+		return heapmanager.RID{}, err
+	}
+
+	rid, err := heapmanager.AddRowToHeapTx(tx, tableName, record)
+	if err != nil {
+		return heapmanager.RID{}, err
+	}
+	if len(keys) > 0 {
+		if err := indexmanager.AddEntryToTableIndexesTx(tx, tableName, keys, rid); err != nil {
+			return heapmanager.RID{}, err
+		}
+	}
+	// the row is in the table first, so it can reference itself
+	if err := t.checkForeignKeys(row, record); err != nil {
+		return heapmanager.RID{}, err
+	}
+	return rid, nil
+}
+
+// UpdateRow sets the columns of the row rid of the table to the values in its own transaction,
+// the other columns keep their values. the key of a row referenced by a foreign key cannot change.
+func UpdateRow(tableName string, rid heapmanager.RID, columns []string, values []Value) error {
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(updateRow(tx, tableName, rid, columns, values))
+}
+
+// UpdateRowTx updates the row as part of the transaction tx, see UpdateRow.
+// if it fails, its changes are undone and tx stays open.
+func UpdateRowTx(tx *logmanager.Transaction, tableName string, rid heapmanager.RID, columns []string, values []Value) error {
+	savepoint := tx.Savepoint()
+	return tx.EndStatement(savepoint, updateRow(tx, tableName, rid, columns, values))
+}
+
+func updateRow(tx *logmanager.Transaction, tableName string, rid heapmanager.RID, columns []string, values []Value) error {
+	t, err := openTable(tableName)
+	if err != nil {
+		return err
+	}
+
+	oldRecord, err := heapmanager.GetRowByRID(tableName, rid)
+	if err != nil {
+		return err
+	}
+	row, err := t.codec.Decode(oldRecord)
+	if err != nil {
+		return err
+	}
+	if err := t.setValues(row, columns, values); err != nil {
+		return err
+	}
+
+	record, keys, err := t.checkRow(row, &rid)
+	if err != nil {
+		return err
+	}
+	oldKeys, err := t.keys(oldRecord)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		if err := indexmanager.RemoveEntryFromTableIndexesTx(tx, tableName, oldKeys, rid); err != nil {
+			return err
+		}
+	}
+	if err := heapmanager.UpdateRowInHeapTx(tx, tableName, rid, record); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		if err := indexmanager.AddEntryToTableIndexesTx(tx, tableName, keys, rid); err != nil {
+			return err
+		}
+	}
+
+	if err := t.checkForeignKeys(row, record); err != nil {
+		return err
+	}
+	return t.checkReferences(oldRecord, record)
+}
+
+// DeleteRow removes the row rid from the table and from all its indexes in its own transaction.
+// the rows that reference it by a foreign key are handled by the ON DELETE action of the foreign key.
+func DeleteRow(tableName string, rid heapmanager.RID) error {
+	tx, err := logmanager.Begin()
+	if err != nil {
+		return err
+	}
+	return tx.Finish(deleteRow(tx, tableName, rid))
+}
+
+// DeleteRowTx removes the row as part of the transaction tx, see DeleteRow.
+// if it fails, its changes are undone and tx stays open.
+func DeleteRowTx(tx *logmanager.Transaction, tableName string, rid heapmanager.RID) error {
+	savepoint := tx.Savepoint()
+	return tx.EndStatement(savepoint, deleteRow(tx, tableName, rid))
+}
+
+func deleteRow(tx *logmanager.Transaction, tableName string, rid heapmanager.RID) error {
+	t, err := openTable(tableName)
+	if err != nil {
+		return err
+	}
+
+	record, err := heapmanager.GetRowByRID(tableName, rid)
+	if err != nil {
+		return err
+	}
+	keys, err := t.keys(record)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) > 0 {
+		if err := indexmanager.RemoveEntryFromTableIndexesTx(tx, tableName, keys, rid); err != nil {
+			return err
+		}
+	}
+	if err := heapmanager.DeleteRowFromHeapTx(tx, tableName, rid); err != nil {
+		return err
+	}
+
+	// the row is not in the table anymore, a row that references itself is not found
+	return t.deleteReferences(tx, record)
+}
+
+// GetRow returns the values of the columns of the row rid of the table, in the order of the columns.
+func GetRow(tableName string, rid heapmanager.RID) ([]Value, error) {
+	t, err := openTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := heapmanager.GetRowByRID(tableName, rid)
+	if err != nil {
+		return nil, err
+	}
+	return t.codec.Decode(record)
+}
+
+// reads the table from the schema with its checks, its indexes and its foreign keys
+func openTable(name string) (*table, error) {
+	tables, err := schemamanager.GetTables()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, schema := range tables {
+		if schema.Name != name {
+			continue
+		}
+
+		codec, err := rowcodec.New(schema)
+		if err != nil {
+			return nil, err
+		}
+		t := &table{schema: schema, codec: codec}
+
+		for _, column := range schema.Columns {
+			expr, err := schema.CompileCheck(column)
+			if err != nil {
+				return nil, fmt.Errorf("column %s of table %s: %w", column.Name, name, err)
+			}
+			if expr != nil {
+				t.checks = append(t.checks, check{column: column.Name, expr: expr})
+			}
+		}
+
+		// a table without indexes has no metadata file
+		indexes, err := indexmanager.GetIndexesMetadata(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, metadata := range indexes {
+			keyOf, err := rowcodec.IndexKeyFunc(schema, schemamanager.Index{Name: metadata.Name, ColumnNames: metadata.Columns, IncludeColumns: metadata.Include})
+			if err != nil {
+				return nil, err
+			}
+			t.indexes = append(t.indexes, index{metadata: metadata, keyOf: keyOf, constraint: schema.IndexConstraint(metadata.Name)})
+		}
+
+		if err := t.openForeignKeys(tables); err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+	return nil, fmt.Errorf("table %s does not exist", name)
+}
+
+// sets the values of the columns in the row
+func (t *table) setValues(row []Value, columns []string, values []Value) error {
+	if len(columns) != len(values) {
+		return fmt.Errorf("got %d columns and %d values", len(columns), len(values))
+	}
+
+	set := make(map[int]bool, len(columns))
+	for i, name := range columns {
+		column, err := t.codec.ColumnIndex(name)
+		if err != nil {
+			return err
+		}
+		if set[column] {
+			return fmt.Errorf("column %s is given twice", name)
+		}
+		set[column] = true
+		row[column] = values[i]
+	}
+	return nil
+}
+
+// returns the key of the record for each index of the table
+func (t *table) keys(record []byte) ([]indexmanager.IndexKey, error) {
+	keys := make([]indexmanager.IndexKey, len(t.indexes))
+	for i, index := range t.indexes {
+		var err error
+		if keys[i], err = index.keyOf(record); err != nil {
+			return nil, fmt.Errorf("failed to get the key of index %s: %w", index.metadata.Name, err)
+		}
+	}
+	return keys, nil
+}
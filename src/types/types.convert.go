@@ -0,0 +1,118 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// the conversions between the data types, used when the type of a column changes:
+//   - any data type to string and to itself always works
+//   - int to float works when the float holds the int exactly, int to bool only for 0 and 1
+//   - float to int works when the float has no fraction and fits in an int
+//   - bool to int gives 0 or 1
+//   - string to any data type parses the string like strconv does, RFC 3339 for a timestamp
+//
+// the other conversions (a timestamp to an int for example) are not allowed.
+// int is already 64 bits, so there is no wider integer type to convert it to.
+
+// Convertible reports whether values of the data type from can be converted to the data type to,
+// the conversion of a value can still fail (see Convert).
+func Convertible(from DataType, to DataType) bool {
+	if from == to || from == String || to == String {
+		return true
+	}
+
+	switch from {
+	case Int:
+		return to == Float || to == Bool
+	case Float:
+		return to == Int
+	case Bool:
+		return to == Int
+	}
+	return false
+}
+
+// Convert returns the value of the data type from as a value of the data type to, nil stays nil.
+func Convert(value any, from DataType, to DataType) (any, error) {
+	value, err := Normalize(from, value)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	if !Convertible(from, to) {
+		return nil, fmt.Errorf("%s cannot be converted to %s", from, to)
+	}
+	if from == to {
+		return value, nil
+	}
+
+	switch v := value.(type) {
+	case int64:
+		switch to {
+		case Float:
+			f := float64(v)
+			if f >= math.MaxInt64 || int64(f) != v {
+				return nil, fmt.Errorf("%d cannot be converted to float exactly", v)
+			}
+			return f, nil
+		case Bool:
+			if v != 0 && v != 1 {
+				return nil, fmt.Errorf("%d cannot be converted to bool, only 0 and 1 can", v)
+			}
+			return v == 1, nil
+		}
+		return strconv.FormatInt(v, 10), nil
+
+	case float64:
+		if to == Int {
+			if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+				return nil, fmt.Errorf("%v cannot be converted to int", v)
+			}
+			return int64(v), nil
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+
+	case bool:
+		if to == Int {
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		}
+		return strconv.FormatBool(v), nil
+
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+
+	case string:
+		return parse(v, to)
+	}
+	return nil, fmt.Errorf("%v (%T) cannot be converted to %s", value, value, to)
+}
+
+// parses the string as a value of the data type
+func parse(s string, to DataType) (any, error) {
+	var value any
+	var err error
+	switch to {
+	case Int:
+		value, err = strconv.ParseInt(s, 10, 64)
+	case Float:
+		value, err = strconv.ParseFloat(s, 64)
+	case Bool:
+		value, err = strconv.ParseBool(s)
+	case Timestamp:
+		var t time.Time
+		t, err = time.Parse(time.RFC3339Nano, s)
+		value = t.UTC()
+	default:
+		return nil, fmt.Errorf("unknown data type %q", to)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%q cannot be converted to %s: %w", s, to, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,86 @@
+// this is types package main file, it defines the data types of the columns of the schema
+// and the go values that hold them, the key and row codecs and the schema share them.
+//
+// | DataType  | go value  |
+// |-----------|-----------|
+// | int       | int64     |
+// | float     | float64   |
+// | string    | string    |
+// | bool      | bool      |
+// | timestamp | time.Time |
+//
+// a nil value is null.
+
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// DataType is the type of a column, its value is the name used in the schema.
+type DataType string
+
+const (
+	Int       DataType = "int"
+	Float     DataType = "float"
+	String    DataType = "string"
+	Bool      DataType = "bool"
+	Timestamp DataType = "timestamp"
+)
+
+// ParseDataType returns the data type with the given schema name.
+func ParseDataType(name string) (DataType, error) {
+	switch dataType := DataType(name); dataType {
+	case Int, Float, String, Bool, Timestamp:
+		return dataType, nil
+	}
+	return "", fmt.Errorf("unknown data type %q", name)
+}
+
+// Normalize returns the value as the go value of the data type, so int32 becomes int64 for an int column.
+// nil stays nil, a value that does not fit the data type is an error.
+func Normalize(dataType DataType, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch dataType {
+	case Int:
+		switch v := value.(type) {
+		case int64:
+			return v, nil
+		case int:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case int16:
+			return int64(v), nil
+		case int8:
+			return int64(v), nil
+		}
+	case Float:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case float32:
+			return float64(v), nil
+		}
+	case String:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	case Bool:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case Timestamp:
+		if v, ok := value.(time.Time); ok {
+			return v, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown data type %q", dataType)
+	}
+
+	return nil, fmt.Errorf("%v (%T) is not a valid %s value", value, value, dataType)
+}